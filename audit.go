@@ -18,6 +18,7 @@ import (
 	"github.com/ccmonky/errors"
 	"github.com/ccmonky/pkg/utils"
 	"github.com/ccmonky/render"
+	"github.com/ccmonky/sqlkit/fingerprint"
 	"github.com/ccmonky/sqlkit/mysql"
 	"github.com/golang/protobuf/proto"
 	"github.com/prometheus/client_golang/prometheus"
@@ -142,12 +143,24 @@ type Audit struct {
 	// Whitelist contains sqls which will not be audited
 	Whitelist []string `json:"whitelist,omitempty"`
 
+	// Fingerprint if true, sqls and whitelist are keyed by fingerprint instead of the exact query,
+	// so the same statement with different literals is audited once, see `fingerprint.Fingerprint`
+	Fingerprint bool `json:"fingerprint,omitempty"`
+
 	// SqlCacheDuration sql explain result cache duration, default is forever
 	SqlCacheDuration *utils.Duration `json:"sql_cache_duration,omitempty"`
 
+	// SqlCache config of the cache of audited sqls, default is `DefaultCacheSize` entries,
+	// NOTE: sqls set by `SetSql` or `AddBlacklistQuery` are not evicted by size or TTL of the cache
+	SqlCache CacheConfig `json:"sql_cache,omitempty"`
+
 	// ExplainExtraAlarmSubstrs alarm when explain extra contains the sub-string in this list
 	ExplainExtraAlarmSubstrs []string `json:"explain_extra_alarm_substrs,omitempty"`
 
+	// Rules extra audit rules, e.g. `[{"type":"pattern","pattern":"(?i)^SELECT \\*","alarm_type":"alarm"}]`,
+	// which can be replaced at runtime by `SetRules`, see `AuditRuleRegistry`
+	Rules AuditRuleList `json:"rules,omitempty"`
+
 	// ShouldAuditFunc used to determine if a sql should be audited, default behavior is detect if startss with `select|insert|update|delete`
 	// NOTE: it does contains the whitelist
 	ShouldAuditFunc func(query string) bool `json:"-"`
@@ -157,11 +170,12 @@ type Audit struct {
 
 	logger                   *zap.Logger
 	db                       *sql.DB
-	sqls                     sync.Map // map[query]*Sql
-	whitelist                sync.Map // map[query]struct{}
+	sqls                     *Cache[string, *Sql]
+	blacklist                sync.Map // map[key]*Sql
+	whitelist                sync.Map // map[key]struct{}
 	explainExtraAlarmSubstrs map[string]struct{}
+	rules                    atomic.Value // NOTE: AuditRuleList
 	labels                   prometheus.Labels
-	//rewrites                 sync.Map // map[query]*Rewrite // FIXME: use Middleware?
 }
 
 func (audit *Audit) SetDB(db *sql.DB) error {
@@ -198,17 +212,25 @@ func (audit *Audit) AddBlacklistQuery(query string, alarmType AlarmType, reason
 		Reason:    reason,
 		CreatedAt: Now(),
 	}
-	audit.sqls.Store(query, &s)
+	audit.blacklist.Store(audit.key(query), &s)
 }
 
 // SetWhitelistQuery 用于动态设定白名单查询, 如出现误判场景
 // NOTE: no persistence!
 func (audit *Audit) AddWhitelistQuery(query string) {
-	audit.whitelist.Store(query, struct{}{})
+	audit.whitelist.Store(audit.key(query), struct{}{})
 }
 
 func (audit *Audit) DelWhitelistQuery(query string) {
-	audit.whitelist.Delete(query)
+	audit.whitelist.Delete(audit.key(query))
+}
+
+// key return the key of query in sqls and whitelist
+func (audit *Audit) key(query string) string {
+	if audit.Fingerprint {
+		return fingerprint.Fingerprint(query)
+	}
+	return query
 }
 
 // Whitelists 返回所有白名单查询, 包括静态配置和动态添加
@@ -251,9 +273,14 @@ func (audit *Audit) Provision(ctx context.Context) error {
 	for _, ss := range audit.ExplainExtraAlarmSubstrs {
 		audit.explainExtraAlarmSubstrs[ss] = struct{}{}
 	}
-	audit.whitelist.Store(mysql.TablesQuery, struct{}{})
+	if err := audit.Rules.Provision(ctx); err != nil {
+		return err
+	}
+	audit.rules.Store(audit.Rules)
+	audit.sqls = NewCache[string, *Sql]("audit", audit.SqlCache)
+	audit.whitelist.Store(audit.key(mysql.TablesQuery), struct{}{})
 	for _, query := range audit.Whitelist {
-		audit.whitelist.Store(query, struct{}{})
+		audit.whitelist.Store(audit.key(query), struct{}{})
 	}
 	auditMetrics.init.Do(func() {
 		initAuditMetrics()
@@ -282,7 +309,11 @@ func (audit *Audit) Tables(ctx context.Context) (map[string]*mysql.Table, error)
 // Sqls return all sqls cached for representation
 func (audit *Audit) Sqls() map[string]*Sql {
 	var sqls = make(map[string]*Sql)
-	audit.sqls.Range(func(k, v interface{}) bool {
+	audit.sqls.Range(func(k string, v *Sql) bool {
+		sqls[k] = v
+		return true
+	})
+	audit.blacklist.Range(func(k, v interface{}) bool {
 		sqls[k.(string)] = v.(*Sql)
 		return true
 	})
@@ -297,10 +328,25 @@ type Sql struct {
 	AlarmType AlarmType          `json:"alarm_type"`
 	Reason    string             `json:"reason"`
 	CreatedAt time.Time          `json:"created_at"`
+	Info      *QueryInfo         `json:"info,omitempty"` // NOTE: info of the first seen query
+}
+
+// contextLogFields return ContextLogFields with query info and the original query if rewritten
+func (audit *Audit) contextLogFields(ctx context.Context) []zap.Field {
+	var fields []zap.Field
+	if audit.ContextLogFields != nil {
+		fields = audit.ContextLogFields(ctx)
+	}
+	fields = append(fields, queryInfoFields(ctx)...)
+	return append(fields, rewriteFields(ctx)...)
 }
 
 func (audit *Audit) ShouldAudit(query string) bool {
-	if _, ok := audit.whitelist.Load(query); ok {
+	return audit.shouldAudit(query, audit.key(query))
+}
+
+func (audit *Audit) shouldAudit(query, key string) bool {
+	if _, ok := audit.whitelist.Load(key); ok {
 		return false
 	}
 	return audit.ShouldAuditFunc(query)
@@ -353,6 +399,27 @@ func (audit *Audit) detectAlarmType(er *mysql.ExplainRow) (alarmType AlarmType,
 	return
 }
 
+// SetRules provisions the rules and replaces the current ones, the cached sqls(except the blacklist) are cleared so they are audited again
+func (audit *Audit) SetRules(ctx context.Context, rules AuditRuleList) error {
+	if err := rules.Provision(ctx); err != nil {
+		return err
+	}
+	audit.rules.Store(rules)
+	audit.sqls.Clear()
+	return nil
+}
+
+// AllRules return the audit rules in use
+func (audit *Audit) AllRules() AuditRuleList {
+	rules, _ := audit.rules.Load().(AuditRuleList)
+	return rules
+}
+
+// DetectRules detects the alarm type by the audit rules
+func (audit *Audit) DetectRules(query string, ers []mysql.ExplainRow) (alarmType AlarmType, reason string) {
+	return audit.AllRules().Detect(query, ers)
+}
+
 // Explain do mysql explain
 func (audit *Audit) Explain(ctx context.Context, query string, args ...interface{}) ([]mysql.ExplainRow, error) {
 	return mysql.NewMySQL(audit.db).Explain(ctx, query, args...)
@@ -360,8 +427,8 @@ func (audit *Audit) Explain(ctx context.Context, query string, args ...interface
 
 // GetSql get sql
 func (audit *Audit) GetSql(query string) *Sql {
-	if v, ok := audit.sqls.Load(query); ok {
-		return v.(*Sql)
+	if s, ok := audit.loadSql(audit.key(query)); ok {
+		return s
 	}
 	return nil
 }
@@ -374,25 +441,36 @@ func (audit *Audit) SetSql(s *Sql) error {
 	if s.Query == "" {
 		return errors.New("set sql with empty query")
 	}
-	audit.sqls.Store(s.Query, s)
+	audit.blacklist.Store(audit.key(s.Query), s)
 	return nil
 }
 
 // DeteleSql delete specified sql in cache
 func (audit *Audit) DeleteSql(query string) error {
-	audit.sqls.Delete(query)
+	key := audit.key(query)
+	audit.blacklist.Delete(key)
+	audit.sqls.Delete(key)
 	return nil
 }
 
 // ClearSqls clear cached sqls
 func (audit *Audit) ClearSqls() error {
-	audit.sqls.Range(func(key interface{}, value interface{}) bool {
-		audit.sqls.Delete(key)
+	audit.blacklist.Range(func(key interface{}, value interface{}) bool {
+		audit.blacklist.Delete(key)
 		return true
 	})
+	audit.sqls.Clear()
 	return nil
 }
 
+// loadSql return the sql of key, the blacklist takes precedence
+func (audit *Audit) loadSql(key string) (*Sql, bool) {
+	if v, ok := audit.blacklist.Load(key); ok {
+		return v.(*Sql), true
+	}
+	return audit.sqls.Load(key)
+}
+
 func (audit *Audit) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
 	return audit.before(ctx, query, args...)
 }
@@ -400,7 +478,8 @@ func (audit *Audit) Before(ctx context.Context, query string, args ...interface{
 // Before hook will print the query with it's args and return the context with the timestamp
 func (audit *Audit) before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
 	start := time.Now()
-	if !audit.ShouldAudit(query) {
+	key := audit.key(query)
+	if !audit.shouldAudit(query, key) {
 		return ctx, nil
 	}
 	defer func() {
@@ -411,30 +490,30 @@ func (audit *Audit) before(ctx context.Context, query string, args ...interface{
 	//auditMetrics.queryCount.With(audit.labels).Inc()
 	//auditMetrics.queryInFlight.With(audit.labels).Inc()
 
-	v, ok := audit.sqls.Load(query)
+	s, ok := audit.loadSql(key)
 	if ok {
-		s := v.(*Sql)
 		if audit.SqlCacheDuration != nil && time.Since(s.CreatedAt) > audit.SqlCacheDuration.Duration+jitter(30) { // NOTE: jitter avoid invalidate too many at once!
-			audit.sqls.Delete(query)
+			audit.blacklist.Delete(key)
+			audit.sqls.Delete(key)
 		} else {
 			switch s.AlarmType {
 			case Banned:
 				//auditMetrics.bannedCount.With(audit.labels).Inc()
 				if audit.SeenSqlLogLevel.Load() <= int32(Banned) {
-					fields := append([]zap.Field{zap.String("query", query), zap.Error(ErrBanned), zap.Bool(alarmFieldName, true)}, audit.ContextLogFields(ctx)...)
+					fields := append([]zap.Field{zap.String("query", query), zap.Error(ErrBanned), zap.Bool(alarmFieldName, true)}, audit.contextLogFields(ctx)...)
 					audit.logger.Error("seen banned query", fields...)
 				}
 				return ctx, errors.WithMessage(ErrBanned, query)
 			case Alarm:
 				//auditMetrics.alarmCount.With(audit.labels).Inc()
 				if audit.SeenSqlLogLevel.Load() <= int32(Alarm) {
-					fields := append([]zap.Field{zap.String("query", query), zap.Error(ErrAlarm), zap.Bool(alarmFieldName, true)}, audit.ContextLogFields(ctx)...)
+					fields := append([]zap.Field{zap.String("query", query), zap.Error(ErrAlarm), zap.Bool(alarmFieldName, true)}, audit.contextLogFields(ctx)...)
 					audit.logger.Error("seen alarm query", fields...)
 				}
 				return context.WithValue(ctx, startCtxKey{}, Now()), nil
 			default:
 				if audit.SeenSqlLogLevel.Load() <= int32(Normal) {
-					fields := append([]zap.Field{zap.String("query", query)}, audit.ContextLogFields(ctx)...)
+					fields := append([]zap.Field{zap.String("query", query)}, audit.contextLogFields(ctx)...)
 					audit.logger.Info("seen normal query", fields...)
 					return context.WithValue(ctx, startCtxKey{}, Now()), nil
 				}
@@ -442,23 +521,24 @@ func (audit *Audit) before(ctx context.Context, query string, args ...interface{
 			}
 		}
 	}
-	_, loaded := audit.sqls.LoadOrStore(query, &Sql{ // TODO: 定期(如10s)巡检mysql负载状态, 定义可放行阈值？此处目前先放行处理。
+	_, loaded := audit.sqls.LoadOrStore(key, &Sql{ // TODO: 定期(如10s)巡检mysql负载状态, 定义可放行阈值？此处目前先放行处理。
 		Query:     query,
 		Args:      args,
 		Reason:    temporaryReason,
 		CreatedAt: Now(),
+		Info:      getQueryInfo(ctx),
 	})
 	if !loaded {
-		audit.auditAsync(ctx, query, args...)
+		audit.auditAsync(ctx, key, query, args...)
 	}
 	return ctx, nil
 }
 
-func (audit *Audit) auditAsync(ctx context.Context, query string, args ...interface{}) {
+func (audit *Audit) auditAsync(ctx context.Context, key, query string, args ...interface{}) {
 	go func() {
 		defer func() {
 			if p := recover(); p != nil {
-				audit.sqls.Delete(query)
+				audit.sqls.Delete(key)
 				err := fmt.Errorf("panic: %v;\nstack trace: %s", p, debug.Stack())
 				audit.logger.Error("audit async paniced", zap.String("query", query), zap.Error(err))
 				return
@@ -468,25 +548,27 @@ func (audit *Audit) auditAsync(ctx context.Context, query string, args ...interf
 		defer cancel()
 		ers, err := audit.Explain(explainCtx, query, args...)
 		if err != nil {
-			audit.sqls.Delete(query)
+			audit.sqls.Delete(key)
 			audit.logger.Error("async explain failed", zap.Error(err), zap.String("query", query), zap.Bool(alarmFieldName, true))
 			return
 		}
 		alarmType, reason := audit.DetectAlarmType(ers)
-		audit.sqls.Store(query, &Sql{
+		if at, cause := audit.DetectRules(query, ers); at > alarmType {
+			alarmType, reason = at, cause
+		}
+		audit.sqls.Store(key, &Sql{
 			Query:     query,
 			Args:      args,
 			CreatedAt: Now(),
 			AlarmType: alarmType,
 			Reason:    reason,
 			Explain:   ers,
+			Info:      getQueryInfo(ctx),
 		})
 		fields := []zap.Field{
 			zap.String("query", query),
 		}
-		if audit.ContextLogFields != nil {
-			fields = append(fields, audit.ContextLogFields(ctx)...)
-		}
+		fields = append(fields, audit.contextLogFields(ctx)...)
 		switch alarmType {
 		case Banned:
 			//auditMetrics.bannedCount.With(audit.labels).Inc()
@@ -519,50 +601,41 @@ func (audit *Audit) after(ctx context.Context, query string, args ...interface{}
 	v := ctx.Value(startCtxKey{})
 	if start, ok := v.(time.Time); ok {
 		fields := []zap.Field{zap.String("query", query), zap.Duration("rt", time.Since(start))}
-		audit.logger.Info("query rt", append(fields, audit.ContextLogFields(ctx)...)...)
+		audit.logger.Info("query rt", append(fields, audit.contextLogFields(ctx)...)...)
 	}
 	//auditMetrics.queryInFlight.With(audit.labels).Dec()
 	return ctx, nil
 }
 
 func (audit *Audit) ExecContext(next ExecContext) ExecContext {
-	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-		ifaceArgs := namedToInterface(args)
-		ctx, err := audit.before(ctx, query, ifaceArgs...)
-		if err != nil {
-			return nil, err
-		}
-		results, err := next(ctx, query, args)
-		if err != nil {
-			return results, err
-		}
-		_, err = audit.after(ctx, query, ifaceArgs...)
-		if err != nil {
-			return nil, err
-		}
-		return results, err
-	}
+	return FromHooks(audit).ExecContext(next)
 }
 
 func (audit *Audit) QueryContext(next QueryContext) QueryContext {
-	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-		ifaceArgs := namedToInterface(args)
-		ctx, err := audit.before(ctx, query, ifaceArgs...)
-		if err != nil {
-			return nil, err
-		}
-		rows, err := next(ctx, query, args)
-		if err != nil {
-			return rows, err
-		}
-		_, err = audit.after(ctx, query, ifaceArgs...)
-		if err != nil {
-			return nil, err
+	return FromHooks(audit).QueryContext(next)
+}
+
+// PrepareContext reject the query which is known as banned before it is prepared on the server,
+// NOTE: new found query will be audited when executed, since explain requires the args
+func (audit *Audit) PrepareContext(next PrepareContext) PrepareContext {
+	return func(ctx context.Context, query string) (driver.Stmt, error) {
+		if audit.ShouldAudit(query) {
+			if s := audit.GetSql(query); s != nil && s.AlarmType == Banned {
+				if audit.SeenSqlLogLevel.Load() <= int32(Banned) {
+					fields := append([]zap.Field{zap.String("query", query), zap.Error(ErrBanned), zap.Bool(alarmFieldName, true)}, audit.contextLogFields(ctx)...)
+					audit.logger.Error("prepare banned query", fields...)
+				}
+				return nil, errors.WithMessage(ErrBanned, query)
+			}
 		}
-		return rows, err
+		return next(ctx, query)
 	}
 }
 
+func (audit *Audit) CloseStmt(next CloseStmt) CloseStmt {
+	return next
+}
+
 // DefaultShouldAudit sql是否审计的默认实现
 func DefaultShouldAudit(query string) bool {
 	query = strings.TrimSpace(query)
@@ -653,7 +726,9 @@ func (audit *Audit) ConfigAPI(w http.ResponseWriter, r *http.Request) {
 			"seen_sql_log_level":          audit.SeenSqlLogLevel.Load(),
 			"whitelist":                   audit.Whitelists(),
 			"sql_cache_duration":          audit.SqlCacheDuration,
+			"sql_cache":                   audit.sqls.Stats(),
 			"explain_extra_alarm_substrs": audit.explainExtraAlarmSubstrs,
+			"rules":                       audit.AllRules(),
 		},
 	})
 }
@@ -715,6 +790,31 @@ func (audit *Audit) SetSeenSqlLogLevelAPI(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// SetRulesAPI replace the audit rules with the JSON array in body, e.g. `[{"type":"pattern","pattern":"^DELETE"}]`
+func (audit *Audit) SetRulesAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.Unknown))
+		return
+	}
+	defer r.Body.Close()
+	rules, err := UnmarshalAuditRules(body)
+	if err != nil {
+		render.R(renderName).Err(w, r, err)
+		return
+	}
+	err = audit.SetRules(r.Context(), rules)
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+		return
+	}
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"rules": audit.AllRules(),
+		},
+	})
+}
+
 // WhitelistQueryAPI
 func (audit *Audit) WhitelistAPI(w http.ResponseWriter, r *http.Request) {
 	body, err := io.ReadAll(r.Body)
@@ -803,6 +903,7 @@ var (
 const temporaryReason = "__temporary"
 
 var (
-	_ Middleware     = (*Audit)(nil)
-	_ sqlhooks.Hooks = (*Audit)(nil)
+	_ Middleware        = (*Audit)(nil)
+	_ PrepareMiddleware = (*Audit)(nil)
+	_ sqlhooks.Hooks    = (*Audit)(nil)
 )
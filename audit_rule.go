@@ -0,0 +1,207 @@
+package sqlkit
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+
+	"github.com/ccmonky/errors"
+	"github.com/ccmonky/sqlkit/mysql"
+)
+
+// AuditRule detects the alarm type of a sql in addition to the explain thresholds of Audit, the most severe one wins.
+// Rules are registered in `AuditRuleRegistry`, so they can be configured by JSON objects with a `type` field, e.g.
+// `{"type":"pattern","pattern":"(?i)^DELETE .* LIMIT","alarm_type":"banned"}`
+type AuditRule interface {
+	Name() string
+	Provision(context.Context) error
+	Detect(query string, ers []mysql.ExplainRow) (alarmType AlarmType, reason string)
+}
+
+// AuditRuleList a list of audit rules, which is encoded as the JSON array of objects with a `type` field
+type AuditRuleList []AuditRule
+
+func (rules AuditRuleList) MarshalJSON() ([]byte, error) {
+	return AuditRuleRegistry.MarshalList(rules)
+}
+
+func (rules *AuditRuleList) UnmarshalJSON(data []byte) error {
+	vs, err := AuditRuleRegistry.UnmarshalList(data)
+	if err != nil {
+		return err
+	}
+	*rules = vs
+	return nil
+}
+
+// Provision provisions all the rules
+func (rules AuditRuleList) Provision(ctx context.Context) error {
+	for i, rule := range rules {
+		if rule == nil {
+			return errors.Errorf("audit rule %d is nil", i)
+		}
+		if err := rule.Provision(ctx); err != nil {
+			return errors.WithMessagef(err, "audit rule %s provision failed", rule.Name())
+		}
+	}
+	return nil
+}
+
+// Detect return the most severe alarm type detected by the rules
+func (rules AuditRuleList) Detect(query string, ers []mysql.ExplainRow) (alarmType AlarmType, reason string) {
+	alarmType = Normal
+	for _, rule := range rules {
+		at, cause := rule.Detect(query, ers)
+		if at > alarmType {
+			alarmType = at
+			reason = cause
+		}
+	}
+	return
+}
+
+// PatternRule alarms or bans the sqls which match the pattern, e.g. `(?i)^SELECT \*`
+type PatternRule struct {
+	// Pattern regular expression of the sql
+	Pattern string `json:"pattern"`
+
+	// AlarmType alarm type of the matched sqls, default is alarm
+	AlarmType AlarmType `json:"alarm_type"`
+
+	// Reason reason of the alarm, default is the pattern
+	Reason string `json:"reason,omitempty"`
+
+	re *regexp.Regexp
+}
+
+func (pr *PatternRule) Name() string {
+	return "pattern"
+}
+
+func (pr *PatternRule) Provision(ctx context.Context) error {
+	re, err := regexp.Compile(pr.Pattern)
+	if err != nil {
+		return errors.Adapt(err, errors.InvalidArgument)
+	}
+	pr.re = re
+	if pr.AlarmType < Normal || pr.AlarmType > Banned {
+		return errors.WithError(errors.Errorf("invalid alarm type of pattern rule: %s", pr.AlarmType), errors.InvalidArgument)
+	}
+	if pr.AlarmType == Normal {
+		pr.AlarmType = Alarm
+	}
+	if pr.Reason == "" {
+		pr.Reason = pr.Pattern
+	}
+	return nil
+}
+
+func (pr *PatternRule) Detect(query string, ers []mysql.ExplainRow) (AlarmType, string) {
+	if !pr.re.MatchString(query) {
+		return Normal, ""
+	}
+	return pr.AlarmType, "rule:pattern:" + pr.Reason
+}
+
+// ExplainRule alarms or bans the sqls whose explain rows match the types or extras and scan too many rows,
+// e.g. `{"type":"explain","types":["range"],"tables":["orders"],"alarm_rows":10000}`
+type ExplainRule struct {
+	// Types explain types to match, e.g. `ALL`, `index`, `range`
+	Types []string `json:"types,omitempty"`
+
+	// Extras sub-strings of explain extra to match, e.g. `Using temporary`
+	Extras []string `json:"extras,omitempty"`
+
+	// Tables only the explain rows of these tables are matched, default is all tables
+	Tables []string `json:"tables,omitempty"`
+
+	// AlarmRows the matched sql is alarmed if scan rows great than it, 0 means no alarm
+	AlarmRows int64 `json:"alarm_rows,omitempty"`
+
+	// BannedRows the matched sql is banned if scan rows great than it, 0 means no ban
+	BannedRows int64 `json:"banned_rows,omitempty"`
+
+	tables map[string]struct{}
+}
+
+func (er *ExplainRule) Name() string {
+	return "explain"
+}
+
+func (er *ExplainRule) Provision(ctx context.Context) error {
+	if len(er.Types) == 0 && len(er.Extras) == 0 {
+		return errors.WithError(errors.New("explain rule with empty types and extras"), errors.InvalidArgument)
+	}
+	if er.AlarmRows <= 0 && er.BannedRows <= 0 {
+		return errors.WithError(errors.New("explain rule with neither alarm_rows nor banned_rows"), errors.InvalidArgument)
+	}
+	er.tables = tableSet(er.Tables)
+	return nil
+}
+
+func (er *ExplainRule) Detect(query string, ers []mysql.ExplainRow) (alarmType AlarmType, reason string) {
+	alarmType = Normal
+	for i := range ers {
+		cause, ok := er.match(&ers[i])
+		if !ok {
+			continue
+		}
+		var rows int64
+		if ers[i].Rows != nil {
+			rows = int64(*ers[i].Rows)
+		}
+		at := Normal
+		if er.BannedRows > 0 && rows > er.BannedRows {
+			at = Banned
+		} else if er.AlarmRows > 0 && rows > er.AlarmRows {
+			at = Alarm
+		}
+		if at > alarmType {
+			alarmType = at
+			reason = "rule:explain:" + cause
+		}
+	}
+	return
+}
+
+// match return the cause if the explain row matches
+func (er *ExplainRule) match(row *mysql.ExplainRow) (string, bool) {
+	if row.Table == nil {
+		return "", false
+	}
+	if len(er.tables) > 0 {
+		if _, ok := er.tables[strings.ToLower(*row.Table)]; !ok {
+			return "", false
+		}
+	}
+	if row.Type != nil {
+		for _, typ := range er.Types {
+			if *row.Type == typ {
+				return "type:" + typ, true
+			}
+		}
+	}
+	if row.Extra != nil {
+		for _, extra := range er.Extras {
+			if strings.Contains(*row.Extra, extra) {
+				return "extra:" + extra, true
+			}
+		}
+	}
+	return "", false
+}
+
+// UnmarshalAuditRules decodes the rules from the JSON array of objects with a `type` field, see `AuditRuleRegistry`
+func UnmarshalAuditRules(data []byte) (AuditRuleList, error) {
+	var rules AuditRuleList
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, errors.Adapt(err, errors.InvalidArgument)
+	}
+	return rules, nil
+}
+
+var (
+	_ AuditRule = (*PatternRule)(nil)
+	_ AuditRule = (*ExplainRule)(nil)
+)
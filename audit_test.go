@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
 	"github.com/qustavo/sqlhooks/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -685,3 +686,17 @@ var (
 	queryBanned = "select id, app_name, name, version from data;"
 	now         = time.Date(2023, 2, 1, 15, 0, 0, 0, time.Local)
 )
+
+func TestAuditPrepare(t *testing.T) {
+	audit := &sqlkit.Audit{}
+	err := audit.Provision(context.Background())
+	assert.Nil(t, err)
+	audit.AddBlacklistQuery("select * from t1", sqlkit.Banned, "test")
+	sql.Register("sqlite3:audit:prepare", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, audit))
+	db, err := sql.Open("sqlite3:audit:prepare", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	_, err = db.PrepareContext(context.Background(), "select * from t1")
+	assert.Truef(t, errors.Is(err, sqlkit.ErrBanned), "should banned error")
+}
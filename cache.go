@@ -0,0 +1,253 @@
+package sqlkit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/ccmonky/pkg/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/atomic"
+)
+
+// DefaultCacheSize default max number of entries of a cache
+var DefaultCacheSize = 10000
+
+// CacheConfig config of a bounded cache
+type CacheConfig struct {
+	// Size max number of entries, the least recently used ones are evicted if exceeded,
+	// default is `DefaultCacheSize`, negative means unbounded
+	Size int `json:"size,omitempty"`
+
+	// TTL entries expire after TTL since stored, default is forever
+	TTL *utils.Duration `json:"ttl,omitempty"`
+}
+
+// Cache a bounded cache which evicts the least recently used entries, and the expired ones if TTL is set,
+// the hits, misses, evictions and size are exported as metrics labeled by the cache name.
+//
+// Usage:
+//
+//     cache := sqlkit.NewCache[string, string]("shadow_table", sqlkit.CacheConfig{Size: 1000})
+//     cache.Store("k", "v")
+//     v, ok := cache.Load("k")
+//
+type Cache[K comparable, V any] struct {
+	name string
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[K]*list.Element
+	lru     *list.List // NOTE: front is the most recently used
+
+	hits      *atomic.Int64
+	misses    *atomic.Int64
+	evictions *atomic.Int64
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key      K
+	value    V
+	expireAt time.Time // NOTE: zero means never
+}
+
+// NewCache creates a new cache, name is used as the label of metrics
+func NewCache[K comparable, V any](name string, config CacheConfig) *Cache[K, V] {
+	cacheMetrics.init.Do(func() {
+		initCacheMetrics()
+	})
+	c := &Cache[K, V]{
+		name:      name,
+		size:      config.Size,
+		entries:   make(map[K]*list.Element),
+		lru:       list.New(),
+		hits:      atomic.NewInt64(0),
+		misses:    atomic.NewInt64(0),
+		evictions: atomic.NewInt64(0),
+	}
+	if c.size == 0 {
+		c.size = DefaultCacheSize
+	}
+	if config.TTL != nil {
+		c.ttl = config.TTL.Duration
+	}
+	return c
+}
+
+// Load return the value of key if exists and not expired
+func (c *Cache[K, V]) Load(key K) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[key]
+	if ok && c.expired(elem) {
+		c.remove(elem, "expired")
+		ok = false
+	}
+	if !ok {
+		c.misses.Inc()
+		cacheMetrics.misses.WithLabelValues(c.name).Inc()
+		return
+	}
+	c.hits.Inc()
+	cacheMetrics.hits.WithLabelValues(c.name).Inc()
+	c.lru.MoveToFront(elem)
+	return elem.Value.(*cacheEntry[K, V]).value, true
+}
+
+// Store stores the value with key, if exists then override
+func (c *Cache[K, V]) Store(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(key, value)
+}
+
+// LoadOrStore return the existing value of key if exists and not expired, otherwise stores and returns the given value
+func (c *Cache[K, V]) LoadOrStore(key K, value V) (actual V, loaded bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok && !c.expired(elem) {
+		c.lru.MoveToFront(elem)
+		return elem.Value.(*cacheEntry[K, V]).value, true
+	}
+	c.store(key, value)
+	return value, false
+}
+
+// Delete deletes the key
+func (c *Cache[K, V]) Delete(key K) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem, "")
+	}
+}
+
+// Range calls f for each entry not expired from the most recently used one, stops if f returns false,
+// NOTE: f must not modify the cache
+func (c *Cache[K, V]) Range(f func(key K, value V) bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for elem := c.lru.Front(); elem != nil; elem = elem.Next() {
+		if c.expired(elem) {
+			continue
+		}
+		entry := elem.Value.(*cacheEntry[K, V])
+		if !f(entry.key, entry.value) {
+			return
+		}
+	}
+}
+
+// Clear deletes all the keys
+func (c *Cache[K, V]) Clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cacheMetrics.size.WithLabelValues(c.name).Sub(float64(len(c.entries)))
+	c.entries = make(map[K]*list.Element)
+	c.lru.Init()
+}
+
+// Len return the number of entries, including the expired ones not evicted yet
+func (c *Cache[K, V]) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+// Stats return the statistics of the cache
+func (c *Cache[K, V]) Stats() CacheStats {
+	return CacheStats{
+		Name:      c.name,
+		Size:      c.size,
+		TTL:       c.ttl,
+		Len:       c.Len(),
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Evictions: c.evictions.Load(),
+	}
+}
+
+// CacheStats statistics of a cache
+type CacheStats struct {
+	Name      string        `json:"name"`
+	Size      int           `json:"size"`
+	TTL       time.Duration `json:"ttl"`
+	Len       int           `json:"len"`
+	Hits      int64         `json:"hits"`
+	Misses    int64         `json:"misses"`
+	Evictions int64         `json:"evictions"`
+}
+
+func (c *Cache[K, V]) store(key K, value V) {
+	var expireAt time.Time
+	if c.ttl > 0 {
+		expireAt = Now().Add(c.ttl)
+	}
+	if elem, ok := c.entries[key]; ok {
+		entry := elem.Value.(*cacheEntry[K, V])
+		entry.value, entry.expireAt = value, expireAt
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.lru.PushFront(&cacheEntry[K, V]{key: key, value: value, expireAt: expireAt})
+	cacheMetrics.size.WithLabelValues(c.name).Inc()
+	for c.size > 0 && len(c.entries) > c.size {
+		c.remove(c.lru.Back(), "size")
+	}
+}
+
+// remove removes the element, reason is the label of evictions metric, empty means deleted explicitly
+func (c *Cache[K, V]) remove(elem *list.Element, reason string) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*cacheEntry[K, V]).key)
+	cacheMetrics.size.WithLabelValues(c.name).Dec()
+	if reason != "" {
+		c.evictions.Inc()
+		cacheMetrics.evictions.WithLabelValues(c.name, reason).Inc()
+	}
+}
+
+func (c *Cache[K, V]) expired(elem *list.Element) bool {
+	expireAt := elem.Value.(*cacheEntry[K, V]).expireAt
+	return !expireAt.IsZero() && Now().After(expireAt)
+}
+
+var cacheMetrics = struct {
+	init      sync.Once
+	hits      *prometheus.CounterVec
+	misses    *prometheus.CounterVec
+	evictions *prometheus.CounterVec
+	size      *prometheus.GaugeVec
+}{
+	init: sync.Once{},
+}
+
+func initCacheMetrics() {
+	const ns, sub = "sqlkit", "cache"
+	cacheMetrics.hits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "hits_total",
+		Help:      "Counter of cache hits.",
+	}, []string{"cache"})
+	cacheMetrics.misses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "misses_total",
+		Help:      "Counter of cache misses.",
+	}, []string{"cache"})
+	cacheMetrics.evictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "evictions_total",
+		Help:      "Counter of cache evictions labeled by reason(size or expired).",
+	}, []string{"cache", "reason"})
+	cacheMetrics.size = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "size",
+		Help:      "Number of cache entries.",
+	}, []string{"cache"})
+}
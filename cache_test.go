@@ -0,0 +1,96 @@
+package sqlkit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ccmonky/pkg/utils"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestCache(t *testing.T) {
+	cache := sqlkit.NewCache[string, int]("test_lru", sqlkit.CacheConfig{Size: 2})
+	cache.Store("a", 1)
+	cache.Store("b", 2)
+	v, ok := cache.Load("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+	cache.Store("c", 3) // NOTE: b is the least recently used
+	_, ok = cache.Load("b")
+	assert.False(t, ok)
+	assert.Equal(t, 2, cache.Len())
+
+	actual, loaded := cache.LoadOrStore("a", 10)
+	assert.True(t, loaded)
+	assert.Equal(t, 1, actual)
+	actual, loaded = cache.LoadOrStore("d", 4)
+	assert.False(t, loaded)
+	assert.Equal(t, 4, actual)
+
+	var keys []string
+	cache.Range(func(k string, v int) bool {
+		keys = append(keys, k)
+		return true
+	})
+	assert.Equal(t, []string{"d", "a"}, keys)
+
+	cache.Delete("a")
+	assert.Equal(t, 1, cache.Len())
+	stats := cache.Stats()
+	assert.Equal(t, 2, stats.Size)
+	assert.Equal(t, int64(1), stats.Hits)
+	assert.Equal(t, int64(1), stats.Misses)
+	assert.Equal(t, int64(2), stats.Evictions)
+
+	cache.Clear()
+	assert.Equal(t, 0, cache.Len())
+
+	unbounded := sqlkit.NewCache[int, int]("test_unbounded", sqlkit.CacheConfig{Size: -1})
+	for i := 0; i < sqlkit.DefaultCacheSize+1; i++ {
+		unbounded.Store(i, i)
+	}
+	assert.Equal(t, sqlkit.DefaultCacheSize+1, unbounded.Len())
+}
+
+func TestCacheTTL(t *testing.T) {
+	defer func(now func() time.Time) { sqlkit.Now = now }(sqlkit.Now)
+	current := time.Date(2023, 2, 1, 15, 0, 0, 0, time.Local)
+	sqlkit.Now = func() time.Time { return current }
+
+	cache := sqlkit.NewCache[string, int]("test_ttl", sqlkit.CacheConfig{TTL: &utils.Duration{Duration: time.Minute}})
+	cache.Store("a", 1)
+	current = current.Add(30 * time.Second)
+	cache.Store("b", 2)
+	_, ok := cache.Load("a")
+	assert.True(t, ok)
+
+	current = current.Add(31 * time.Second)
+	_, ok = cache.Load("a")
+	assert.False(t, ok)
+	_, ok = cache.Load("b")
+	assert.True(t, ok)
+	assert.Equal(t, int64(1), cache.Stats().Evictions)
+
+	current = current.Add(time.Minute)
+	var n int
+	cache.Range(func(string, int) bool {
+		n++
+		return true
+	})
+	assert.Equal(t, 0, n)
+}
+
+func TestShadowTableCache(t *testing.T) {
+	st := &sqlkit.ShadowTable{Suffix: "_shadow", Cache: sqlkit.CacheConfig{Size: 2}}
+	assert.Nil(t, st.Provision(context.Background()))
+	for _, query := range []string{"SELECT * FROM a", "SELECT * FROM b", "SELECT * FROM c"} {
+		_, err := st.RewriteSql(query)
+		assert.Nil(t, err)
+	}
+	sqls := st.Sqls()
+	assert.Len(t, sqls, 2)
+	assert.Equal(t, "SELECT * FROM c_shadow", sqls["SELECT * FROM c"])
+}
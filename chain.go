@@ -0,0 +1,450 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/ccmonky/errors"
+	"github.com/ccmonky/render"
+	"go.uber.org/atomic"
+)
+
+// Chain composes middlewares into one, the first middleware is the outermost one,
+// e.g. `Chain(a, b, c)` will be called as `a -> b -> c -> driver`, and returns in the reverse order.
+//
+// Usage:
+//
+//     mock := sqlkit.NewMock()
+//     chain := sqlkit.Chain(audit, sqlkit.Named("mock", mock))
+//     sql.Register("audit:mock:mysql", sqlkit.Wrap(&mysql.MySQLDriver{}, chain))
+//     chain.Disable("mock") // bypass mock at runtime
+//
+func Chain(mws ...Middleware) *MiddlewareChain {
+	chain := &MiddlewareChain{}
+	for _, mw := range mws {
+		chain.add(mw)
+	}
+	return chain
+}
+
+// WrapChain is short for `Wrap(driver, Chain(mws...))`
+func WrapChain(driver driver.Driver, mws ...Middleware) driver.Driver {
+	return Wrap(driver, Chain(mws...))
+}
+
+// MiddlewareChain an ordered list of middlewares, each of which can be enabled or disabled at runtime
+type MiddlewareChain struct {
+	entries []*chainEntry
+}
+
+type chainEntry struct {
+	name    string
+	mw      Middleware
+	enabled *atomic.Bool
+}
+
+func (chain *MiddlewareChain) add(mw Middleware) {
+	if mw == nil {
+		return
+	}
+	name := MiddlewareName(mw)
+	if nm, ok := mw.(*namedMiddleware); ok {
+		mw = nm.Middleware
+	}
+	if chain.entry(name) != nil {
+		name += "#" + strconv.Itoa(len(chain.entries))
+	}
+	chain.entries = append(chain.entries, &chainEntry{
+		name:    name,
+		mw:      mw,
+		enabled: atomic.NewBool(true),
+	})
+}
+
+func (chain *MiddlewareChain) entry(name string) *chainEntry {
+	for _, e := range chain.entries {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// Enable enable the middleware with specified name
+func (chain *MiddlewareChain) Enable(name string) error {
+	return chain.setEnabled(name, true)
+}
+
+// Disable disable the middleware with specified name, a disabled middleware will be bypassed
+func (chain *MiddlewareChain) Disable(name string) error {
+	return chain.setEnabled(name, false)
+}
+
+func (chain *MiddlewareChain) setEnabled(name string, enabled bool) error {
+	e := chain.entry(name)
+	if e == nil {
+		return errors.WithError(errors.Errorf("middleware %s not found in chain", name), errors.NotFound)
+	}
+	e.enabled.Store(enabled)
+	return nil
+}
+
+// Get return the middleware with specified name
+func (chain *MiddlewareChain) Get(name string) (Middleware, bool) {
+	e := chain.entry(name)
+	if e == nil {
+		return nil, false
+	}
+	return e.mw, true
+}
+
+// Middlewares return the installed middlewares in order for representation
+func (chain *MiddlewareChain) Middlewares() []MiddlewareStatus {
+	statuses := make([]MiddlewareStatus, 0, len(chain.entries))
+	for _, e := range chain.entries {
+		statuses = append(statuses, MiddlewareStatus{
+			Name:    e.name,
+			Type:    fmt.Sprintf("%T", e.mw),
+			Enabled: e.enabled.Load(),
+		})
+	}
+	return statuses
+}
+
+// MiddlewareStatus middleware status in chain
+type MiddlewareStatus struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Enabled bool   `json:"enabled"`
+}
+
+func (chain *MiddlewareChain) ExecContext(next ExecContext) ExecContext {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].execContext(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) QueryContext(next QueryContext) QueryContext {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].queryContext(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) BeginTx(next BeginTx) BeginTx {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].beginTx(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) Commit(next Commit) Commit {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].commit(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) Rollback(next Rollback) Rollback {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].rollback(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) PrepareContext(next PrepareContext) PrepareContext {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].prepareContext(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) CloseStmt(next CloseStmt) CloseStmt {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].closeStmt(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) OnRow(next OnRow) OnRow {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].onRow(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) OnClose(next OnClose) OnClose {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].onClose(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) OpenConn(next OpenConn) OpenConn {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].openConn(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) ResetConn(next ResetConn) ResetConn {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].resetConn(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) CloseConn(next CloseConn) CloseConn {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].closeConn(next)
+	}
+	return next
+}
+
+func (chain *MiddlewareChain) CheckNamedValue(next CheckNamedValue) CheckNamedValue {
+	for i := len(chain.entries) - 1; i >= 0; i-- {
+		next = chain.entries[i].checkNamedValue(next)
+	}
+	return next
+}
+
+// NOTE: enabled is checked on every call, so that enable/disable takes effect on prepared statements too
+func (e *chainEntry) execContext(next ExecContext) ExecContext {
+	wrapped := e.mw.ExecContext(next)
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		if !e.enabled.Load() {
+			return next(ctx, query, args)
+		}
+		return wrapped(ctx, query, args)
+	}
+}
+
+func (e *chainEntry) queryContext(next QueryContext) QueryContext {
+	wrapped := e.mw.QueryContext(next)
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		if !e.enabled.Load() {
+			return next(ctx, query, args)
+		}
+		return wrapped(ctx, query, args)
+	}
+}
+
+func (e *chainEntry) beginTx(next BeginTx) BeginTx {
+	tm, ok := e.mw.(TxMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := tm.BeginTx(next)
+	return func(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+		if !e.enabled.Load() {
+			return next(ctx, opts)
+		}
+		return wrapped(ctx, opts)
+	}
+}
+
+func (e *chainEntry) commit(next Commit) Commit {
+	tm, ok := e.mw.(TxMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := tm.Commit(next)
+	return func(ctx context.Context) error {
+		if !e.enabled.Load() {
+			return next(ctx)
+		}
+		return wrapped(ctx)
+	}
+}
+
+func (e *chainEntry) rollback(next Rollback) Rollback {
+	tm, ok := e.mw.(TxMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := tm.Rollback(next)
+	return func(ctx context.Context) error {
+		if !e.enabled.Load() {
+			return next(ctx)
+		}
+		return wrapped(ctx)
+	}
+}
+
+func (e *chainEntry) prepareContext(next PrepareContext) PrepareContext {
+	pm, ok := e.mw.(PrepareMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := pm.PrepareContext(next)
+	return func(ctx context.Context, query string) (driver.Stmt, error) {
+		if !e.enabled.Load() {
+			return next(ctx, query)
+		}
+		return wrapped(ctx, query)
+	}
+}
+
+func (e *chainEntry) closeStmt(next CloseStmt) CloseStmt {
+	pm, ok := e.mw.(PrepareMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := pm.CloseStmt(next)
+	return func(ctx context.Context, query string) error {
+		if !e.enabled.Load() {
+			return next(ctx, query)
+		}
+		return wrapped(ctx, query)
+	}
+}
+
+func (e *chainEntry) onRow(next OnRow) OnRow {
+	rm, ok := e.mw.(RowsMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := rm.OnRow(next)
+	return func(ctx context.Context, query string, columns []string, dest []driver.Value) error {
+		if !e.enabled.Load() {
+			return next(ctx, query, columns, dest)
+		}
+		return wrapped(ctx, query, columns, dest)
+	}
+}
+
+func (e *chainEntry) onClose(next OnClose) OnClose {
+	rm, ok := e.mw.(RowsMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := rm.OnClose(next)
+	return func(ctx context.Context, query string, stats RowsStats) error {
+		if !e.enabled.Load() {
+			return next(ctx, query, stats)
+		}
+		return wrapped(ctx, query, stats)
+	}
+}
+
+func (e *chainEntry) openConn(next OpenConn) OpenConn {
+	cm, ok := e.mw.(ConnMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := cm.OpenConn(next)
+	return func(ctx context.Context, stats ConnStats) (driver.Conn, error) {
+		if !e.enabled.Load() {
+			return next(ctx, stats)
+		}
+		return wrapped(ctx, stats)
+	}
+}
+
+func (e *chainEntry) resetConn(next ResetConn) ResetConn {
+	cm, ok := e.mw.(ConnMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := cm.ResetConn(next)
+	return func(ctx context.Context, conn driver.Conn, stats ConnStats) error {
+		if !e.enabled.Load() {
+			return next(ctx, conn, stats)
+		}
+		return wrapped(ctx, conn, stats)
+	}
+}
+
+func (e *chainEntry) closeConn(next CloseConn) CloseConn {
+	cm, ok := e.mw.(ConnMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := cm.CloseConn(next)
+	return func(conn driver.Conn, stats ConnStats) error {
+		if !e.enabled.Load() {
+			return next(conn, stats)
+		}
+		return wrapped(conn, stats)
+	}
+}
+
+func (e *chainEntry) checkNamedValue(next CheckNamedValue) CheckNamedValue {
+	am, ok := e.mw.(ArgsMiddleware)
+	if !ok {
+		return next
+	}
+	wrapped := am.CheckNamedValue(next)
+	return func(nv *driver.NamedValue) error {
+		if !e.enabled.Load() {
+			return next(nv)
+		}
+		return wrapped(nv)
+	}
+}
+
+// MiddlewaresAPI list middlewares in chain
+func (chain *MiddlewareChain) MiddlewaresAPI(w http.ResponseWriter, r *http.Request) {
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"middlewares": chain.Middlewares(),
+		},
+	})
+}
+
+// SetMiddlewareEnabledAPI enable or disable a middleware in chain, e.g. `?name=mock&enabled=false`
+func (chain *MiddlewareChain) SetMiddlewareEnabledAPI(w http.ResponseWriter, r *http.Request) {
+	enabled, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+		return
+	}
+	err = chain.setEnabled(r.FormValue("name"), enabled)
+	if err != nil {
+		render.R(renderName).Err(w, r, err)
+		return
+	}
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"middlewares": chain.Middlewares(),
+		},
+	})
+}
+
+// Named give a middleware a name used in chain
+func Named(name string, mw Middleware) Middleware {
+	return &namedMiddleware{
+		Middleware: mw,
+		name:       name,
+	}
+}
+
+type namedMiddleware struct {
+	Middleware
+	name string
+}
+
+func (nm *namedMiddleware) MiddlewareName() string {
+	return nm.name
+}
+
+// MiddlewareName return the name of the middleware, which is `mw.MiddlewareName()` if implemented, otherwise the type name
+func MiddlewareName(mw Middleware) string {
+	if n, ok := mw.(interface{ MiddlewareName() string }); ok {
+		return n.MiddlewareName()
+	}
+	return fmt.Sprintf("%T", mw)
+}
+
+var (
+	_ Middleware        = (*MiddlewareChain)(nil)
+	_ TxMiddleware      = (*MiddlewareChain)(nil)
+	_ PrepareMiddleware = (*MiddlewareChain)(nil)
+	_ RowsMiddleware    = (*MiddlewareChain)(nil)
+	_ ConnMiddleware    = (*MiddlewareChain)(nil)
+	_ ArgsMiddleware    = (*MiddlewareChain)(nil)
+)
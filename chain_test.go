@@ -0,0 +1,82 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+type recorder struct {
+	name  string
+	calls *[]string
+}
+
+func (r *recorder) MiddlewareName() string {
+	return r.name
+}
+
+func (r *recorder) ExecContext(next sqlkit.ExecContext) sqlkit.ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		*r.calls = append(*r.calls, r.name+":before")
+		result, err := next(ctx, query, args)
+		*r.calls = append(*r.calls, r.name+":after")
+		return result, err
+	}
+}
+
+func (r *recorder) QueryContext(next sqlkit.QueryContext) sqlkit.QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		*r.calls = append(*r.calls, r.name+":before")
+		rows, err := next(ctx, query, args)
+		*r.calls = append(*r.calls, r.name+":after")
+		return rows, err
+	}
+}
+
+func TestChain(t *testing.T) {
+	var calls []string
+	chain := sqlkit.Chain(
+		&recorder{name: "a", calls: &calls},
+		&recorder{name: "b", calls: &calls},
+		sqlkit.Named("c", &recorder{name: "c", calls: &calls}),
+	)
+	sql.Register("sqlite3:chain", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, chain))
+	db, err := sql.Open("sqlite3:chain", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"a:before", "b:before", "c:before", "c:after", "b:after", "a:after"}, calls)
+
+	calls = nil
+	assert.Nil(t, chain.Disable("b"))
+	rows, err := db.QueryContext(ctx, "SELECT id FROM t1")
+	assert.Nil(t, err)
+	rows.Close()
+	assert.Equal(t, []string{"a:before", "c:before", "c:after", "a:after"}, calls)
+
+	calls = nil
+	stmt, err := db.PrepareContext(ctx, "INSERT INTO t1 (text) VALUES(?)")
+	assert.Nil(t, err)
+	assert.Nil(t, chain.Enable("b"))
+	assert.Nil(t, chain.Disable("a"))
+	_, err = stmt.ExecContext(ctx, "foo")
+	assert.Nil(t, err)
+	stmt.Close()
+	assert.Equal(t, []string{"b:before", "c:before", "c:after", "b:after"}, calls)
+
+	assert.NotNil(t, chain.Disable("x"))
+	assert.Equal(t, []sqlkit.MiddlewareStatus{
+		{Name: "a", Type: "*sqlkit_test.recorder", Enabled: false},
+		{Name: "b", Type: "*sqlkit_test.recorder", Enabled: true},
+		{Name: "c", Type: "*sqlkit_test.recorder", Enabled: true},
+	}, chain.Middlewares())
+}
@@ -0,0 +1,164 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"go.uber.org/atomic"
+)
+
+// ConnMiddleware is an optional extension of Middleware which intercepts the connection lifecycle,
+// the conn passed to `ResetConn` and `CloseConn` and returned by `OpenConn` is the underlying driver.Conn,
+// so statements executed on it(e.g. `SET SESSION ...`) will not go through the middlewares.
+// NOTE: `ResetConn` is only called if the underlying conn implements driver.SessionResetter.
+type ConnMiddleware interface {
+	OpenConn(OpenConn) OpenConn
+	ResetConn(ResetConn) ResetConn
+	CloseConn(CloseConn) CloseConn
+}
+
+type OpenConn func(ctx context.Context, stats ConnStats) (driver.Conn, error)
+
+type ResetConn func(ctx context.Context, conn driver.Conn, stats ConnStats) error
+
+type CloseConn func(conn driver.Conn, stats ConnStats) error
+
+// ConnStats statistics of a connection
+type ConnStats struct {
+	ID         int64     `json:"id"`
+	DSN        string    `json:"dsn"` // NOTE: password is redacted
+	OpenedAt   time.Time `json:"opened_at"`
+	Queries    int64     `json:"queries"`
+	Resets     int64     `json:"resets"`
+	LastQuery  string    `json:"last_query"`
+	LastUsedAt time.Time `json:"last_used_at"`
+
+	live *connStats
+}
+
+// Refresh return the latest statistics of the same connection
+func (s ConnStats) Refresh() ConnStats {
+	if s.live == nil {
+		return s
+	}
+	return s.live.snapshot()
+}
+
+// Age return the duration since the connection opened
+func (s ConnStats) Age() time.Duration {
+	return Now().Sub(s.OpenedAt)
+}
+
+type connStats struct {
+	id         int64
+	dsn        string
+	openedAt   time.Time
+	queries    *atomic.Int64
+	resets     *atomic.Int64
+	lastQuery  *atomic.String
+	lastUsedAt *atomic.Time
+}
+
+var connID = atomic.NewInt64(0)
+
+func newConnStats(dsn string) *connStats {
+	now := Now()
+	return &connStats{
+		id:         connID.Inc(),
+		dsn:        redactDSN(dsn),
+		openedAt:   now,
+		queries:    atomic.NewInt64(0),
+		resets:     atomic.NewInt64(0),
+		lastQuery:  atomic.NewString(""),
+		lastUsedAt: atomic.NewTime(now),
+	}
+}
+
+func (s *connStats) used(query string) {
+	s.queries.Inc()
+	s.lastQuery.Store(query)
+	s.lastUsedAt.Store(Now())
+}
+
+func (s *connStats) snapshot() ConnStats {
+	return ConnStats{
+		ID:         s.id,
+		DSN:        s.dsn,
+		OpenedAt:   s.openedAt,
+		Queries:    s.queries.Load(),
+		Resets:     s.resets.Load(),
+		LastQuery:  s.lastQuery.Load(),
+		LastUsedAt: s.lastUsedAt.Load(),
+		live:       s,
+	}
+}
+
+// openConn opens a connection with ConnMiddleware if implemented, and wraps it
+func openConn(ctx context.Context, dsn string, wrapper Middleware, open func(context.Context) (driver.Conn, error)) (driver.Conn, error) {
+	var (
+		conn  driver.Conn
+		err   error
+		stats = newConnStats(dsn)
+	)
+	if cm, ok := wrapper.(ConnMiddleware); ok {
+		conn, err = cm.OpenConn(func(c context.Context, _ ConnStats) (driver.Conn, error) {
+			return open(c)
+		})(ctx, stats.snapshot())
+	} else {
+		conn, err = open(ctx)
+	}
+	if err != nil {
+		return conn, err
+	}
+	return wrapConn(conn, wrapper, stats)
+}
+
+// Stats return the statistics of the connection
+func (conn *Conn) Stats() ConnStats {
+	return conn.stats.snapshot()
+}
+
+func (conn *Conn) Close() error {
+	cm, ok := conn.wrapper.(ConnMiddleware)
+	if !ok {
+		return conn.Conn.Close()
+	}
+	return cm.CloseConn(func(c driver.Conn, _ ConnStats) error {
+		return c.Close()
+	})(conn.Conn, conn.Stats())
+}
+
+func (s *SessionResetter) ResetSession(ctx context.Context) error {
+	s.stats.resets.Inc()
+	cm, ok := s.wrapper.(ConnMiddleware)
+	if !ok {
+		return s.Conn.Conn.(driver.SessionResetter).ResetSession(ctx)
+	}
+	return cm.ResetConn(func(c context.Context, dc driver.Conn, _ ConnStats) error {
+		return dc.(driver.SessionResetter).ResetSession(c)
+	})(ctx, s.Conn.Conn, s.Stats())
+}
+
+// redactDSN replace the password in dsn with `***`, e.g. `user:password@tcp(localhost:3306)/db` -> `user:***@tcp(localhost:3306)/db`,
+// like `mysql.ParseDSN`, the user info ends at the last `@` before the last `/`, so the password may contain `@` or `/`
+func redactDSN(dsn string) string {
+	start := 0
+	if i := strings.Index(dsn, "://"); i >= 0 {
+		start = i + 3
+	}
+	rest := dsn[start:]
+	if i := strings.LastIndexByte(rest, '/'); i >= 0 {
+		rest = rest[:i]
+	}
+	at := strings.LastIndexByte(rest, '@')
+	if at < 0 {
+		return dsn
+	}
+	colon := strings.IndexByte(rest[:at], ':')
+	if colon < 0 || strings.IndexByte(rest[:colon], '/') >= 0 {
+		return dsn
+	}
+	return dsn[:start+colon+1] + "***" + dsn[start+at:]
+}
@@ -0,0 +1,227 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/ccmonky/errors"
+	"github.com/ccmonky/render"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// ConnTracker tracks open connections and exports connection churn metrics, it can also
+// execute session initialization sqls on new connections
+//
+// Usage:
+//
+//     tracker := &sqlkit.ConnTracker{
+//         Name:     "demo",
+//         InitSqls: []string{"SET SESSION sql_mode = 'STRICT_ALL_TABLES'"},
+//     }
+//     err := tracker.Provision(ctx)
+//     sql.Register("tracker:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, tracker, audit))
+//
+type ConnTracker struct {
+	// Name used as the label of metrics
+	Name string `json:"name"`
+
+	// InitSqls sqls executed on the connection after opened, e.g. `SET SESSION ...`
+	InitSqls []string `json:"init_sqls,omitempty"`
+
+	logger *zap.Logger
+	conns  *SyncMap[int64, ConnStats]
+}
+
+func (ct *ConnTracker) SetLogger(logger *zap.Logger) error {
+	if logger == nil {
+		return errors.New("nil logger")
+	}
+	ct.logger = logger
+	return nil
+}
+
+func (ct *ConnTracker) Provision(ctx context.Context) error {
+	if ct.logger == nil {
+		ct.logger = zap.NewNop()
+	}
+	if ct.conns == nil {
+		ct.conns = NewSyncMap[int64, ConnStats]()
+	}
+	connMetrics.init.Do(func() {
+		initConnMetrics()
+	})
+	return nil
+}
+
+// Conns return the latest statistics of open connections, ordered by opened time
+func (ct *ConnTracker) Conns() []ConnStats {
+	var conns []ConnStats
+	ct.conns.Range(func(_, v any) bool {
+		conns = append(conns, v.(ConnStats).Refresh())
+		return true
+	})
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].OpenedAt.Before(conns[j].OpenedAt)
+	})
+	return conns
+}
+
+func (ct *ConnTracker) ExecContext(next ExecContext) ExecContext {
+	return next
+}
+
+func (ct *ConnTracker) QueryContext(next QueryContext) QueryContext {
+	return next
+}
+
+func (ct *ConnTracker) OpenConn(next OpenConn) OpenConn {
+	return func(ctx context.Context, stats ConnStats) (driver.Conn, error) {
+		conn, err := next(ctx, stats)
+		if err != nil {
+			connMetrics.openErrors.WithLabelValues(ct.Name).Inc()
+			return conn, err
+		}
+		for _, query := range ct.InitSqls {
+			if err := execRaw(ctx, conn, query); err != nil {
+				ct.logger.Error("execute init sql failed", zap.String("query", query), zap.Error(err), zap.Int64("conn_id", stats.ID))
+				conn.Close()
+				connMetrics.openErrors.WithLabelValues(ct.Name).Inc()
+				return nil, errors.WithMessagef(err, "execute init sql failed: %s", query)
+			}
+		}
+		ct.conns.Store(stats.ID, stats)
+		connMetrics.opened.WithLabelValues(ct.Name).Inc()
+		connMetrics.open.WithLabelValues(ct.Name).Inc()
+		return conn, nil
+	}
+}
+
+func (ct *ConnTracker) ResetConn(next ResetConn) ResetConn {
+	return func(ctx context.Context, conn driver.Conn, stats ConnStats) error {
+		connMetrics.resets.WithLabelValues(ct.Name).Inc()
+		return next(ctx, conn, stats)
+	}
+}
+
+func (ct *ConnTracker) CloseConn(next CloseConn) CloseConn {
+	return func(conn driver.Conn, stats ConnStats) error {
+		if _, ok := ct.conns.LoadAndDelete(stats.ID); !ok { // NOTE: opened before tracked
+			return next(conn, stats)
+		}
+		connMetrics.closed.WithLabelValues(ct.Name).Inc()
+		connMetrics.open.WithLabelValues(ct.Name).Dec()
+		connMetrics.lifetime.WithLabelValues(ct.Name).Observe(stats.Age().Seconds())
+		connMetrics.queries.WithLabelValues(ct.Name).Observe(float64(stats.Queries))
+		return next(conn, stats)
+	}
+}
+
+// ConnsAPI list open connections
+func (ct *ConnTracker) ConnsAPI(w http.ResponseWriter, r *http.Request) {
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"app":   App,
+			"name":  ct.Name,
+			"conns": ct.Conns(),
+		},
+	})
+}
+
+// execRaw execute query on the underlying driver.Conn
+func execRaw(ctx context.Context, conn driver.Conn, query string) error {
+	switch c := conn.(type) {
+	case driver.ExecerContext:
+		_, err := c.ExecContext(ctx, query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	case driver.Execer:
+		_, err := c.Exec(query, nil)
+		if err != driver.ErrSkip {
+			return err
+		}
+	}
+	stmt, err := conn.Prepare(query)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if s, ok := stmt.(driver.StmtExecContext); ok {
+		_, err = s.ExecContext(ctx, nil)
+	} else {
+		_, err = stmt.Exec(nil)
+	}
+	return err
+}
+
+var connMetrics = struct {
+	init       sync.Once
+	opened     *prometheus.CounterVec
+	openErrors *prometheus.CounterVec
+	closed     *prometheus.CounterVec
+	resets     *prometheus.CounterVec
+	open       *prometheus.GaugeVec
+	lifetime   *prometheus.HistogramVec
+	queries    *prometheus.HistogramVec
+}{
+	init: sync.Once{},
+}
+
+func initConnMetrics() {
+	const ns, sub = "sqlkit", "conn"
+	labels := []string{"name"}
+	connMetrics.opened = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "opened_total",
+		Help:      "Counter of connections opened.",
+	}, labels)
+	connMetrics.openErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "open_errors_total",
+		Help:      "Counter of connections failed to open.",
+	}, labels)
+	connMetrics.closed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "closed_total",
+		Help:      "Counter of connections closed.",
+	}, labels)
+	connMetrics.resets = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "resets_total",
+		Help:      "Counter of connection session resets.",
+	}, labels)
+	connMetrics.open = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "open",
+		Help:      "Number of connections currently open.",
+	}, labels)
+	connMetrics.lifetime = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "lifetime_seconds",
+		Help:      "Histogram of connection lifetimes.",
+		Buckets:   []float64{1, 10, 60, 300, 600, 1800, 3600, 4 * 3600, 24 * 3600},
+	}, labels)
+	connMetrics.queries = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "queries",
+		Help:      "Histogram of queries executed on a connection during its lifetime.",
+		Buckets:   prometheus.ExponentialBuckets(1, 4, 10),
+	}, labels)
+}
+
+var (
+	_ Middleware     = (*ConnTracker)(nil)
+	_ ConnMiddleware = (*ConnTracker)(nil)
+)
@@ -0,0 +1,595 @@
+package sqlkit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/format"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/mysql"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pingcap/tidb/parser/types"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrDialectUnsupported returned by SQLiteDialect if a statement can not be translated
+var ErrDialectUnsupported = errors.New("statement not supported by sqlite dialect")
+
+// dialectRestoreFlags used to restore the translated statements, names are quoted with double quotes for sqlite
+const dialectRestoreFlags = format.RestoreKeyWordUppercase | format.RestoreStringSingleQuotes |
+	format.RestoreNameDoubleQuotes | format.RestoreStringWithoutCharset
+
+// dialectSentinel the string literal format marks where the raw sqlite text is
+const dialectSentinel = "__sqlkit_dialect_%d__"
+
+// SQLiteDialect translates MySQL statements to SQLite ones, so the same application sqls can run against
+// an in-memory SQLite in hermetic tests:
+//   - names are quoted with double quotes instead of backticks, schema qualifiers are removed
+//   - `INSERT IGNORE` => `INSERT OR IGNORE`, `UPDATE IGNORE` => `UPDATE OR IGNORE`
+//   - `ON DUPLICATE KEY UPDATE c = VALUES(c)` => `ON CONFLICT (<key>) DO UPDATE SET c = excluded.c`
+//   - `NOW()`, `CURDATE()`, `CURTIME()` and the synonyms => `CURRENT_TIMESTAMP`, `CURRENT_DATE`, `CURRENT_TIME`
+//   - `a <=> b` => `a IS b`, `IF()` => `IIF()`, `CAST(x AS SIGNED)` => `CAST(x AS INTEGER)`
+//   - index hints, locking reads and MySQL specific modifiers(e.g. `LOW_PRIORITY`, `SQL_NO_CACHE`) are removed
+//   - `CREATE TABLE` column types are mapped to SQLite affinities, `AUTO_INCREMENT` primary key => `INTEGER PRIMARY KEY AUTOINCREMENT`,
+//     inline indexes => `CREATE INDEX` statements, table options, comments and charsets are removed
+//   - `TRUNCATE TABLE t` => `DELETE FROM t`, `DROP TABLE a, b` => `DROP TABLE a; DROP TABLE b`
+//
+// The conflict target of `ON DUPLICATE KEY UPDATE` is `ConflictColumns` of the table if configured, otherwise the first key
+// (the primary key, then the unique keys) learned from the `CREATE TABLE` statements translated whose columns are all inserted,
+// since SQLite requires one.
+// Other statements are kept as is, and `ErrDialectUnsupported` is returned for the ones can not be translated,
+// e.g. `CREATE TABLE ... LIKE`.
+// NOTE: `CURRENT_TIMESTAMP` of SQLite is in UTC.
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{Rewriter: &sqlkit.SQLiteDialect{}}
+//     err := rm.Provision(ctx)
+//     sql.Register("sqlite3:mysql", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+//     db, err := sql.Open("sqlite3:mysql", ":memory:")
+//     _, err = db.ExecContext(ctx, "INSERT INTO `t` (`id`, `n`) VALUES (?, 1) ON DUPLICATE KEY UPDATE `n` = `n` + VALUES(`n`)", 1)
+//     // => INSERT INTO "t" ("id","n") VALUES (?,1) ON CONFLICT ("id") DO UPDATE SET "n"="n"+excluded."n"
+//
+type SQLiteDialect struct {
+	// ConflictColumns table -> conflict target columns of `ON DUPLICATE KEY UPDATE`
+	ConflictColumns map[string][]string `json:"conflict_columns,omitempty"`
+
+	// Cache config of the cache of translated sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser *parser.Parser
+	mu     sync.Mutex // NOTE: parser is not goroutine safe
+	cache  *Cache[string, string]
+	keys   *SyncMap[string, [][]string] // NOTE: table -> primary and unique keys learned from `CREATE TABLE`
+	logger *zap.Logger
+}
+
+func (d *SQLiteDialect) Name() string {
+	return "sqlite_dialect"
+}
+
+func (d *SQLiteDialect) Provision(ctx context.Context) error {
+	if d.logger == nil {
+		d.logger = zap.NewNop()
+	}
+	d.parser = parser.New()
+	d.cache = NewCache[string, string](d.Name(), d.Cache)
+	d.keys = NewSyncMap[string, [][]string]()
+	for table, columns := range d.ConflictColumns {
+		if len(columns) == 0 {
+			return errors.Errorf("empty conflict columns of table %s", table)
+		}
+	}
+	return nil
+}
+
+func (d *SQLiteDialect) SetLogger(logger *zap.Logger) {
+	d.logger = logger
+}
+
+func (d *SQLiteDialect) Rewrite(sql string, args []any) (string, []any, error) {
+	sql, err := d.RewriteSql(sql)
+	return sql, args, err
+}
+
+func (d *SQLiteDialect) RewriteSql(sql string) (string, error) {
+	if result, ok := d.cache.Load(sql); ok {
+		return result, nil
+	}
+	d.mu.Lock()
+	stmtNodes, warns, err := d.parser.Parse(sql, "", "")
+	d.mu.Unlock()
+	if err != nil {
+		return "", errors.WithMessagef(err, "parse sql failed: %s", sql)
+	}
+	if len(warns) > 0 {
+		d.logger.Debug("sqlite dialect warnings", zap.Any("warns", warns), zap.String("sql", sql))
+	}
+	if len(stmtNodes) == 0 {
+		return sql, nil
+	}
+	results := make([]string, 0, len(stmtNodes))
+	for _, stmtNode := range stmtNodes {
+		result, err := d.translate(stmtNode)
+		if err != nil {
+			return "", errors.WithMessagef(err, "sql: %s", sql)
+		}
+		results = append(results, result)
+	}
+	result := strings.Join(results, "; ")
+	d.cache.Store(sql, result)
+	return result, nil
+}
+
+// translate translates a single statement, the unsupported statements are kept as is
+func (d *SQLiteDialect) translate(stmtNode ast.StmtNode) (string, error) {
+	v := &dialectVisitor{}
+	switch n := stmtNode.(type) {
+	case *ast.SelectStmt, *ast.SetOprStmt:
+		return v.translate(n)
+	case *ast.InsertStmt:
+		return d.insert(v, n)
+	case *ast.UpdateStmt:
+		ignore := n.IgnoreErr
+		n.IgnoreErr, n.Priority = false, mysql.NoPriority
+		s, err := v.translate(n)
+		if err != nil || !ignore {
+			return s, err
+		}
+		return "UPDATE OR IGNORE" + strings.TrimPrefix(s, "UPDATE"), nil
+	case *ast.DeleteStmt:
+		n.IgnoreErr, n.Quick, n.Priority = false, false, mysql.NoPriority
+		return v.translate(n)
+	case *ast.CreateTableStmt:
+		return d.createTable(v, n)
+	case *ast.CreateIndexStmt:
+		n.IndexOption, n.LockAlg = nil, nil
+		return v.translate(n)
+	case *ast.DropTableStmt:
+		stmts := make([]string, 0, len(n.Tables))
+		for _, tn := range n.Tables {
+			s := "DROP TABLE "
+			if n.IsView {
+				s = "DROP VIEW "
+			}
+			if n.IfExists {
+				s += "IF EXISTS "
+			}
+			stmts = append(stmts, s+quoteName(tn.Name.O))
+		}
+		return strings.Join(stmts, "; "), nil
+	case *ast.TruncateTableStmt:
+		return "DELETE FROM " + quoteName(n.Table.Name.O), nil
+	case *ast.BeginStmt:
+		return "BEGIN", nil
+	case *ast.CommitStmt:
+		return "COMMIT", nil
+	case *ast.RollbackStmt:
+		return "ROLLBACK", nil
+	default:
+		return strings.TrimSuffix(strings.TrimSpace(stmtNode.Text()), ";"), nil
+	}
+}
+
+func (d *SQLiteDialect) insert(v *dialectVisitor, n *ast.InsertStmt) (string, error) {
+	ignore := n.IgnoreErr && !n.IsReplace
+	onDuplicate := n.OnDuplicate
+	n.IgnoreErr, n.Priority, n.OnDuplicate = false, mysql.NoPriority, nil
+	if len(onDuplicate) > 0 && n.Select != nil {
+		sel, ok := n.Select.(*ast.SelectStmt)
+		if !ok {
+			return "", errors.WithMessage(ErrDialectUnsupported, "ON DUPLICATE KEY UPDATE with set operations")
+		}
+		if sel.Where == nil { // NOTE: sqlite requires WHERE to resolve the parsing ambiguity of upsert with SELECT
+			sel.Where = v.sentinel("true")
+		}
+	}
+	s, err := v.restore(n)
+	if err != nil {
+		return "", err
+	}
+	if ignore {
+		s = "INSERT OR IGNORE" + strings.TrimPrefix(s, "INSERT")
+	}
+	if len(onDuplicate) > 0 {
+		tn, ok := n.Table.TableRefs.Left.(*ast.TableSource).Source.(*ast.TableName)
+		if !ok {
+			return "", errors.WithMessage(ErrDialectUnsupported, "INSERT without table name")
+		}
+		columns, err := d.conflictColumns(tn.Name.O, insertColumns(n))
+		if err != nil {
+			return "", err
+		}
+		sets := make([]string, 0, len(onDuplicate))
+		for _, a := range onDuplicate {
+			node, ok := a.Expr.Accept(v)
+			if !ok {
+				return "", errors.New("accept failed")
+			}
+			expr, err := v.restore(node)
+			if err != nil {
+				return "", err
+			}
+			sets = append(sets, quoteName(a.Column.Name.O)+"="+expr)
+		}
+		s += " ON CONFLICT (" + quoteNames(columns) + ") DO UPDATE SET " + strings.Join(sets, ",")
+	}
+	return v.expand(s), nil
+}
+
+// conflictColumns return the conflict target of the table, which is the first key whose columns are all inserted,
+// NOTE: all the columns are inserted if inserted is empty, e.g. `INSERT INTO t VALUES (...)`
+func (d *SQLiteDialect) conflictColumns(table string, inserted map[string]struct{}) ([]string, error) {
+	for t, columns := range d.ConflictColumns {
+		if strings.EqualFold(t, table) {
+			return columns, nil
+		}
+	}
+	keys, _ := d.keys.Load(strings.ToLower(table))
+	if len(keys) > 0 && len(inserted) == 0 {
+		return keys[0], nil
+	}
+	for _, columns := range keys {
+		covered := true
+		for _, column := range columns {
+			if _, ok := inserted[strings.ToLower(column)]; !ok {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return columns, nil
+		}
+	}
+	return nil, errors.WithMessagef(ErrDialectUnsupported, "conflict columns of table %s unknown", table)
+}
+
+// insertColumns return the lower-cased columns inserted
+func insertColumns(n *ast.InsertStmt) map[string]struct{} {
+	columns := make(map[string]struct{}, len(n.Columns)+len(n.Setlist))
+	for _, c := range n.Columns {
+		columns[c.Name.L] = struct{}{}
+	}
+	for _, a := range n.Setlist {
+		columns[a.Column.Name.L] = struct{}{}
+	}
+	return columns
+}
+
+func (d *SQLiteDialect) createTable(v *dialectVisitor, n *ast.CreateTableStmt) (string, error) {
+	if n.ReferTable != nil || n.Select != nil {
+		return "", errors.WithMessage(ErrDialectUnsupported, "CREATE TABLE ... LIKE or SELECT")
+	}
+	var (
+		table      = n.Table.Name.O
+		defs       []string
+		indexes    []string
+		primary    []string
+		uniques    [][]string
+		inlinePk   string // NOTE: the primary key column which is inlined as rowid alias if auto increment
+		inlined    bool
+		ifNotExist string
+	)
+	if n.IfNotExists {
+		ifNotExist = "IF NOT EXISTS "
+	}
+	for _, c := range n.Constraints {
+		if c.Tp == ast.ConstraintPrimaryKey && len(c.Keys) == 1 && c.Keys[0].Column != nil {
+			inlinePk = c.Keys[0].Column.Name.L
+		}
+	}
+	for _, col := range n.Cols {
+		def, pk, unique, rowid, err := d.columnDef(v, col, inlinePk)
+		if err != nil {
+			return "", err
+		}
+		inlined = inlined || (rowid && col.Name.Name.L == inlinePk)
+		if pk {
+			primary = []string{col.Name.Name.O}
+		}
+		if unique {
+			uniques = append(uniques, []string{col.Name.Name.O})
+		}
+		defs = append(defs, def)
+	}
+	for _, c := range n.Constraints {
+		columns, err := indexColumns(c.Keys)
+		if err != nil {
+			return "", err
+		}
+		switch c.Tp {
+		case ast.ConstraintPrimaryKey:
+			primary = columns
+			if inlined {
+				continue
+			}
+			defs = append(defs, "PRIMARY KEY ("+quoteNames(columns)+")")
+		case ast.ConstraintUniq, ast.ConstraintUniqKey, ast.ConstraintUniqIndex:
+			uniques = append(uniques, columns)
+			defs = append(defs, "UNIQUE ("+quoteNames(columns)+")")
+		case ast.ConstraintKey, ast.ConstraintIndex:
+			name := c.Name
+			if name == "" {
+				name = strings.Join(columns, "_")
+			}
+			indexes = append(indexes, "CREATE INDEX "+ifNotExist+quoteName(table+"_"+name)+" ON "+quoteName(table)+" ("+quoteNames(columns)+")")
+		case ast.ConstraintForeignKey:
+			refColumns, err := indexColumns(c.Refer.IndexPartSpecifications)
+			if err != nil {
+				return "", err
+			}
+			def := "FOREIGN KEY (" + quoteNames(columns) + ") REFERENCES " + quoteName(c.Refer.Table.Name.O) + " (" + quoteNames(refColumns) + ")"
+			if c.Refer.OnDelete != nil && c.Refer.OnDelete.ReferOpt != ast.ReferOptionNoOption {
+				def += " ON DELETE " + c.Refer.OnDelete.ReferOpt.String()
+			}
+			if c.Refer.OnUpdate != nil && c.Refer.OnUpdate.ReferOpt != ast.ReferOptionNoOption {
+				def += " ON UPDATE " + c.Refer.OnUpdate.ReferOpt.String()
+			}
+			defs = append(defs, def)
+		}
+	}
+	if len(primary) > 0 {
+		uniques = append([][]string{primary}, uniques...)
+	}
+	d.keys.Store(strings.ToLower(table), uniques)
+	d.cache.Clear() // NOTE: the conflict targets may be changed
+	create := "CREATE TABLE "
+	if n.TemporaryKeyword != ast.TemporaryNone {
+		create = "CREATE TEMP TABLE "
+	}
+	stmts := append([]string{create + ifNotExist + quoteName(table) + " (" + strings.Join(defs, ", ") + ")"}, indexes...)
+	return v.expand(strings.Join(stmts, "; ")), nil
+}
+
+// columnDef return the column definition, whether it is the primary key or unique,
+// and whether it is inlined as `INTEGER PRIMARY KEY AUTOINCREMENT`, i.e. the alias of rowid
+func (d *SQLiteDialect) columnDef(v *dialectVisitor, col *ast.ColumnDef, inlinePk string) (def string, pk, unique, rowid bool, err error) {
+	var (
+		name    = col.Name.Name.O
+		typ     = sqliteType(col.Tp)
+		autoInc bool
+		parts   []string
+	)
+	for _, opt := range col.Options {
+		switch opt.Tp {
+		case ast.ColumnOptionPrimaryKey:
+			pk = true
+		case ast.ColumnOptionAutoIncrement:
+			autoInc = true
+		case ast.ColumnOptionNotNull:
+			parts = append(parts, "NOT NULL")
+		case ast.ColumnOptionNull:
+			parts = append(parts, "NULL")
+		case ast.ColumnOptionUniqKey:
+			unique = true
+			parts = append(parts, "UNIQUE")
+		case ast.ColumnOptionDefaultValue:
+			node, ok := opt.Expr.Accept(v)
+			if !ok {
+				return "", false, false, false, errors.New("accept failed")
+			}
+			value, err := v.restore(node)
+			if err != nil {
+				return "", false, false, false, err
+			}
+			if _, ok := node.(ast.ValueExpr); !ok {
+				value = "(" + value + ")"
+			}
+			parts = append(parts, "DEFAULT "+value)
+		}
+	}
+	head := quoteName(name) + " " + typ
+	rowid = autoInc && typ == "INTEGER" && (pk || col.Name.Name.L == inlinePk)
+	switch {
+	case rowid:
+		head += " PRIMARY KEY AUTOINCREMENT"
+	case pk:
+		head += " PRIMARY KEY"
+	}
+	return strings.Join(append([]string{head}, parts...), " "), pk, unique, rowid, nil
+}
+
+// indexColumns return the column names of the index, expression indexes are not supported
+func indexColumns(keys []*ast.IndexPartSpecification) ([]string, error) {
+	columns := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.Column == nil {
+			return nil, errors.WithMessage(ErrDialectUnsupported, "expression index")
+		}
+		columns = append(columns, key.Column.Name.O)
+	}
+	return columns, nil
+}
+
+// sqliteType return the sqlite column type of the MySQL one, date and time types are kept for go-sqlite3 to scan time.Time
+func sqliteType(tp *types.FieldType) string {
+	binary := tp.Charset == "binary" || mysql.HasBinaryFlag(tp.Flag)
+	switch tp.Tp {
+	case mysql.TypeTiny, mysql.TypeShort, mysql.TypeInt24, mysql.TypeLong, mysql.TypeLonglong, mysql.TypeYear, mysql.TypeBit:
+		return "INTEGER"
+	case mysql.TypeFloat, mysql.TypeDouble:
+		return "REAL"
+	case mysql.TypeNewDecimal:
+		return "NUMERIC"
+	case mysql.TypeDate:
+		return "DATE"
+	case mysql.TypeDatetime:
+		return "DATETIME"
+	case mysql.TypeTimestamp:
+		return "TIMESTAMP"
+	case mysql.TypeVarchar, mysql.TypeString, mysql.TypeVarString,
+		mysql.TypeTinyBlob, mysql.TypeMediumBlob, mysql.TypeLongBlob, mysql.TypeBlob:
+		if binary {
+			return "BLOB"
+		}
+		return "TEXT"
+	default:
+		return "TEXT"
+	}
+}
+
+// castType return the sqlite type of `CAST`, NOTE: date and time types are casted to TEXT to keep the formats
+func castType(tp *types.FieldType) string {
+	switch typ := sqliteType(tp); typ {
+	case "DATE", "DATETIME", "TIMESTAMP":
+		return "TEXT"
+	default:
+		return typ
+	}
+}
+
+func quoteName(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func quoteNames(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = quoteName(name)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// dialectVisitor translates the expressions, the sqlite texts which can not be restored by the MySQL AST are replaced
+// with sentinels, which are expanded after restored
+type dialectVisitor struct {
+	raws []string
+	err  error
+}
+
+func (v *dialectVisitor) Enter(in ast.Node) (ast.Node, bool) {
+	return in, v.err != nil
+}
+
+func (v *dialectVisitor) Leave(in ast.Node) (ast.Node, bool) {
+	if v.err != nil {
+		return in, false
+	}
+	switch n := in.(type) {
+	case ast.ValueExpr:
+		// NOTE: backslashes of string literals are escaped by restore, which is not an escape character of sqlite
+		if s, ok := n.GetValue().(string); ok && strings.Contains(s, `\`) {
+			return v.sentinel("'" + strings.ReplaceAll(s, "'", "''") + "'"), true
+		}
+	case *ast.TableName:
+		n.Schema, n.IndexHints, n.PartitionNames = model.CIStr{}, nil, nil
+	case *ast.ColumnName:
+		n.Schema = model.CIStr{}
+	case *ast.SelectStmt:
+		n.LockInfo = nil
+		if n.SelectStmtOpts != nil {
+			n.SelectStmtOpts = &ast.SelectStmtOpts{Distinct: n.SelectStmtOpts.Distinct, SQLCache: true}
+		}
+	case *ast.FuncCallExpr:
+		switch n.FnName.L {
+		case ast.Now, ast.CurrentTimestamp, ast.Sysdate, ast.LocalTime, ast.LocalTimestamp, ast.UTCTimestamp:
+			return v.sentinel("CURRENT_TIMESTAMP"), true
+		case ast.Curdate, ast.CurrentDate, ast.UTCDate:
+			return v.sentinel("CURRENT_DATE"), true
+		case ast.Curtime, ast.CurrentTime, ast.UTCTime:
+			return v.sentinel("CURRENT_TIME"), true
+		case ast.If:
+			n.FnName = model.NewCIStr("IIF")
+		}
+	case *ast.ValuesExpr:
+		return v.sentinel("excluded." + quoteName(n.Column.Name.Name.O)), true
+	case *ast.BinaryOperationExpr:
+		if n.Op == opcode.NullEQ {
+			l, r := v.mustRestore(n.L), v.mustRestore(n.R)
+			return v.sentinel("(" + l + " IS " + r + ")"), v.err == nil
+		}
+	case *ast.FuncCastExpr:
+		expr := v.mustRestore(n.Expr)
+		if n.FunctionType == ast.CastBinaryOperator {
+			return v.sentinel(expr), v.err == nil
+		}
+		return v.sentinel("CAST(" + expr + " AS " + castType(n.Tp) + ")"), v.err == nil
+	}
+	return in, v.err == nil
+}
+
+// sentinel return a sentinel literal which is expanded to the raw text
+func (v *dialectVisitor) sentinel(raw string) ast.ExprNode {
+	v.raws = append(v.raws, raw)
+	return ast.NewValueExpr(fmt.Sprintf(dialectSentinel, len(v.raws)-1), "", "")
+}
+
+// translate visits and restores the statement, then expands the sentinels
+func (v *dialectVisitor) translate(node ast.Node) (string, error) {
+	s, err := v.restore(node)
+	if err != nil {
+		return "", err
+	}
+	return v.expand(s), nil
+}
+
+// restore visits and restores the node, the sentinels are not expanded
+func (v *dialectVisitor) restore(node ast.Node) (string, error) {
+	node, ok := node.Accept(v)
+	if v.err != nil {
+		return "", v.err
+	}
+	if !ok {
+		return "", errors.New("accept failed")
+	}
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(dialectRestoreFlags, &sb)); err != nil {
+		return "", errors.WithMessage(err, "restore failed")
+	}
+	return sb.String(), nil
+}
+
+// mustRestore restores the visited node, the error is recorded
+func (v *dialectVisitor) mustRestore(node ast.Node) string {
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(dialectRestoreFlags, &sb)); err != nil && v.err == nil {
+		v.err = errors.WithMessage(err, "restore failed")
+	}
+	return sb.String()
+}
+
+// expand replaces the sentinels with the raw texts recursively
+func (v *dialectVisitor) expand(restored string) string {
+	if len(v.raws) == 0 {
+		return restored
+	}
+	var (
+		sb   strings.Builder
+		last int
+	)
+	scanSql(restored, func(kind byte, start, end int) {
+		if kind != '\'' {
+			return
+		}
+		i, ok := dialectSentinelIndex(restored[start:end])
+		if !ok || i >= len(v.raws) {
+			return
+		}
+		sb.WriteString(restored[last:start])
+		sb.WriteString(v.expand(v.raws[i]))
+		last = end
+	})
+	sb.WriteString(restored[last:])
+	return sb.String()
+}
+
+// dialectSentinelIndex return the index of the sentinel literal, e.g. `'__sqlkit_dialect_1__'` => 1
+func dialectSentinelIndex(literal string) (int, bool) {
+	const prefix, suffix = "'__sqlkit_dialect_", "__'"
+	if !strings.HasPrefix(literal, prefix) || !strings.HasSuffix(literal, suffix) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(literal[len(prefix) : len(literal)-len(suffix)])
+	return i, err == nil
+}
+
+var (
+	_ SqlRewriter       = (*SQLiteDialect)(nil)
+	_ RewriterInterface = (*SQLiteDialect)(nil)
+)
@@ -0,0 +1,139 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestSQLiteDialect(t *testing.T) {
+	d := &sqlkit.SQLiteDialect{ConflictColumns: map[string][]string{"kv": {"k"}}}
+	assert.Nil(t, d.Provision(context.Background()))
+	var cases = []struct {
+		sql    string
+		result string
+	}{
+		{
+			sql: "CREATE TABLE IF NOT EXISTS `users` (`id` BIGINT UNSIGNED NOT NULL AUTO_INCREMENT, `name` VARCHAR(64) NOT NULL DEFAULT '' COMMENT 'name', " +
+				"`email` varchar(128) CHARACTER SET utf8mb4 DEFAULT NULL, `score` DECIMAL(10,2) DEFAULT 0.00, `data` BLOB, " +
+				"`created_at` DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, " +
+				"PRIMARY KEY (`id`), UNIQUE KEY `uk_email` (`email`), KEY `idx_name` (`name`(10))) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4",
+			result: `CREATE TABLE IF NOT EXISTS "users" ("id" INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL, "name" TEXT NOT NULL DEFAULT '', ` +
+				`"email" TEXT DEFAULT NULL, "score" NUMERIC DEFAULT 0.00, "data" BLOB, "created_at" DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP, ` +
+				`UNIQUE ("email")); CREATE INDEX IF NOT EXISTS "users_idx_name" ON "users" ("name")`,
+		},
+		{
+			sql:    "SELECT `u`.`id`, `name` FROM `db`.`users` AS `u` USE INDEX (idx_name) WHERE `u`.`name` LIKE 'a%' AND created_at < NOW() LIMIT 10, 20 FOR UPDATE",
+			result: `SELECT "u"."id","name" FROM "users" AS "u" WHERE "u"."name" LIKE 'a%' AND "created_at"<CURRENT_TIMESTAMP LIMIT 10,20`,
+		},
+		{
+			sql:    "SELECT SQL_NO_CACHE DISTINCT IF(a <=> NULL, 'x', 'y'), CAST(b AS SIGNED), CURDATE() FROM t LIMIT 5",
+			result: `SELECT DISTINCT IIF(("a" IS NULL), 'x', 'y'),CAST("b" AS INTEGER),CURRENT_DATE FROM "t" LIMIT 5`,
+		},
+		{
+			sql:    "INSERT IGNORE INTO `users` (`name`, `email`) VALUES (?, ?)",
+			result: `INSERT OR IGNORE INTO "users" ("name","email") VALUES (?,?)`,
+		},
+		{
+			sql:    "INSERT INTO users (name, email) VALUES (?, ?) ON DUPLICATE KEY UPDATE name = VALUES(name), created_at = NOW()",
+			result: `INSERT INTO "users" ("name","email") VALUES (?,?) ON CONFLICT ("email") DO UPDATE SET "name"=excluded."name","created_at"=CURRENT_TIMESTAMP`,
+		},
+		{
+			sql:    "INSERT INTO kv (k, v) SELECT k, v FROM kv2 ON DUPLICATE KEY UPDATE v = v + VALUES(v)",
+			result: `INSERT INTO "kv" ("k","v") SELECT "k","v" FROM "kv2" WHERE true ON CONFLICT ("k") DO UPDATE SET "v"="v"+excluded."v"`,
+		},
+		{
+			sql:    `UPDATE LOW_PRIORITY IGNORE users SET name = 'it''s \\ x' WHERE id = ?`,
+			result: `UPDATE OR IGNORE "users" SET "name"='it''s \ x' WHERE "id"=?`,
+		},
+		{
+			sql:    "DELETE QUICK FROM users WHERE id IN (1, 2)",
+			result: `DELETE FROM "users" WHERE "id" IN (1,2)`,
+		},
+		{
+			sql:    "TRUNCATE TABLE users",
+			result: `DELETE FROM "users"`,
+		},
+		{
+			sql:    "DROP TABLE IF EXISTS a, b",
+			result: `DROP TABLE IF EXISTS "a"; DROP TABLE IF EXISTS "b"`,
+		},
+		{
+			sql:    "SET NAMES utf8mb4",
+			result: "SET NAMES utf8mb4",
+		},
+	}
+	for _, c := range cases {
+		result, err := d.RewriteSql(c.sql)
+		assert.Nilf(t, err, c.sql)
+		assert.Equalf(t, c.result, result, c.sql)
+	}
+
+	for _, sql := range []string{
+		"CREATE TABLE t2 LIKE t",
+		"INSERT INTO unknown (a) VALUES (1) ON DUPLICATE KEY UPDATE a = 2",
+	} {
+		_, err := d.RewriteSql(sql)
+		assert.Truef(t, errors.Is(err, sqlkit.ErrDialectUnsupported), sql)
+	}
+}
+
+func TestSQLiteDialectMiddleware(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.SQLiteDialect{},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:dialect", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:dialect", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE `counters` (`id` INT(11) NOT NULL AUTO_INCREMENT, `name` VARCHAR(32) NOT NULL, "+
+		"`n` INT NOT NULL DEFAULT 0, `updated_at` DATETIME DEFAULT NULL, PRIMARY KEY (`id`), UNIQUE KEY `uk_name` (`name`), KEY `idx_n` (`n`)) ENGINE=InnoDB")
+	assert.Nil(t, err)
+	for i := 0; i < 3; i++ {
+		_, err = db.ExecContext(ctx, "INSERT INTO `counters` (`name`, `n`, `updated_at`) VALUES (?, 1, NOW()) "+
+			"ON DUPLICATE KEY UPDATE `n` = `n` + VALUES(`n`), `updated_at` = NOW()", "a")
+		assert.Nil(t, err)
+	}
+	res, err := db.ExecContext(ctx, "INSERT IGNORE INTO `counters` (`name`) VALUES ('a'), ('b')")
+	assert.Nil(t, err)
+	affected, _ := res.RowsAffected()
+	assert.Equal(t, int64(1), affected)
+
+	var (
+		names []string
+		n     int
+	)
+	rows, err := db.QueryContext(ctx, "SELECT `name`, `n` FROM `counters` WHERE `updated_at` IS NOT NULL OR `n` = 0 ORDER BY `id` LIMIT 0, 10")
+	assert.Nil(t, err)
+	for rows.Next() {
+		var name string
+		assert.Nil(t, rows.Scan(&name, &n))
+		names = append(names, name)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT `n` FROM `counters` WHERE `name` = ?", "a").Scan(&n))
+	assert.Equal(t, 3, n)
+	for _, name := range []string{"c", "d", "e", "f"} {
+		_, err = db.ExecContext(ctx, "INSERT INTO `counters` (`name`) VALUES (?)", name)
+		assert.Nil(t, err)
+	}
+	var name string
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT `name` FROM `counters` ORDER BY `id` LIMIT ?, ?", 3, 1).Scan(&name))
+	assert.Equal(t, "d", name) // NOTE: offset 3, count 1
+
+	_, err = db.ExecContext(ctx, "TRUNCATE TABLE `counters`")
+	assert.Nil(t, err)
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM `counters`").Scan(&n))
+	assert.Equal(t, 0, n)
+}
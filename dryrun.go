@@ -0,0 +1,152 @@
+package sqlkit
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/ccmonky/errors"
+	"github.com/ccmonky/render"
+	"go.uber.org/zap"
+)
+
+// DryRunRecord the rewrites, errors and timings of a query rewritten by RewriteMiddleware in dry-run mode
+type DryRunRecord struct {
+	Rewriter      string        `json:"rewriter"`
+	OriginalQuery string        `json:"original_query"`
+	Query         string        `json:"query,omitempty"` // NOTE: the last rewritten query which differs from the original
+	Args          []any         `json:"args,omitempty"`
+	Error         string        `json:"error,omitempty"` // NOTE: the last rewrite error
+	Count         int64         `json:"count"`
+	Diffs         int64         `json:"diffs"`
+	Errors        int64         `json:"errors"`
+	TotalDuration time.Duration `json:"total_duration"`
+	MaxDuration   time.Duration `json:"max_duration"`
+	FirstSeenAt   time.Time     `json:"first_seen_at"`
+	LastSeenAt    time.Time     `json:"last_seen_at"`
+}
+
+// Discrepant reports whether the query is rewritten or failed to rewrite
+func (record *DryRunRecord) Discrepant() bool {
+	return record.Diffs > 0 || record.Errors > 0
+}
+
+// IsDryRun reports whether the middleware is in dry-run mode
+func (rm *RewriteMiddleware) IsDryRun() bool {
+	return rm.dryRun.Load()
+}
+
+// SetDryRun turns dry-run mode on or off at runtime, the records are kept
+func (rm *RewriteMiddleware) SetDryRun(dryRun bool) {
+	rm.dryRun.Store(dryRun)
+	if rm.logger != nil {
+		rm.logger.Info("rewrite dry run changed", zap.Bool("dry_run", dryRun))
+	}
+}
+
+// DryRunRecords return a snapshot of the dry-run records from the most recently seen one
+func (rm *RewriteMiddleware) DryRunRecords() []DryRunRecord {
+	rm.dryRunMu.Lock()
+	defer rm.dryRunMu.Unlock()
+	var records []DryRunRecord
+	rm.dryRunRecords.Range(func(query string, record *DryRunRecord) bool {
+		records = append(records, *record)
+		return true
+	})
+	return records
+}
+
+// ClearDryRunRecords clear the dry-run records
+func (rm *RewriteMiddleware) ClearDryRunRecords() {
+	rm.dryRunMu.Lock()
+	defer rm.dryRunMu.Unlock()
+	rm.dryRunRecords.Clear()
+}
+
+func (rm *RewriteMiddleware) recordDryRun(rewriter, query string, originalArgs []any, rewritten string, rewrittenArgs []any, err error, dur time.Duration) {
+	rm.dryRunMu.Lock()
+	defer rm.dryRunMu.Unlock()
+	now := Now()
+	record, ok := rm.dryRunRecords.Load(query)
+	if !ok {
+		record = &DryRunRecord{
+			Rewriter:      rewriter,
+			OriginalQuery: query,
+			FirstSeenAt:   now,
+		}
+		rm.dryRunRecords.Store(query, record)
+	}
+	record.Count++
+	record.TotalDuration += dur
+	if dur > record.MaxDuration {
+		record.MaxDuration = dur
+	}
+	record.LastSeenAt = now
+	if err != nil {
+		record.Errors++
+		record.Error = err.Error()
+		rm.logger.Warn("dry run rewrite failed", zap.String("rewriter", rewriter), zap.String("query", query), zap.Error(err))
+		return
+	}
+	if rewritten != query || !reflect.DeepEqual(originalArgs, rewrittenArgs) {
+		record.Diffs++
+		record.Query = rewritten
+		record.Args = rewrittenArgs
+	}
+}
+
+// DryRunAPI list dry-run records, only the discrepant ones if `?discrepant=true`
+func (rm *RewriteMiddleware) DryRunAPI(w http.ResponseWriter, r *http.Request) {
+	var discrepant bool
+	if s := r.FormValue("discrepant"); s != "" {
+		var err error
+		discrepant, err = strconv.ParseBool(s)
+		if err != nil {
+			render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+			return
+		}
+	}
+	records := rm.DryRunRecords()
+	if discrepant {
+		filtered := records[:0]
+		for i := range records {
+			if records[i].Discrepant() {
+				filtered = append(filtered, records[i])
+			}
+		}
+		records = filtered
+	}
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"rewriter": rm.CurrentRewriter().Name(),
+			"dry_run":  rm.IsDryRun(),
+			"records":  records,
+		},
+	})
+}
+
+// SetDryRunAPI turns dry-run mode on or off, e.g. `?dry_run=false`, and clear the records if `&clear=true`
+func (rm *RewriteMiddleware) SetDryRunAPI(w http.ResponseWriter, r *http.Request) {
+	dryRun, err := strconv.ParseBool(r.FormValue("dry_run"))
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+		return
+	}
+	if s := r.FormValue("clear"); s != "" {
+		ok, err := strconv.ParseBool(s)
+		if err != nil {
+			render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+			return
+		}
+		if ok {
+			rm.ClearDryRunRecords()
+		}
+	}
+	rm.SetDryRun(dryRun)
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"dry_run": rm.IsDryRun(),
+		},
+	})
+}
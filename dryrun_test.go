@@ -0,0 +1,87 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestRewriteDryRun(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.Rewrite{
+			GlobalRewriter: &sqlkit.Rewriter{
+				SqlRewriters: []sqlkit.SqlRewriter{&sqlkit.ShadowTable{Suffix: "_shadow", Tables: []string{"t"}}},
+			},
+		},
+		DryRun: true,
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:dryrun", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:dryrun", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "CREATE TABLE d (id INTEGER)")
+	assert.Nil(t, err)
+	for i := 0; i < 2; i++ {
+		_, err = db.ExecContext(ctx, "INSERT INTO t (id) VALUES (?)", i)
+		assert.Nil(t, err)
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO d (id) VALUES (1)") // NOTE: refused by shadow table, but executed
+	assert.Nil(t, err)
+	var count int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	records := make(map[string]sqlkit.DryRunRecord)
+	for _, record := range rm.DryRunRecords() {
+		records[record.OriginalQuery] = record
+	}
+	record := records["INSERT INTO t (id) VALUES (?)"]
+	assert.Equal(t, int64(2), record.Count)
+	assert.Equal(t, int64(2), record.Diffs)
+	assert.Equal(t, "INSERT INTO t_shadow (id) VALUES (?)", record.Query)
+	assert.True(t, record.MaxDuration > 0)
+	record = records["INSERT INTO d (id) VALUES (1)"]
+	assert.Equal(t, int64(1), record.Errors)
+	assert.NotEmpty(t, record.Error)
+	record = records["SELECT COUNT(*) FROM t"]
+	assert.Equal(t, "SELECT COUNT(1) FROM t_shadow", record.Query)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/dryrun?discrepant=true", nil)
+	rm.DryRunAPI(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data struct {
+			Data struct {
+				DryRun  bool                  `json:"dry_run"`
+				Records []sqlkit.DryRunRecord `json:"records"`
+			} `json:"data"`
+		} `json:"data"`
+	}
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Data.Data.DryRun)
+	assert.Len(t, resp.Data.Data.Records, len(records))
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPost, "/dryrun?dry_run=false&clear=true", nil)
+	rm.SetDryRunAPI(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.False(t, rm.IsDryRun())
+	assert.Len(t, rm.DryRunRecords(), 0)
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id) VALUES (?)", 3)
+	assert.NotNil(t, err) // NOTE: t_shadow not exists
+}
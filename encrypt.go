@@ -0,0 +1,760 @@
+package sqlkit
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrEncryptionUnsupported returned by ColumnEncryption if the query uses encrypted columns in a way which can not be encrypted,
+// e.g. literals compared with encrypted columns, LIKE or range conditions on encrypted columns
+var ErrEncryptionUnsupported = errors.New("query unsupported on encrypted columns")
+
+// encryptedPrefix the prefix of encrypted values, the format is `enc:<key id>:<base64 of nonce and cipher text>`
+const encryptedPrefix = "enc:"
+
+// KeyProvider provides the encryption keys by key id, it is provisioned by ColumnEncryption if it implements `Provision(context.Context) error`
+type KeyProvider interface {
+	Key(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// LocalKeyProvider loads keys from a local json file of key id to base64 encoded key, e.g. `{"pii": "base64..."}`,
+// it is intended for tests and development, use a KMS backed KeyProvider in production
+type LocalKeyProvider struct {
+	KeyFile string `json:"key_file"`
+
+	keys map[string][]byte
+}
+
+func (p *LocalKeyProvider) Provision(ctx context.Context) error {
+	data, err := os.ReadFile(p.KeyFile)
+	if err != nil {
+		return errors.Wrapf(err, "read key file %s failed", p.KeyFile)
+	}
+	var encoded map[string]string
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return errors.Wrapf(err, "unmarshal key file %s failed", p.KeyFile)
+	}
+	p.keys = make(map[string][]byte, len(encoded))
+	for keyID, s := range encoded {
+		key, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return errors.Wrapf(err, "decode key %s failed", keyID)
+		}
+		p.keys[keyID] = key
+	}
+	return nil
+}
+
+func (p *LocalKeyProvider) Key(ctx context.Context, keyID string) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, errors.Errorf("key %s not found", keyID)
+	}
+	return key, nil
+}
+
+// ColumnEncryption encrypts the configured columns transparently:
+//   - the placeholders bound to encrypted columns are found by parsing the query, i.e. `col = ?`, `col IN (?, ?)`,
+//     INSERT values and SET assignments, and the args of them are encrypted
+//   - the result columns selected from encrypted columns(including `*`) are decrypted
+//   - the encryption is deterministic(AES-GCM with synthetic nonce), so that equality lookups work, but the ciphertexts leak equality
+//
+// queries which can not be encrypted are refused with ErrEncryptionUnsupported, e.g. literals compared with encrypted columns,
+// LIKE, BETWEEN and range conditions on encrypted columns, INSERT without column list.
+// NOTE: the columns should be wide enough for the ciphertexts, and ColumnEncryption should be placed after the rewriters in the chain.
+//
+// Usage:
+//
+//     ce := &sqlkit.ColumnEncryption{
+//         Columns: map[string]string{"users.phone": "pii", "users.id_card": "pii"},
+//         KeyFile: "keys.json",
+//     }
+//     err := ce.Provision(ctx)
+//     sql.Register("encrypt:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, audit, ce))
+//
+type ColumnEncryption struct {
+	// Columns encrypted columns of `table.column` to key id
+	Columns map[string]string `json:"columns"`
+
+	// KeyProvider provides the keys, default is LocalKeyProvider of KeyFile
+	KeyProvider KeyProvider `json:"-"`
+
+	// KeyFile key file of the default LocalKeyProvider
+	KeyFile string `json:"key_file,omitempty"`
+
+	// Cache config of the cache of parsed queries, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser  *parser.Parser
+	mu      sync.Mutex // NOTE: parser is not goroutine safe
+	plans   *Cache[string, *encryptionPlan]
+	ciphers *SyncMap[string, *columnCipher]
+	tables  map[string]map[string]string // NOTE: table -> column -> key id, lower case
+	logger  *zap.Logger
+}
+
+// encryptionPlan the key ids of the placeholders("" means not encrypted), and the result columns to be decrypted
+type encryptionPlan struct {
+	args    []string
+	columns map[string]struct{}
+}
+
+func (ce *ColumnEncryption) Name() string {
+	return "column_encryption"
+}
+
+func (ce *ColumnEncryption) Provision(ctx context.Context) error {
+	if len(ce.Columns) == 0 {
+		return errors.New("column encryption with empty columns")
+	}
+	ce.tables = make(map[string]map[string]string)
+	for column, keyID := range ce.Columns {
+		i := strings.Index(column, ".")
+		if i <= 0 || i == len(column)-1 {
+			return errors.Errorf("invalid encrypted column %s, should be `table.column`", column)
+		}
+		if keyID == "" || strings.Contains(keyID, ":") {
+			return errors.Errorf("invalid key id %q of column %s", keyID, column)
+		}
+		table := strings.ToLower(column[:i])
+		if ce.tables[table] == nil {
+			ce.tables[table] = make(map[string]string)
+		}
+		ce.tables[table][strings.ToLower(column[i+1:])] = keyID
+	}
+	if ce.KeyProvider == nil {
+		if ce.KeyFile == "" {
+			return errors.New("column encryption without key provider or key file")
+		}
+		ce.KeyProvider = &LocalKeyProvider{KeyFile: ce.KeyFile}
+	}
+	if p, ok := ce.KeyProvider.(interface{ Provision(context.Context) error }); ok {
+		if err := p.Provision(ctx); err != nil {
+			return err
+		}
+	}
+	if ce.logger == nil {
+		ce.logger = zap.NewNop()
+	}
+	ce.parser = parser.New()
+	ce.plans = NewCache[string, *encryptionPlan](ce.Name(), ce.Cache)
+	ce.ciphers = NewSyncMap[string, *columnCipher]()
+	for _, keyID := range ce.Columns { // NOTE: fail fast on missing or invalid keys
+		if _, err := ce.cipher(ctx, keyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ce *ColumnEncryption) SetLogger(logger *zap.Logger) {
+	ce.logger = logger
+}
+
+// Rewrite encrypts the args bound to encrypted columns, the sql is not changed
+func (ce *ColumnEncryption) Rewrite(sql string, args []any) (string, []any, error) {
+	return ce.RewriteContext(context.Background(), sql, args)
+}
+
+func (ce *ColumnEncryption) RewriteContext(ctx context.Context, sql string, args []any) (string, []any, error) {
+	plan, err := ce.plan(sql)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(plan.args) == 0 || args == nil { // NOTE: args is nil on prepare
+		return sql, args, nil
+	}
+	if len(args) != len(plan.args) {
+		return "", nil, errors.Errorf("sql: expected %d arguments, got %d: %s", len(plan.args), len(args), sql)
+	}
+	newArgs := make([]any, len(args))
+	for i, keyID := range plan.args {
+		if keyID == "" {
+			newArgs[i] = args[i]
+			continue
+		}
+		if newArgs[i], err = ce.Encrypt(ctx, keyID, args[i]); err != nil {
+			return "", nil, errors.WithMessagef(err, "encrypt arg %d failed: %s", i+1, sql)
+		}
+	}
+	return sql, newArgs, nil
+}
+
+func (ce *ColumnEncryption) ExecContext(next ExecContext) ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		args, err := ce.encryptArgs(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, query, args)
+	}
+}
+
+func (ce *ColumnEncryption) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		args, err := ce.encryptArgs(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, query, args)
+	}
+}
+
+func (ce *ColumnEncryption) encryptArgs(ctx context.Context, query string, args []driver.NamedValue) ([]driver.NamedValue, error) {
+	values := make([]any, len(args))
+	for i := range args {
+		values[i] = args[i].Value
+	}
+	_, values, err := ce.RewriteContext(ctx, query, values)
+	if err != nil {
+		return nil, err
+	}
+	newArgs := make([]driver.NamedValue, len(args))
+	for i := range args {
+		newArgs[i] = args[i]
+		newArgs[i].Value = values[i]
+	}
+	return newArgs, nil
+}
+
+// OnRow decrypts the result columns selected from encrypted columns
+// NOTE: the rows of queries which failed to parse are returned as is
+func (ce *ColumnEncryption) OnRow(next OnRow) OnRow {
+	var (
+		cols    []string
+		decrypt []int
+	)
+	return func(ctx context.Context, query string, columns []string, dest []driver.Value) error {
+		if err := next(ctx, query, columns, dest); err != nil {
+			return err
+		}
+		if len(columns) != len(cols) || len(columns) > 0 && &columns[0] != &cols[0] { // NOTE: columns changed on next result set
+			cols, decrypt = columns, nil
+			if plan, err := ce.plan(query); err == nil {
+				for i, column := range columns {
+					if _, ok := plan.columns[strings.ToLower(column)]; ok {
+						decrypt = append(decrypt, i)
+					}
+				}
+			}
+		}
+		for _, i := range decrypt {
+			if i >= len(dest) {
+				break
+			}
+			v, err := ce.Decrypt(ctx, dest[i])
+			if err != nil {
+				return errors.WithMessagef(err, "decrypt column %s failed", columns[i])
+			}
+			dest[i] = v
+		}
+		return nil
+	}
+}
+
+func (ce *ColumnEncryption) OnClose(next OnClose) OnClose {
+	return next
+}
+
+// Encrypt encrypts the value with the key of keyID deterministically, nil is not encrypted
+func (ce *ColumnEncryption) Encrypt(ctx context.Context, keyID string, value any) (driver.Value, error) {
+	plaintext, ok, err := encodePlaintext(value)
+	if err != nil || !ok {
+		return nil, err
+	}
+	c, err := ce.cipher(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	return c.encrypt(plaintext), nil
+}
+
+// Decrypt decrypts the value encrypted by Encrypt, a string or []byte is returned according to the value,
+// values which are not encrypted are returned as is
+func (ce *ColumnEncryption) Decrypt(ctx context.Context, value driver.Value) (driver.Value, error) {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case []byte:
+		s = string(v)
+	default:
+		return value, nil
+	}
+	if !strings.HasPrefix(s, encryptedPrefix) {
+		return value, nil
+	}
+	s = s[len(encryptedPrefix):]
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return nil, errors.New("invalid encrypted value")
+	}
+	c, err := ce.cipher(ctx, s[:i])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := c.decrypt(s[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := value.([]byte); ok {
+		return plaintext, nil
+	}
+	return string(plaintext), nil
+}
+
+func (ce *ColumnEncryption) cipher(ctx context.Context, keyID string) (*columnCipher, error) {
+	if c, ok := ce.ciphers.Load(keyID); ok {
+		return c, nil
+	}
+	key, err := ce.KeyProvider.Key(ctx, keyID)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "get key %s failed", keyID)
+	}
+	c, err := newColumnCipher(keyID, key)
+	if err != nil {
+		return nil, err
+	}
+	ce.ciphers.Store(keyID, c)
+	return c, nil
+}
+
+func (ce *ColumnEncryption) plan(sql string) (*encryptionPlan, error) {
+	if plan, ok := ce.plans.Load(sql); ok {
+		return plan, nil
+	}
+	ce.mu.Lock()
+	stmtNodes, warns, err := ce.parser.Parse(sql, "", "")
+	ce.mu.Unlock()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parse sql failed: %s", sql)
+	}
+	if len(warns) > 0 {
+		ce.logger.Debug("column encryption warnings", zap.Any("warns", warns), zap.String("sql", sql))
+	}
+	var (
+		plan   = &encryptionPlan{columns: make(map[string]struct{})}
+		keys   []string
+		marked bool
+	)
+	for _, stmtNode := range stmtNodes {
+		v := newEncryptionVisitor(ce, stmtNode, &keys)
+		stmtNode.Accept(v)
+		if v.err != nil {
+			return nil, errors.WithMessagef(v.err, "sql: %s", sql)
+		}
+		marked = marked || v.marked
+		if sel := firstSelect(stmtNode); sel != nil {
+			v.resultColumns(sel, plan.columns)
+		}
+	}
+	if marked {
+		if plan.args, err = encryptedArgs(stmtNodes, keys); err != nil {
+			return nil, errors.WithMessagef(err, "sql: %s", sql)
+		}
+		var nargs int
+		scanSql(sql, func(kind byte, start, end int) {
+			if kind == '?' {
+				nargs++
+			}
+		})
+		if nargs != len(plan.args) {
+			return nil, errors.Errorf("sql: found %d placeholders, but %d parsed: %s", nargs, len(plan.args), sql)
+		}
+	}
+	ce.plans.Store(sql, plan)
+	return plan, nil
+}
+
+// encryptedArgs restores the statements and returns the key ids of placeholders according to the sentinels
+func encryptedArgs(stmtNodes []ast.StmtNode, keys []string) ([]string, error) {
+	sentinels := make(map[string]string, len(keys))
+	for i, keyID := range keys {
+		sentinels["'"+encryptionSentinel(i)+"'"] = keyID
+	}
+	var args []string
+	for _, stmtNode := range stmtNodes {
+		restored, err := restore(stmtNode)
+		if err != nil {
+			return nil, errors.WithMessage(err, "restore failed")
+		}
+		scanSql(restored, func(kind byte, start, end int) {
+			switch kind {
+			case '?':
+				args = append(args, "")
+			case '\'':
+				if keyID, ok := sentinels[restored[start:end]]; ok {
+					args = append(args, keyID)
+				}
+			}
+		})
+	}
+	return args, nil
+}
+
+// encryptionSentinel a string literal replaces the placeholder bound to the ith key
+func encryptionSentinel(i int) string {
+	return fmt.Sprintf("__sqlkit_encrypt_%d__", i)
+}
+
+// firstSelect returns the select which determines the result columns of the statement
+func firstSelect(node ast.Node) *ast.SelectStmt {
+	switch n := node.(type) {
+	case *ast.SelectStmt:
+		return n
+	case *ast.SetOprStmt:
+		return firstSelect(n.SelectList)
+	case *ast.SetOprSelectList:
+		if len(n.Selects) > 0 {
+			return firstSelect(n.Selects[0])
+		}
+	}
+	return nil
+}
+
+// encryptionVisitor marks the placeholders bound to encrypted columns with sentinels
+type encryptionVisitor struct {
+	ce      *ColumnEncryption
+	keys    *[]string
+	aliases map[string]string // NOTE: alias or name -> table, lower case
+	tables  []string
+	marked  bool
+	err     error
+}
+
+func newEncryptionVisitor(ce *ColumnEncryption, stmtNode ast.StmtNode, keys *[]string) *encryptionVisitor {
+	v := &encryptionVisitor{
+		ce:      ce,
+		keys:    keys,
+		aliases: make(map[string]string),
+	}
+	c := &nodeCollector{}
+	stmtNode.Accept(c)
+	for _, n := range c.nodes {
+		ts, ok := n.(*ast.TableSource)
+		if !ok {
+			continue
+		}
+		tn, ok := ts.Source.(*ast.TableName)
+		if !ok {
+			continue
+		}
+		if _, ok := v.aliases[tn.Name.L]; !ok {
+			v.tables = append(v.tables, tn.Name.L)
+		}
+		v.aliases[tn.Name.L] = tn.Name.L
+		if ts.AsName.L != "" {
+			v.aliases[ts.AsName.L] = tn.Name.L
+		}
+	}
+	return v
+}
+
+func (v *encryptionVisitor) Enter(in ast.Node) (ast.Node, bool) {
+	return in, false
+}
+
+func (v *encryptionVisitor) Leave(in ast.Node) (ast.Node, bool) {
+	if v.err != nil {
+		return in, false
+	}
+	switch n := in.(type) {
+	case *ast.BinaryOperationExpr:
+		switch n.Op {
+		case opcode.EQ, opcode.NE, opcode.NullEQ:
+			if keyID := v.column(n.L); keyID != "" {
+				n.R = v.bind(keyID, n.R)
+			} else if keyID := v.column(n.R); keyID != "" {
+				n.L = v.bind(keyID, n.L)
+			}
+		case opcode.LT, opcode.LE, opcode.GT, opcode.GE:
+			if v.column(n.L) != "" || v.column(n.R) != "" {
+				v.fail("range condition on encrypted column")
+			}
+		}
+	case *ast.PatternInExpr:
+		if keyID := v.column(n.Expr); keyID != "" {
+			for i, expr := range n.List {
+				n.List[i] = v.bind(keyID, expr)
+			}
+		}
+	case *ast.PatternLikeExpr:
+		if v.column(n.Expr) != "" {
+			v.fail("LIKE on encrypted column")
+		}
+	case *ast.PatternRegexpExpr:
+		if v.column(n.Expr) != "" {
+			v.fail("REGEXP on encrypted column")
+		}
+	case *ast.BetweenExpr:
+		if v.column(n.Expr) != "" {
+			v.fail("BETWEEN on encrypted column")
+		}
+	case *ast.UpdateStmt:
+		for _, assignment := range n.List {
+			if keyID := v.columnName(assignment.Column); keyID != "" {
+				assignment.Expr = v.bind(keyID, assignment.Expr)
+			}
+		}
+	case *ast.InsertStmt:
+		v.insert(n)
+	}
+	return in, v.err == nil
+}
+
+func (v *encryptionVisitor) insert(n *ast.InsertStmt) {
+	ts, ok := n.Table.TableRefs.Left.(*ast.TableSource)
+	if !ok {
+		return
+	}
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok {
+		return
+	}
+	columns := v.ce.tables[tn.Name.L]
+	if len(columns) == 0 {
+		return
+	}
+	for _, assignments := range [][]*ast.Assignment{n.Setlist, n.OnDuplicate} {
+		for _, assignment := range assignments {
+			if keyID := columns[assignment.Column.Name.L]; keyID != "" {
+				assignment.Expr = v.bind(keyID, assignment.Expr)
+			}
+		}
+	}
+	if len(n.Setlist) > 0 {
+		return
+	}
+	if len(n.Columns) == 0 {
+		v.fail("insert into %s without column list", tn.Name.O)
+		return
+	}
+	for j, column := range n.Columns {
+		keyID := columns[column.Name.L]
+		if keyID == "" {
+			continue
+		}
+		if n.Select != nil {
+			v.fail("insert into encrypted column %s from select", column.Name.O)
+			return
+		}
+		for _, list := range n.Lists {
+			if j < len(list) {
+				list[j] = v.bind(keyID, list[j])
+			}
+		}
+	}
+}
+
+// bind returns the sentinel if expr is a placeholder bound to the encrypted column of keyID
+func (v *encryptionVisitor) bind(keyID string, expr ast.ExprNode) ast.ExprNode {
+	switch e := expr.(type) {
+	case ast.ParamMarkerExpr:
+		i := 0
+		for ; i < len(*v.keys) && (*v.keys)[i] != keyID; i++ {
+		}
+		if i == len(*v.keys) {
+			*v.keys = append(*v.keys, keyID)
+		}
+		v.marked = true
+		return ast.NewValueExpr(encryptionSentinel(i), "", "")
+	case ast.ValueExpr:
+		if e.GetValue() != nil {
+			v.fail("literal bound to encrypted column")
+		}
+	case *ast.ColumnNameExpr:
+		if v.column(e) != keyID && v.err == nil {
+			v.fail("column %s compared with encrypted column of different key", e.Name.Name.O)
+		}
+	case *ast.ValuesExpr:
+		if v.column(e.Column) != keyID && v.err == nil {
+			v.fail("VALUES(%s) bound to encrypted column of different key", e.Column.Name.Name.O)
+		}
+	case *ast.DefaultExpr:
+	default:
+		v.fail("expression %T bound to encrypted column", expr)
+	}
+	return expr
+}
+
+// column returns the key id if expr is an encrypted column, or ""
+func (v *encryptionVisitor) column(expr ast.ExprNode) string {
+	e, ok := expr.(*ast.ColumnNameExpr)
+	if !ok {
+		return ""
+	}
+	return v.columnName(e.Name)
+}
+
+func (v *encryptionVisitor) columnName(name *ast.ColumnName) string {
+	if name.Table.L != "" {
+		table, ok := v.aliases[name.Table.L]
+		if !ok {
+			table = name.Table.L
+		}
+		return v.ce.tables[table][name.Name.L]
+	}
+	var keyID string
+	for _, table := range v.tables {
+		k := v.ce.tables[table][name.Name.L]
+		if k == "" {
+			continue
+		}
+		if keyID != "" && k != keyID {
+			v.fail("ambiguous encrypted column %s", name.Name.O)
+			return ""
+		}
+		keyID = k
+	}
+	return keyID
+}
+
+// resultColumns adds the names of the result columns selected from encrypted columns
+func (v *encryptionVisitor) resultColumns(sel *ast.SelectStmt, columns map[string]struct{}) {
+	if sel.Fields == nil {
+		return
+	}
+	for _, field := range sel.Fields.Fields {
+		if field.WildCard != nil {
+			tables := v.tables
+			if field.WildCard.Table.L != "" {
+				table, ok := v.aliases[field.WildCard.Table.L]
+				if !ok {
+					table = field.WildCard.Table.L
+				}
+				tables = []string{table}
+			}
+			for _, table := range tables {
+				for column := range v.ce.tables[table] {
+					columns[column] = struct{}{}
+				}
+			}
+			continue
+		}
+		e, ok := field.Expr.(*ast.ColumnNameExpr)
+		if !ok || v.column(e) == "" {
+			continue
+		}
+		if field.AsName.L != "" {
+			columns[field.AsName.L] = struct{}{}
+		} else {
+			columns[e.Name.Name.L] = struct{}{}
+		}
+	}
+}
+
+func (v *encryptionVisitor) fail(format string, args ...any) {
+	if v.err == nil {
+		v.err = errors.WithMessagef(ErrEncryptionUnsupported, format, args...)
+	}
+}
+
+// columnCipher deterministic AES-GCM, the nonce is the HMAC of the plaintext, and the key id is authenticated
+type columnCipher struct {
+	keyID  string
+	aead   cipher.AEAD
+	macKey []byte
+}
+
+func newColumnCipher(keyID string, key []byte) (*columnCipher, error) {
+	if len(key) < 16 {
+		return nil, errors.Errorf("key %s too short: %d bytes, at least 16 bytes", keyID, len(key))
+	}
+	block, err := aes.NewCipher(deriveKey(key, "sqlkit:encrypt"))
+	if err != nil {
+		return nil, errors.Wrapf(err, "new cipher of key %s failed", keyID)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, errors.Wrapf(err, "new gcm of key %s failed", keyID)
+	}
+	return &columnCipher{
+		keyID:  keyID,
+		aead:   aead,
+		macKey: deriveKey(key, "sqlkit:mac"),
+	}, nil
+}
+
+func deriveKey(key []byte, label string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+func (c *columnCipher) encrypt(plaintext []byte) string {
+	mac := hmac.New(sha256.New, c.macKey)
+	mac.Write(plaintext)
+	nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(plaintext)+c.aead.Overhead())
+	copy(nonce, mac.Sum(nil))
+	sealed := c.aead.Seal(nonce, nonce, plaintext, []byte(c.keyID))
+	return encryptedPrefix + c.keyID + ":" + base64.RawStdEncoding.EncodeToString(sealed)
+}
+
+func (c *columnCipher) decrypt(s string) ([]byte, error) {
+	sealed, err := base64.RawStdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.Wrap(err, "decode encrypted value failed")
+	}
+	if len(sealed) < c.aead.NonceSize() {
+		return nil, errors.New("invalid encrypted value")
+	}
+	plaintext, err := c.aead.Open(nil, sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():], []byte(c.keyID))
+	if err != nil {
+		return nil, errors.Wrapf(err, "decrypt with key %s failed", c.keyID)
+	}
+	return plaintext, nil
+}
+
+// encodePlaintext encodes the value to be encrypted, false is returned if the value is nil
+func encodePlaintext(value any) ([]byte, bool, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false, nil
+	case string:
+		return []byte(v), true, nil
+	case []byte:
+		return v, true, nil
+	case int64:
+		return []byte(strconv.FormatInt(v, 10)), true, nil
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'g', -1, 64)), true, nil
+	case bool:
+		if v {
+			return []byte("1"), true, nil
+		}
+		return []byte("0"), true, nil
+	case time.Time:
+		return []byte(v.Format(time.RFC3339Nano)), true, nil
+	}
+	dv, err := driver.DefaultParameterConverter.ConvertValue(value)
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "unsupported value %T", value)
+	}
+	return encodePlaintext(dv)
+}
+
+var (
+	_ KeyProvider       = (*LocalKeyProvider)(nil)
+	_ RewriterInterface = (*ColumnEncryption)(nil)
+	_ ContextRewriter   = (*ColumnEncryption)(nil)
+	_ Middleware        = (*ColumnEncryption)(nil)
+	_ RowsMiddleware    = (*ColumnEncryption)(nil)
+)
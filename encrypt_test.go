@@ -0,0 +1,95 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestColumnEncryption(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "keys.json")
+	key := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	assert.Nil(t, os.WriteFile(keyFile, []byte(`{"pii": "`+key+`"}`), 0600))
+
+	ce := &sqlkit.ColumnEncryption{
+		Columns: map[string]string{"users.phone": "pii", "users.email": "pii"},
+		KeyFile: keyFile,
+	}
+	ctx := context.Background()
+	assert.Nil(t, ce.Provision(ctx))
+	sql.Register("sqlite3:encrypt", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, ce))
+	db, err := sql.Open("sqlite3:encrypt", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, phone TEXT, email TEXT)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO users (id, name, phone, email) VALUES (?, ?, ?, ?), (?, ?, ?, NULL)",
+		1, "a", "13800000001", "a@example.com", 2, "b", "13800000002")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "UPDATE users SET email = ? WHERE phone = ?", "b@example.com", "13800000002")
+	assert.Nil(t, err)
+
+	var raw string
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT '' || phone FROM users WHERE id = ?", 1).Scan(&raw))
+	assert.True(t, strings.HasPrefix(raw, "enc:pii:"))
+
+	var (
+		name, phone string
+		email       sql.NullString
+	)
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT u.name, u.phone AS p, email FROM users u WHERE u.phone = ?", "13800000001").Scan(&name, &phone, &email))
+	assert.Equal(t, "a", name)
+	assert.Equal(t, "13800000001", phone)
+	assert.Equal(t, "a@example.com", email.String)
+
+	rows, err := db.QueryContext(ctx, "SELECT * FROM users WHERE phone IN (?, ?) ORDER BY id", "13800000001", "13800000002")
+	assert.Nil(t, err)
+	var emails []string
+	for rows.Next() {
+		var id int
+		assert.Nil(t, rows.Scan(&id, &name, &phone, &email))
+		emails = append(emails, email.String)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []string{"a@example.com", "b@example.com"}, emails)
+
+	stmt, err := db.PrepareContext(ctx, "SELECT name FROM users WHERE email = ?")
+	assert.Nil(t, err)
+	assert.Nil(t, stmt.QueryRowContext(ctx, "b@example.com").Scan(&name))
+	assert.Equal(t, "b", name)
+	assert.Nil(t, stmt.Close())
+
+	for _, query := range []string{
+		"SELECT name FROM users WHERE phone = '13800000001'",
+		"SELECT name FROM users WHERE phone LIKE '138%'",
+		"SELECT name FROM users WHERE phone > ?",
+		"INSERT INTO users VALUES (3, 'c', ?, NULL)",
+		"INSERT INTO users (phone) SELECT phone FROM users",
+	} {
+		_, err := db.ExecContext(ctx, query, "13800000001")
+		assert.Truef(t, errors.Is(err, sqlkit.ErrEncryptionUnsupported), query)
+	}
+
+	ciphertext, err := ce.Encrypt(ctx, "pii", "13800000001")
+	assert.Nil(t, err)
+	again, err := ce.Encrypt(ctx, "pii", "13800000001")
+	assert.Nil(t, err)
+	assert.Equal(t, ciphertext, again)
+	plaintext, err := ce.Decrypt(ctx, []byte(ciphertext.(string)))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("13800000001"), plaintext)
+	_, err = ce.Decrypt(ctx, ciphertext.(string)[:len(ciphertext.(string))-2])
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,229 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrEmptySlice returned by ExpandSlices if a slice arg is empty and `RejectEmpty` is true
+var ErrEmptySlice = errors.New("empty slice arg")
+
+// ErrSliceTooLarge returned by ExpandSlices if a slice arg has more elements than `MaxSize`
+var ErrSliceTooLarge = errors.New("slice arg too large")
+
+// ExpandSlices expands `IN (?)` with a slice arg into the placeholders of the elements, so slices can be passed
+// as args directly, e.g. `db.QueryContext(ctx, "SELECT * FROM t WHERE id IN (?)", []int{1, 2, 3})`,
+// the slices are accepted by `RewriteMiddleware.CheckNamedValue` before they are converted by database/sql.
+// An empty slice is expanded to `NULL` for `IN (?)` which matches no rows, and returns `ErrEmptySlice` for `NOT IN (?)`,
+// since `NOT IN (NULL)` matches no rows either while excluding nothing should match every row.
+// NOTE: slices are not supported by prepared statements, since the placeholders are fixed when prepared.
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{Rewriter: &sqlkit.ExpandSlices{MaxSize: 500}}
+//     err := rm.Provision(ctx)
+//     sql.Register("expand:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, rm))
+//     rows, err := db.QueryContext(ctx, "SELECT * FROM t WHERE id IN (?) AND type = ?", []int{1, 2}, "x")
+//     // => SELECT * FROM t WHERE id IN (?, ?) AND type = ?, [1 2 x]
+//
+type ExpandSlices struct {
+	// MaxSize the max number of elements of a slice arg, default is 1000
+	MaxSize int `json:"max_size,omitempty"`
+
+	// RejectEmpty returns `ErrEmptySlice` for empty slices instead of expanding them to `NULL`
+	RejectEmpty bool `json:"reject_empty,omitempty"`
+
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	cache  *Cache[string, []slicePlaceholder]
+	logger *zap.Logger
+}
+
+// slicePlaceholder a placeholder of the sql, inList is true if it is the only element of `IN (...)`,
+// notIn is true if the list is of `NOT IN (...)`
+type slicePlaceholder struct {
+	pos    int
+	inList bool
+	notIn  bool
+}
+
+func (es *ExpandSlices) Name() string {
+	return "expand_slices"
+}
+
+func (es *ExpandSlices) Provision(ctx context.Context) error {
+	if es.MaxSize <= 0 {
+		es.MaxSize = 1000
+	}
+	if es.logger == nil {
+		es.logger = zap.NewNop()
+	}
+	es.cache = NewCache[string, []slicePlaceholder](es.Name(), es.Cache)
+	return nil
+}
+
+func (es *ExpandSlices) SetLogger(logger *zap.Logger) {
+	es.logger = logger
+}
+
+// CheckArg accepts the slices which can be expanded
+func (es *ExpandSlices) CheckArg(arg any) bool {
+	return isExpandable(arg)
+}
+
+func (es *ExpandSlices) Rewrite(query string, args []any) (string, []any, error) {
+	return es.RewriteContext(context.Background(), query, args)
+}
+
+func (es *ExpandSlices) RewriteContext(ctx context.Context, query string, args []any) (string, []any, error) {
+	expand := false
+	for _, arg := range args {
+		if na, ok := arg.(sql.NamedArg); ok {
+			if isExpandable(na.Value) {
+				return "", nil, errors.Errorf("slice arg can not be named: %s: %s", na.Name, query)
+			}
+		} else if isExpandable(arg) {
+			expand = true
+		}
+	}
+	if !expand {
+		return query, args, nil
+	}
+	if _, ok := GetStmtContext(ctx); ok {
+		return "", nil, errors.Errorf("slice arg is not supported by prepared statement: %s", query)
+	}
+	placeholders := es.placeholders(query)
+	if len(placeholders) != len(args) {
+		return "", nil, errors.Errorf("sql: expected %d arguments, got %d: %s", len(placeholders), len(args), query)
+	}
+	var (
+		sb      strings.Builder
+		newArgs = make([]any, 0, len(args))
+		last    int
+	)
+	for i, arg := range args {
+		if !isExpandable(arg) {
+			newArgs = append(newArgs, arg)
+			continue
+		}
+		p := placeholders[i]
+		if !p.inList {
+			return "", nil, errors.Errorf("slice arg %d is not the only element of IN (...): %s", i+1, query)
+		}
+		elems, err := es.elements(arg)
+		if err != nil {
+			return "", nil, errors.WithMessagef(err, "slice arg %d: %s", i+1, query)
+		}
+		sb.WriteString(query[last:p.pos])
+		if len(elems) == 0 && p.notIn {
+			return "", nil, errors.WithMessagef(ErrEmptySlice, "slice arg %d of NOT IN (...): %s", i+1, query)
+		}
+		if len(elems) == 0 {
+			sb.WriteString("NULL")
+		} else {
+			sb.WriteString(strings.TrimSuffix(strings.Repeat("?, ", len(elems)), ", "))
+		}
+		last = p.pos + 1
+		newArgs = append(newArgs, elems...)
+	}
+	sb.WriteString(query[last:])
+	return sb.String(), newArgs, nil
+}
+
+// placeholders return the placeholders of the query in order
+func (es *ExpandSlices) placeholders(query string) []slicePlaceholder {
+	if placeholders, ok := es.cache.Load(query); ok {
+		return placeholders
+	}
+	var placeholders []slicePlaceholder
+	scanSql(query, func(kind byte, start, end int) {
+		if kind == '?' {
+			inList, notIn := isInList(query, start)
+			placeholders = append(placeholders, slicePlaceholder{pos: start, inList: inList, notIn: notIn})
+		}
+	})
+	es.cache.Store(query, placeholders)
+	return placeholders
+}
+
+// elements return the elements of the slice converted to driver.Value
+func (es *ExpandSlices) elements(arg any) ([]any, error) {
+	v := reflect.ValueOf(arg)
+	if v.Len() == 0 && es.RejectEmpty {
+		return nil, ErrEmptySlice
+	}
+	if v.Len() > es.MaxSize {
+		return nil, errors.WithMessagef(ErrSliceTooLarge, "%d > %d", v.Len(), es.MaxSize)
+	}
+	elems := make([]any, v.Len())
+	for i := range elems {
+		elem, err := driver.DefaultParameterConverter.ConvertValue(v.Index(i).Interface())
+		if err != nil {
+			return nil, errors.WithMessagef(err, "element %d", i)
+		}
+		elems[i] = elem
+	}
+	return elems, nil
+}
+
+// isExpandable reports whether the arg is a slice or array which should be expanded, NOTE: []byte and driver.Valuer are not
+func isExpandable(arg any) bool {
+	if arg == nil {
+		return false
+	}
+	if _, ok := arg.(driver.Valuer); ok {
+		return false
+	}
+	t := reflect.TypeOf(arg)
+	if t.Kind() != reflect.Slice && t.Kind() != reflect.Array {
+		return false
+	}
+	return t.Elem().Kind() != reflect.Uint8
+}
+
+// isInList reports whether the placeholder at pos is the only element of `IN (...)`
+func isInList(query string, pos int) (inList, notIn bool) {
+	after := strings.TrimLeftFunc(query[pos+1:], isSpaceRune)
+	if !strings.HasPrefix(after, ")") {
+		return false, false
+	}
+	before := strings.TrimRightFunc(query[:pos], isSpaceRune)
+	if !strings.HasSuffix(before, "(") {
+		return false, false
+	}
+	before = strings.TrimRightFunc(before[:len(before)-1], isSpaceRune)
+	if !hasWordSuffix(before, "IN") {
+		return false, false
+	}
+	before = strings.TrimRightFunc(before[:len(before)-2], isSpaceRune)
+	return true, hasWordSuffix(before, "NOT")
+}
+
+// hasWordSuffix reports whether s ends with the word(case insensitive)
+func hasWordSuffix(s, word string) bool {
+	if len(s) < len(word) || !strings.EqualFold(s[len(s)-len(word):], word) {
+		return false
+	}
+	return len(s) == len(word) || !isWordByte(s[len(s)-len(word)-1])
+}
+
+func isSpaceRune(r rune) bool {
+	return r < 0x80 && isSpace(byte(r))
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '$' || c == '`'
+}
+
+var (
+	_ RewriterInterface = (*ExpandSlices)(nil)
+	_ ContextRewriter   = (*ExpandSlices)(nil)
+	_ ArgChecker        = (*ExpandSlices)(nil)
+)
@@ -0,0 +1,121 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestExpandSlices(t *testing.T) {
+	es := &sqlkit.ExpandSlices{MaxSize: 3}
+	assert.Nil(t, es.Provision(context.Background()))
+	var cases = []struct {
+		sql     string
+		args    []any
+		esql    string
+		newArgs []any
+	}{
+		{
+			sql:     "SELECT * FROM t WHERE id = ?",
+			args:    []any{1},
+			esql:    "SELECT * FROM t WHERE id = ?",
+			newArgs: []any{1},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE id IN (?) AND type = ? AND name not in ( ? )",
+			args:    []any{[]int{1, 2}, "x", []string{"a", "b", "c"}},
+			esql:    "SELECT * FROM t WHERE id IN (?, ?) AND type = ? AND name not in ( ?, ?, ? )",
+			newArgs: []any{int64(1), int64(2), "x", "a", "b", "c"},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE data = ? AND id IN (?) AND name = '?'",
+			args:    []any{[]byte("x"), []int64{}},
+			esql:    "SELECT * FROM t WHERE data = ? AND id IN (NULL) AND name = '?'",
+			newArgs: []any{[]byte("x")},
+		},
+	}
+	for _, c := range cases {
+		esql, newArgs, err := es.Rewrite(c.sql, c.args)
+		assert.Nilf(t, err, c.sql)
+		assert.Equalf(t, c.esql, esql, c.sql)
+		assert.Equalf(t, c.newArgs, newArgs, c.sql)
+	}
+
+	var errCases = []struct {
+		sql  string
+		args []any
+	}{
+		{"SELECT * FROM t WHERE id IN (?)", []any{[]int{1, 2, 3, 4}}},
+		{"SELECT * FROM t WHERE id IN (?, ?)", []any{[]int{1}, 2}},
+		{"SELECT * FROM t WHERE id = ?", []any{[]int{1}}},
+		{"SELECT * FROM t WHERE id IN (?)", []any{sql.Named("ids", []int{1})}},
+		{"SELECT * FROM t WHERE id IN (?) AND type = ?", []any{[]int{1}}},
+	}
+	for _, c := range errCases {
+		_, _, err := es.Rewrite(c.sql, c.args)
+		assert.NotNilf(t, err, c.sql)
+	}
+	_, _, err := es.Rewrite("SELECT * FROM t WHERE id IN (?)", []any{[]int{1, 2, 3, 4}})
+	assert.True(t, errors.Is(err, sqlkit.ErrSliceTooLarge))
+
+	es = &sqlkit.ExpandSlices{RejectEmpty: true}
+	assert.Nil(t, es.Provision(context.Background()))
+	_, _, err = es.Rewrite("SELECT * FROM t WHERE id IN (?)", []any{[]string{}})
+	assert.True(t, errors.Is(err, sqlkit.ErrEmptySlice))
+
+	es = &sqlkit.ExpandSlices{}
+	assert.Nil(t, es.Provision(context.Background()))
+	esql, newArgs, err := es.Rewrite("SELECT * FROM t WHERE id NOT IN (?)", []any{[]int{1, 2}})
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE id NOT IN (?, ?)", esql)
+	assert.Equal(t, []any{int64(1), int64(2)}, newArgs)
+	for _, query := range []string{
+		"SELECT * FROM t WHERE id NOT IN (?)",
+		"SELECT * FROM t WHERE id not\tin(?)",
+	} {
+		_, _, err = es.Rewrite(query, []any{[]int{}})
+		assert.Truef(t, errors.Is(err, sqlkit.ErrEmptySlice), query)
+	}
+	esql, _, err = es.Rewrite("SELECT * FROM t WHERE knot IN (?)", []any{[]int{}})
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE knot IN (NULL)", esql)
+}
+
+func TestExpandSlicesMiddleware(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.ExpandSlices{},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:expand", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:expand", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER, name VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id, name) VALUES (1, 'x'), (2, 'y'), (3, 'z')")
+	assert.Nil(t, err)
+
+	var count int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t WHERE id IN (?) AND name <> ?", []int{1, 2, 3}, "x").Scan(&count))
+	assert.Equal(t, 2, count)
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t WHERE name IN (?)", []string{}).Scan(&count))
+	assert.Equal(t, 0, count)
+	res, err := db.ExecContext(ctx, "DELETE FROM t WHERE name IN (?)", []string{"x", "y"})
+	assert.Nil(t, err)
+	affected, _ := res.RowsAffected()
+	assert.Equal(t, int64(2), affected)
+
+	stmt, err := db.PrepareContext(ctx, "SELECT COUNT(*) FROM t WHERE id IN (?)")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	assert.NotNil(t, stmt.QueryRowContext(ctx, []int{1, 2}).Scan(&count))
+}
@@ -0,0 +1,170 @@
+package fingerprint
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/pingcap/tidb/parser"
+)
+
+// Fingerprint return the normalized statement in the format of MySQL `STATEMENT_DIGEST_TEXT`,
+// so the same statement with different literals, whitespace, IN-list lengths or comments has the same fingerprint:
+//   - literals are replaced with `?`, value lists with `(...)`, multiple rows with `(...) /* , ... */`
+//   - IN-lists are `(...)` whatever the length is, e.g. `IN (1)` and `IN (1, 2)` have the same fingerprint
+//   - keywords and function names are upper-cased, identifiers are back-quoted
+//   - comments and optimizer hints are removed
+//
+// NOTE: unlike MySQL, identifiers are lower-cased and index hints are removed(by the TiDB digester).
+// Fingerprint is idempotent, i.e. the fingerprint of a fingerprint is itself.
+//
+// Usage:
+//
+//     fingerprint.Fingerprint("select * from t where id in (1, 2, 3) and name = 'x'")
+//     // SELECT * FROM `t` WHERE `id` IN (...) AND `name` = ?
+//
+func Fingerprint(sql string) string {
+	sql = strings.ReplaceAll(sql, "(?) /* , ... */", "(?) , (?)")
+	sql = strings.ReplaceAll(sql, "(...) /* , ... */", "(...) , (...)")
+	tokens := reduce(split(parser.Normalize(sql)))
+	return strings.Join(tokens, " ")
+}
+
+// Digest return the sha256 hex of the fingerprint
+// NOTE: it is not equal to MySQL `STATEMENT_DIGEST` which hashes the internal tokens
+func Digest(sql string) string {
+	return DigestFingerprint(Fingerprint(sql))
+}
+
+// DigestFingerprint return the sha256 hex of the fingerprint
+func DigestFingerprint(fp string) string {
+	sum := sha256.Sum256([]byte(fp))
+	return hex.EncodeToString(sum[:])
+}
+
+var digestRegexp = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// IsDigest reports whether s is a digest
+func IsDigest(s string) bool {
+	return digestRegexp.MatchString(s)
+}
+
+// Key return the key used to match statements, which is s itself if it is a digest, otherwise its fingerprint,
+// so raw sqls, fingerprints and digests can all be used as keys in config
+func Key(s string) string {
+	if IsDigest(s) {
+		return s
+	}
+	return Fingerprint(s)
+}
+
+// split splits the normalized sql into tokens, NOTE: quoted identifiers may contain spaces
+func split(normalized string) []string {
+	var tokens []string
+	for i := 0; i < len(normalized); {
+		if normalized[i] == ' ' {
+			i++
+			continue
+		}
+		j := i + 1
+		if normalized[i] == '`' {
+			for j < len(normalized) && !(normalized[j] == '`' && (j+1 == len(normalized) || normalized[j+1] == ' ')) {
+				j++
+			}
+			j++
+		} else {
+			for j < len(normalized) && normalized[j] != ' ' {
+				j++
+			}
+		}
+		if j > len(normalized) {
+			j = len(normalized)
+		}
+		tokens = append(tokens, normalized[i:j])
+		i = j
+	}
+	return tokens
+}
+
+// reduce converts the tokens of TiDB normalized sql to the ones of MySQL digest text
+func reduce(tokens []string) []string {
+	result := make([]string, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		switch {
+		case tok == "(" && match(tokens[i+1:], "...", ")"): // NOTE: `( ... )`
+			tok, i = "(...)", i+2
+		case tok == "(" && match(tokens[i+1:], ".", ".", ".", ")"): // NOTE: `(...)` of fingerprint
+			tok, i = "(...)", i+4
+		case tok == "(" && match(tokens[i+1:], "?", ")") && last(result) == "IN": // NOTE: MySQL keeps `IN (?)` for a single value
+			tok, i = "(...)", i+2
+		case tok == "(" && match(tokens[i+1:], "?", ")"):
+			tok, i = "(?)", i+2
+		case tok == "..." && last(result) == "LIMIT":
+			result = append(result, "?,")
+			tok = "?"
+		case tok == "?" && (last(result) == "IS" || last(result) == "NOT" && last(result[:len(result)-1]) == "IS"):
+			tok = "NULL"
+		case isWord(tok):
+			tok = strings.ToUpper(tok)
+		}
+		result = append(result, tok)
+	}
+	return collapseRows(result)
+}
+
+// collapseRows collapses the rows of literals, e.g. `(...) , (...) , (...)` => `(...) /* , ... */`
+func collapseRows(tokens []string) []string {
+	result := make([]string, 0, len(tokens))
+	for i := 0; i < len(tokens); i++ {
+		tok := tokens[i]
+		if tok != "(...)" && tok != "(?)" {
+			result = append(result, tok)
+			continue
+		}
+		j := i
+		for j+2 < len(tokens) && tokens[j+1] == "," && tokens[j+2] == tok {
+			j += 2
+		}
+		if j > i {
+			tok += " /* , ... */"
+		}
+		result = append(result, tok)
+		i = j
+	}
+	return result
+}
+
+func match(tokens []string, expected ...string) bool {
+	if len(tokens) < len(expected) {
+		return false
+	}
+	for i, tok := range expected {
+		if tokens[i] != tok {
+			return false
+		}
+	}
+	return true
+}
+
+func last(tokens []string) string {
+	if len(tokens) == 0 {
+		return ""
+	}
+	return tokens[len(tokens)-1]
+}
+
+// isWord reports whether the token is a keyword or function name
+func isWord(tok string) bool {
+	if tok == "" || !(tok[0] >= 'a' && tok[0] <= 'z' || tok[0] == '_') {
+		return false
+	}
+	for i := 1; i < len(tok); i++ {
+		c := tok[i]
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '$') {
+			return false
+		}
+	}
+	return true
+}
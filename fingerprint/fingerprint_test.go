@@ -0,0 +1,56 @@
+package fingerprint_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit/fingerprint"
+)
+
+func TestFingerprint(t *testing.T) {
+	var cases = []struct {
+		sql         string
+		fingerprint string
+	}{
+		{
+			sql:         "select * from t where id in (1, 2, 3) and name = 'x' -- comment",
+			fingerprint: "SELECT * FROM `t` WHERE `id` IN (...) AND `name` = ?",
+		},
+		{
+			sql:         "SELECT /*+ MAX_EXECUTION_TIME(10) */ *\n\tFROM T WHERE ID IN (?, ?)  AND name = \"y\"",
+			fingerprint: "SELECT * FROM `t` WHERE `id` IN (...) AND `name` = ?",
+		},
+		{
+			sql:         "SELECT a FROM t WHERE b IN (1) AND c IS NULL AND d IS NOT NULL AND e = -1 LIMIT 10, 20",
+			fingerprint: "SELECT `a` FROM `t` WHERE `b` IN (...) AND `c` IS NULL AND `d` IS NOT NULL AND `e` = ? LIMIT ?, ?",
+		},
+		{
+			sql:         "INSERT INTO t (a, b) VALUES (1, 'a'), (2, 'b'), (3, 'c')",
+			fingerprint: "INSERT INTO `t` ( `a` , `b` ) VALUES (...) /* , ... */",
+		},
+		{
+			sql:         "insert into t (a) values (?)",
+			fingerprint: "INSERT INTO `t` ( `a` ) VALUES (?)",
+		},
+		{
+			sql:         "UPDATE db.t SET a = now() WHERE t.id = 1 ORDER BY 1",
+			fingerprint: "UPDATE `db` . `t` SET `a` = NOW ( ) WHERE `t` . `id` = ? ORDER BY 1",
+		},
+		{
+			sql:         "SELECT `with space` FROM t WHERE f = @v",
+			fingerprint: "SELECT `with space` FROM `t` WHERE `f` = @v",
+		},
+	}
+	for _, tc := range cases {
+		fp := fingerprint.Fingerprint(tc.sql)
+		assert.Equal(t, tc.fingerprint, fp, tc.sql)
+		assert.Equal(t, fp, fingerprint.Fingerprint(fp), "idempotent: %s", tc.sql)
+		assert.Equal(t, fingerprint.DigestFingerprint(fp), fingerprint.Digest(tc.sql))
+	}
+	assert.Equal(t, fingerprint.Fingerprint("SELECT * FROM t WHERE id NOT IN (1, 2)"), fingerprint.Fingerprint("SELECT * FROM t WHERE id NOT IN (?)"))
+	assert.Equal(t, fingerprint.Fingerprint(cases[0].sql), fingerprint.Key(cases[0].sql))
+	digest := fingerprint.Digest(cases[0].sql)
+	assert.True(t, fingerprint.IsDigest(digest))
+	assert.Equal(t, digest, fingerprint.Key(digest))
+}
@@ -0,0 +1,45 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestMockFingerprint(t *testing.T) {
+	mock := sqlkit.NewMock(
+		sqlkit.WithMockExecReturns(map[string]*sqlkit.Return[driver.Result]{
+			"UPDATE t SET a = 1 WHERE id IN (1, 2)": sqlkit.NewReturn[driver.Result](driver.RowsAffected(2), nil),
+		}),
+		sqlkit.WithMockFingerprint(true),
+	)
+	sql.Register("sqlite3:mock:fingerprint", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, mock))
+	db, err := sql.Open("sqlite3:mock:fingerprint", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	res, err := db.ExecContext(context.Background(), "update t set a = ? where id in (?, ?, ?)", 2, 3, 4, 5)
+	assert.Nil(t, err)
+	affected, _ := res.RowsAffected()
+	assert.Equal(t, int64(2), affected)
+}
+
+func TestAuditFingerprint(t *testing.T) {
+	audit := &sqlkit.Audit{
+		Whitelist:   []string{"SELECT * FROM t WHERE id = 1"},
+		Fingerprint: true,
+	}
+	assert.Nil(t, audit.Provision(context.Background()))
+	assert.False(t, audit.ShouldAudit("select * from t where id = 100"))
+	assert.True(t, audit.ShouldAudit("select * from t where name = 'x'"))
+	audit.AddBlacklistQuery("delete from t where id = 1", sqlkit.Banned, "test")
+	s := audit.GetSql("DELETE FROM t WHERE id = 2")
+	assert.NotNil(t, s)
+	assert.Equal(t, sqlkit.Banned, s.AlarmType)
+	assert.Len(t, audit.Sqls(), 1)
+}
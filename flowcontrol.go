@@ -1,3 +1,113 @@
 package sqlkit
 
-// TODO: integrate sentinel
+import (
+	"context"
+	"database/sql/driver"
+
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+)
+
+// ErrFlowControlled returned by FlowControl if the query is rejected since the concurrency limit is exceeded
+var ErrFlowControlled = errors.New("query rejected by flow control")
+
+// FlowControl is a middleware which limits the concurrent queries by `QueryInfo` of ctx:
+//   - `QueryInfo.Name` is used as the resource limited by `Limits`
+//   - `QueryInfo.Priority` below `HighPriority` can not use the `Reserved` part of `MaxConcurrency`
+//
+// NOTE: a query is counted until the driver returns, i.e. reading rows is not counted.
+// TODO: integrate sentinel for rate limiting and circuit breaking
+//
+// Usage:
+//
+//     fc := &sqlkit.FlowControl{MaxConcurrency: 100, Reserved: 20, HighPriority: 1, Limits: map[string]int{"report.export": 5}}
+//     err := fc.Provision(ctx)
+//     sql.Register("fc:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, fc))
+//     ctx = sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "order.create", Priority: 1})
+//
+type FlowControl struct {
+	// MaxConcurrency max concurrent queries, 0 means unlimited
+	MaxConcurrency int `json:"max_concurrency,omitempty"`
+
+	// Reserved part of MaxConcurrency which is only available to queries with priority >= HighPriority
+	Reserved int `json:"reserved,omitempty"`
+
+	// HighPriority the min priority which can use the reserved concurrency
+	HighPriority int `json:"high_priority,omitempty"`
+
+	// Limits max concurrent queries per query name, queries without limit are only limited by MaxConcurrency
+	Limits map[string]int `json:"limits,omitempty"`
+
+	total    *atomic.Int64
+	inflight map[string]*atomic.Int64
+}
+
+func (fc *FlowControl) Provision(ctx context.Context) error {
+	if fc.MaxConcurrency < 0 || fc.Reserved < 0 || fc.Reserved > fc.MaxConcurrency {
+		return errors.Errorf("invalid concurrency: max=%d, reserved=%d", fc.MaxConcurrency, fc.Reserved)
+	}
+	fc.total = atomic.NewInt64(0)
+	fc.inflight = make(map[string]*atomic.Int64, len(fc.Limits))
+	for name, limit := range fc.Limits {
+		if limit <= 0 {
+			return errors.Errorf("invalid limit of %s: %d", name, limit)
+		}
+		fc.inflight[name] = atomic.NewInt64(0)
+	}
+	return nil
+}
+
+func (fc *FlowControl) ExecContext(next ExecContext) ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		release, err := fc.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return next(ctx, query, args)
+	}
+}
+
+func (fc *FlowControl) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		release, err := fc.acquire(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer release()
+		return next(ctx, query, args)
+	}
+}
+
+// acquire return the func to release the concurrency acquired, or ErrFlowControlled if any limit is exceeded
+func (fc *FlowControl) acquire(ctx context.Context) (func(), error) {
+	info, _ := GetQueryInfo(ctx)
+	if fc.MaxConcurrency > 0 {
+		limit := fc.MaxConcurrency
+		if info.Priority < fc.HighPriority {
+			limit -= fc.Reserved
+		}
+		if fc.total.Inc() > int64(limit) {
+			fc.total.Dec()
+			return nil, errors.WithMessagef(ErrFlowControlled, "max concurrency %d exceeded, priority %d", limit, info.Priority)
+		}
+	}
+	inflight, ok := fc.inflight[info.Name]
+	if ok && inflight.Inc() > int64(fc.Limits[info.Name]) {
+		inflight.Dec()
+		if fc.MaxConcurrency > 0 {
+			fc.total.Dec()
+		}
+		return nil, errors.WithMessagef(ErrFlowControlled, "concurrency %d of %s exceeded", fc.Limits[info.Name], info.Name)
+	}
+	return func() {
+		if ok {
+			inflight.Dec()
+		}
+		if fc.MaxConcurrency > 0 {
+			fc.total.Dec()
+		}
+	}, nil
+}
+
+var _ Middleware = (*FlowControl)(nil)
@@ -0,0 +1,51 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestFlowControl(t *testing.T) {
+	fc := &sqlkit.FlowControl{MaxConcurrency: 2, Reserved: 1, HighPriority: 1, Limits: map[string]int{"report": 1}}
+	ctx := context.Background()
+	assert.Nil(t, fc.Provision(ctx))
+
+	var (
+		started = make(chan struct{})
+		block   = make(chan struct{})
+	)
+	blocking := fc.ExecContext(func(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+		started <- struct{}{}
+		<-block
+		return driver.ResultNoRows, nil
+	})
+	exec := fc.ExecContext(func(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+		return driver.ResultNoRows, nil
+	})
+	done := make(chan error)
+	go func() {
+		_, err := blocking(sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "report"}), "SELECT 1", nil)
+		done <- err
+	}()
+	<-started
+
+	_, err := exec(ctx, "SELECT 1", nil)
+	assert.True(t, errors.Is(err, sqlkit.ErrFlowControlled), "reserved for high priority")
+	_, err = exec(sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "report", Priority: 1}), "SELECT 1", nil)
+	assert.True(t, errors.Is(err, sqlkit.ErrFlowControlled), "limit of report")
+	_, err = exec(sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "order", Priority: 1}), "SELECT 1", nil)
+	assert.Nil(t, err)
+
+	close(block)
+	assert.Nil(t, <-done)
+	_, err = exec(sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "report"}), "SELECT 1", nil)
+	assert.Nil(t, err)
+
+	assert.NotNil(t, (&sqlkit.FlowControl{MaxConcurrency: 1, Reserved: 2}).Provision(ctx))
+}
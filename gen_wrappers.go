@@ -0,0 +1,167 @@
+//go:build ignore
+
+// This program generates wrappers_gen.go, which contains wrappers for every combination of the optional
+// interfaces of driver.Conn and driver.Stmt, so that the wrapped ones implement the same optional interfaces
+// as the underlying ones. Invoke it as `go generate`.
+package main
+
+import (
+	"bytes"
+	"go/format"
+	"log"
+	"os"
+	"strings"
+	"text/template"
+)
+
+type optional struct {
+	Const     string // bit constant name
+	Name      string // short name used in generated type names
+	Component string // component type which implements the optional interface
+	Interface string // the optional interface
+}
+
+type combination struct {
+	Mask       []string
+	TypeName   string
+	Components []string
+	Interfaces []string
+	Generate   bool
+}
+
+type wrapper struct {
+	Kind         string // conn or stmt
+	Base         string // base type, e.g. Conn
+	MaskType     string
+	Optionals    []optional
+	Combinations []combination
+}
+
+var (
+	connOptionals = []optional{
+		{"connExecer", "Execer", "ExecerContext", "driver.ExecerContext"},
+		{"connQueryer", "Queryer", "QueryerContext", "driver.QueryerContext"},
+		{"connSessionResetter", "SessionResetter", "SessionResetter", "driver.SessionResetter"},
+		{"connPinger", "Pinger", "Pinger", "driver.Pinger"},
+		{"connNamedValueChecker", "NamedValueChecker", "NamedValueChecker", "driver.NamedValueChecker"},
+		{"connValidator", "Validator", "Validator", "driver.Validator"},
+	}
+
+	stmtOptionals = []optional{
+		{"stmtNamedValueChecker", "NamedValueChecker", "StmtNamedValueChecker", "driver.NamedValueChecker"},
+		{"stmtColumnConverter", "ColumnConverter", "StmtColumnConverter", "driver.ColumnConverter"},
+	}
+
+	// existing exported combinations
+	connTypeNames = map[string]string{
+		"ExecerContext,QueryerContext":                 "ExecerQueryerContext",
+		"ExecerContext,QueryerContext,SessionResetter": "ExecerQueryerContextWithSessionResetter",
+	}
+)
+
+func combinations(kind string, optionals []optional, typeNames map[string]string) []combination {
+	var combs []combination
+	for mask := 1; mask < 1<<len(optionals); mask++ {
+		var comb combination
+		var names []string
+		for i, opt := range optionals {
+			if mask&(1<<i) != 0 {
+				comb.Mask = append(comb.Mask, opt.Const)
+				comb.Components = append(comb.Components, opt.Component)
+				comb.Interfaces = append(comb.Interfaces, opt.Interface)
+				names = append(names, opt.Name)
+			}
+		}
+		switch {
+		case len(comb.Components) == 1:
+			comb.TypeName = comb.Components[0]
+		case typeNames[strings.Join(comb.Components, ",")] != "":
+			comb.TypeName = typeNames[strings.Join(comb.Components, ",")]
+		default:
+			comb.TypeName = kind + strings.Join(names, "")
+			comb.Generate = true
+		}
+		combs = append(combs, comb)
+	}
+	return combs
+}
+
+func main() {
+	wrappers := []wrapper{
+		{
+			Kind:         "conn",
+			Base:         "Conn",
+			MaskType:     "connOptional",
+			Optionals:    connOptionals,
+			Combinations: combinations("conn", connOptionals, connTypeNames),
+		},
+		{
+			Kind:         "stmt",
+			Base:         "Stmt",
+			MaskType:     "stmtOptional",
+			Optionals:    stmtOptionals,
+			Combinations: combinations("stmt", stmtOptionals, nil),
+		},
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, wrappers); err != nil {
+		log.Fatal(err)
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("wrappers_gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+var tmpl = template.Must(template.New("wrappers").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`// Code generated by "go run gen_wrappers.go"; DO NOT EDIT.
+
+package sqlkit
+
+import "database/sql/driver"
+{{range .}}{{$w := .}}
+type {{.MaskType}} int
+
+const (
+{{- range $i, $opt := .Optionals}}
+	{{$opt.Const}}{{if eq $i 0}} {{$w.MaskType}} = 1 << iota{{end}}
+{{- end}}
+)
+
+// wrap{{.Base}}Optionals return a driver.{{.Base}} which implements the same optional interfaces as the underlying one
+func wrap{{.Base}}Optionals(wrapped *{{.Base}}, mask {{.MaskType}}) driver.{{.Base}} {
+	switch mask {
+{{- range .Combinations}}
+	case {{join .Mask " | "}}:
+		{{- if eq (len .Components) 1}}
+		return &{{.TypeName}}{wrapped}
+		{{- else}}
+		return &{{.TypeName}}{wrapped{{range .Components}}, &{{.}}{wrapped}{{end}}}
+		{{- end}}
+{{- end}}
+	default:
+		return wrapped
+	}
+}
+{{range .Combinations}}{{if .Generate}}
+type {{.TypeName}} struct {
+	*{{$w.Base}}
+{{- range .Components}}
+	*{{.}}
+{{- end}}
+}
+{{end}}{{end}}
+var (
+{{- range .Combinations}}
+{{- $typeName := .TypeName}}
+{{- range .Interfaces}}
+	_ {{.}} = (*{{$typeName}})(nil)
+{{- end}}
+{{- end}}
+)
+{{end}}
+`))
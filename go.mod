@@ -9,6 +9,7 @@ require (
 	github.com/go-sql-driver/mysql v1.7.1
 	github.com/golang/protobuf v1.5.2
 	github.com/mattn/go-sqlite3 v1.14.5
+	github.com/pingcap/tidb v1.1.0-beta.0.20211124132551-4a1b2e9fe5b5
 	github.com/pkg/errors v0.9.1
 	github.com/prometheus/client_golang v1.14.0
 	github.com/prometheus/client_model v0.3.0
@@ -43,7 +44,6 @@ require (
 	github.com/pingcap/failpoint v0.0.0-20220801062533-2eaa32854a6c // indirect
 	github.com/pingcap/kvproto v0.0.0-20211029081837-3c7bd947cf9b // indirect
 	github.com/pingcap/log v1.0.0 // indirect
-	github.com/pingcap/tidb v1.1.0-beta.0.20211124132551-4a1b2e9fe5b5 // indirect
 	github.com/pingcap/tidb/parser v0.0.0-20211124132551-4a1b2e9fe5b5 // indirect
 	github.com/pingcap/tipb v0.0.0-20211105090418-71142a4d40e3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
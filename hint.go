@@ -0,0 +1,412 @@
+package sqlkit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/ccmonky/errors"
+	"github.com/ccmonky/render"
+	"github.com/ccmonky/sqlkit/fingerprint"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/model"
+	"go.uber.org/zap"
+)
+
+// QueryHints hints injected into a query
+type QueryHints struct {
+	// MaxExecutionTime injects `MAX_EXECUTION_TIME(n)` in milliseconds, NOTE: only works for SELECT
+	MaxExecutionTime int64 `json:"max_execution_time,omitempty"`
+
+	// SetVar injects `SET_VAR(name=value)` for each system variable
+	SetVar map[string]string `json:"set_var,omitempty"`
+
+	// UseIndex injects `USE INDEX (indexes)`, table name or alias -> indexes
+	UseIndex map[string][]string `json:"use_index,omitempty"`
+
+	// ForceIndex injects `FORCE INDEX (indexes)`, table name or alias -> indexes
+	ForceIndex map[string][]string `json:"force_index,omitempty"`
+
+	// Hints other optimizer hints, e.g. `NO_INDEX_MERGE(t)`, `JOIN_ORDER(t1, t2)`
+	Hints []string `json:"hints,omitempty"`
+}
+
+var hintVarRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func (qh *QueryHints) validate() error {
+	if qh.MaxExecutionTime < 0 {
+		return errors.Errorf("negative max_execution_time: %d", qh.MaxExecutionTime)
+	}
+	for name, value := range qh.SetVar {
+		if !hintVarRegexp.MatchString(name) {
+			return errors.Errorf("invalid set_var name: %s", name)
+		}
+		if strings.ContainsAny(value, "()*") {
+			return errors.Errorf("invalid set_var value: %s=%s", name, value)
+		}
+	}
+	for _, hint := range qh.Hints {
+		if strings.Contains(hint, "*/") {
+			return errors.Errorf("invalid hint: %s", hint)
+		}
+	}
+	return nil
+}
+
+// comment return the optimizer hints in comment, e.g. `MAX_EXECUTION_TIME(1000) SET_VAR(sort_buffer_size=16777216)`
+func (qh *QueryHints) comment() string {
+	var hints []string
+	if qh.MaxExecutionTime > 0 {
+		hints = append(hints, fmt.Sprintf("MAX_EXECUTION_TIME(%d)", qh.MaxExecutionTime))
+	}
+	names := make([]string, 0, len(qh.SetVar))
+	for name := range qh.SetVar {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		hints = append(hints, fmt.Sprintf("SET_VAR(%s=%s)", name, qh.SetVar[name]))
+	}
+	hints = append(hints, qh.Hints...)
+	return strings.Join(hints, " ")
+}
+
+// OptimizerHints injects MySQL optimizer hints and index hints into queries matched by fingerprint,
+// the key can be a raw sql, a fingerprint(e.g. "SELECT * FROM `t` WHERE `id` = ?") or a digest, see `fingerprint.Key`,
+// hints can be updated at runtime with `SetHints` or `SetHintsAPI`, so operators can pin an index or cap runtime without redeploy.
+//
+// Usage:
+//
+//     {
+//         "hints": {
+//             "SELECT * FROM `orders` WHERE `user_id` = ?": {
+//                 "max_execution_time": 1000,
+//                 "force_index": {"orders": ["idx_user_id"]}
+//             }
+//         }
+//     }
+//
+type OptimizerHints struct {
+	// Hints fingerprint -> hints
+	Hints map[string]*QueryHints `json:"hints,omitempty"`
+
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser *parser.Parser
+	mu     sync.Mutex // NOTE: parser is not goroutine safe
+	hints  *SyncMap[string, *QueryHints]
+	cache  *Cache[string, string]
+	logger *zap.Logger
+}
+
+func (oh *OptimizerHints) Name() string {
+	return "optimizer_hints"
+}
+
+func (oh *OptimizerHints) Provision(ctx context.Context) error {
+	oh.parser = parser.New()
+	oh.hints = NewSyncMap[string, *QueryHints]()
+	oh.cache = NewCache[string, string](oh.Name(), oh.Cache)
+	if oh.logger == nil {
+		oh.logger = zap.NewNop()
+	}
+	for fp, hints := range oh.Hints {
+		if err := oh.SetHints(fp, hints); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (oh *OptimizerHints) SetLogger(logger *zap.Logger) {
+	oh.logger = logger
+}
+
+// SetHints sets the hints of the fingerprint, which can be a raw sql, a fingerprint or a digest
+func (oh *OptimizerHints) SetHints(fp string, hints *QueryHints) error {
+	if hints == nil {
+		return errors.Errorf("nil hints: %s", fp)
+	}
+	if err := hints.validate(); err != nil {
+		return errors.WithMessagef(err, "invalid hints: %s", fp)
+	}
+	oh.hints.Store(fingerprint.Key(fp), hints)
+	oh.cache.Clear()
+	return nil
+}
+
+// DeleteHints deletes the hints of the fingerprint
+func (oh *OptimizerHints) DeleteHints(fp string) {
+	oh.hints.Delete(fingerprint.Key(fp))
+	oh.cache.Clear()
+}
+
+// AllHints return a snapshot of fingerprint -> hints
+func (oh *OptimizerHints) AllHints() map[string]*QueryHints {
+	snapshot := make(map[string]*QueryHints)
+	oh.hints.Range(func(fp, hints any) bool {
+		snapshot[fp.(string)] = hints.(*QueryHints)
+		return true
+	})
+	return snapshot
+}
+
+// lookup return the hints of the sql matched by fingerprint or digest
+func (oh *OptimizerHints) lookup(sql string) (*QueryHints, bool) {
+	fp := fingerprint.Fingerprint(sql)
+	if hints, ok := oh.hints.Load(fp); ok {
+		return hints, true
+	}
+	return oh.hints.Load(fingerprint.DigestFingerprint(fp))
+}
+
+func (oh *OptimizerHints) Rewrite(sql string, args []any) (string, []any, error) {
+	sql, err := oh.RewriteSql(sql)
+	return sql, args, err
+}
+
+func (oh *OptimizerHints) RewriteSql(sql string) (string, error) {
+	if result, ok := oh.cache.Load(sql); ok {
+		return result, nil
+	}
+	hints, ok := oh.lookup(sql)
+	if !ok {
+		oh.cache.Store(sql, sql)
+		return sql, nil
+	}
+	result := sql
+	var err error
+	if len(hints.UseIndex) > 0 || len(hints.ForceIndex) > 0 {
+		result, err = oh.injectIndexHints(result, hints)
+		if err != nil {
+			return "", errors.WithMessagef(err, "inject index hints failed: %s", sql)
+		}
+	}
+	if comment := hints.comment(); comment != "" {
+		var injected bool
+		result, injected = injectHintComment(result, comment)
+		if !injected {
+			oh.logger.Warn("optimizer hints not supported for statement", zap.String("sql", sql), zap.String("hints", comment))
+		}
+	}
+	oh.cache.Store(sql, result)
+	return result, nil
+}
+
+func (oh *OptimizerHints) injectIndexHints(sql string, hints *QueryHints) (string, error) {
+	if hasSetVarHint(sql) { // NOTE: parser restores SET_VAR wrongly
+		oh.logger.Warn("index hints not supported for sql with SET_VAR hints", zap.String("sql", sql), zap.Any("hints", hints))
+		return sql, nil
+	}
+	oh.mu.Lock()
+	stmtNode, err := oh.parser.ParseOneStmt(sql, "", "")
+	oh.mu.Unlock()
+	if err != nil {
+		return "", errors.WithMessagef(err, "parser sql faield")
+	}
+	visitor := &indexHintVisitor{hints: hints}
+	stmtNode.Accept(visitor)
+	if !visitor.found {
+		oh.logger.Warn("index hints not matched", zap.String("sql", sql), zap.Any("hints", hints))
+		return sql, nil
+	}
+	result, err := restore(stmtNode)
+	if err != nil {
+		return "", errors.WithMessagef(err, "restore failed")
+	}
+	return result, nil
+}
+
+// hasSetVarHint reports whether `SET_VAR` is in the optimizer hint comments of the sql, quoted strings are skipped
+func hasSetVarHint(sql string) bool {
+	var quoted [][2]int
+	scanSql(sql, func(kind byte, start, end int) {
+		if kind == '\'' || kind == '"' || kind == '`' {
+			quoted = append(quoted, [2]int{start, end})
+		}
+	})
+	for i := 0; ; {
+		start := strings.Index(sql[i:], "/*+")
+		if start < 0 {
+			return false
+		}
+		start += i
+		end := strings.Index(sql[start:], "*/")
+		if end < 0 {
+			return false
+		}
+		end += start
+		inQuote := false
+		for _, q := range quoted {
+			if start >= q[0] && start < q[1] {
+				inQuote = true
+				break
+			}
+		}
+		if !inQuote && strings.Contains(strings.ToUpper(sql[start:end]), "SET_VAR") {
+			return true
+		}
+		i = end + 2
+	}
+}
+
+// indexHintVisitor adds index hints to the tables matched by name or alias
+type indexHintVisitor struct {
+	hints *QueryHints
+	found bool
+}
+
+func (v *indexHintVisitor) Enter(in ast.Node) (ast.Node, bool) {
+	ts, ok := in.(*ast.TableSource)
+	if !ok {
+		return in, false
+	}
+	tn, ok := ts.Source.(*ast.TableName)
+	if !ok {
+		return in, false
+	}
+	name := tn.Name.String()
+	if ts.AsName.String() != "" {
+		name = ts.AsName.String()
+	}
+	v.add(tn, ast.HintUse, lookupIndexes(v.hints.UseIndex, name))
+	v.add(tn, ast.HintForce, lookupIndexes(v.hints.ForceIndex, name))
+	return in, false
+}
+
+func (v *indexHintVisitor) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+func (v *indexHintVisitor) add(tn *ast.TableName, typ ast.IndexHintType, indexes []string) {
+	if len(indexes) == 0 {
+		return
+	}
+	hint := &ast.IndexHint{HintType: typ, HintScope: ast.HintForScan}
+	for _, index := range indexes {
+		hint.IndexNames = append(hint.IndexNames, model.NewCIStr(index))
+	}
+	tn.IndexHints = append(tn.IndexHints, hint)
+	v.found = true
+}
+
+func lookupIndexes(m map[string][]string, name string) []string {
+	for table, indexes := range m {
+		if strings.EqualFold(table, name) {
+			return indexes
+		}
+	}
+	return nil
+}
+
+// injectHintComment injects the optimizer hints after the leading keyword of the statement,
+// or merges them into the existing hint comment, the sql is returned unchanged with false if the statement
+// does not start with SELECT/INSERT/REPLACE/UPDATE/DELETE, e.g. `WITH ...` or `(SELECT ...) UNION ...`
+func injectHintComment(sql, comment string) (string, bool) {
+	i := skipSpaceAndComments(sql, 0)
+	j := i
+	for j < len(sql) && (sql[j] >= 'a' && sql[j] <= 'z' || sql[j] >= 'A' && sql[j] <= 'Z') {
+		j++
+	}
+	switch strings.ToUpper(sql[i:j]) {
+	case "SELECT", "INSERT", "REPLACE", "UPDATE", "DELETE":
+	default:
+		return sql, false
+	}
+	k := j
+	for k < len(sql) && isSpace(sql[k]) {
+		k++
+	}
+	if strings.HasPrefix(sql[k:], "/*+") {
+		if end := strings.Index(sql[k:], "*/"); end > 0 {
+			end += k
+			return strings.TrimRight(sql[:end], " ") + " " + comment + " " + sql[end:], true
+		}
+	}
+	return sql[:j] + " /*+ " + comment + " */" + sql[j:], true
+}
+
+// skipSpaceAndComments return the position of the first non space char which is not in comments, except hint comments
+func skipSpaceAndComments(sql string, i int) int {
+	for i < len(sql) {
+		switch {
+		case isSpace(sql[i]):
+			i++
+		case strings.HasPrefix(sql[i:], "/*") && !strings.HasPrefix(sql[i:], "/*+"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end < 0 {
+				return len(sql)
+			}
+			i += end + 4
+		case strings.HasPrefix(sql[i:], "-- ") || sql[i] == '#':
+			i = skipLine(sql, i)
+		default:
+			return i
+		}
+	}
+	return i
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// HintsAPI list hints
+func (oh *OptimizerHints) HintsAPI(w http.ResponseWriter, r *http.Request) {
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"hints": oh.AllHints(),
+		},
+	})
+}
+
+// SetHintsAPI add or delete hints of a fingerprint, e.g. `?action=add` with body `{"fingerprint": "...", "hints": {...}}`
+func (oh *OptimizerHints) SetHintsAPI(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.Unknown))
+		return
+	}
+	defer r.Body.Close()
+	m := SetHintsRequest{}
+	err = json.Unmarshal(body, &m)
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+		return
+	}
+	switch action := r.FormValue("action"); action {
+	case "add":
+		err = oh.SetHints(m.Fingerprint, m.Hints)
+	case "delete":
+		oh.DeleteHints(m.Fingerprint)
+	default:
+		err = errors.Errorf("unknown action: %s", action)
+	}
+	if err != nil {
+		render.R(renderName).Err(w, r, errors.Adapt(err, errors.InvalidArgument))
+		return
+	}
+	render.R(renderName).OK(w, r, map[string]interface{}{
+		"data": map[string]interface{}{
+			"hints": oh.AllHints(),
+		},
+	})
+}
+
+type SetHintsRequest struct {
+	Fingerprint string      `json:"fingerprint"`
+	Hints       *QueryHints `json:"hints,omitempty"`
+}
+
+var (
+	_ RewriterInterface = (*OptimizerHints)(nil)
+	_ SqlRewriter       = (*OptimizerHints)(nil)
+)
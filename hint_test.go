@@ -0,0 +1,83 @@
+package sqlkit_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+	"github.com/ccmonky/sqlkit/fingerprint"
+)
+
+func TestOptimizerHints(t *testing.T) {
+	oh := &sqlkit.OptimizerHints{
+		Hints: map[string]*sqlkit.QueryHints{
+			"SELECT * FROM orders WHERE user_id = 1": {
+				MaxExecutionTime: 1000,
+				SetVar:           map[string]string{"sort_buffer_size": "16777216"},
+				ForceIndex:       map[string][]string{"orders": {"idx_user_id"}},
+			},
+			"SELECT `o` . `id` FROM `orders` `o` JOIN `users` `u` ON `o` . `user_id` = `u` . `id`": {
+				UseIndex: map[string][]string{"u": {"PRIMARY"}},
+				Hints:    []string{"JOIN_ORDER(u, o)"},
+			},
+		},
+	}
+	assert.Nil(t, oh.Provision(context.Background()))
+	var cases = []struct {
+		sql    string
+		result string
+	}{
+		{
+			sql:    "SELECT * FROM orders WHERE user_id = ?",
+			result: "SELECT /*+ MAX_EXECUTION_TIME(1000) SET_VAR(sort_buffer_size=16777216) */ * FROM orders FORCE INDEX (idx_user_id) WHERE user_id=?",
+		},
+		{
+			sql:    "SELECT o.id FROM orders o JOIN users u ON o.user_id = u.id",
+			result: "SELECT /*+ JOIN_ORDER(u, o) */ o.id FROM orders AS o JOIN users AS u USE INDEX (PRIMARY) ON o.user_id=u.id",
+		},
+		{
+			sql:    "SELECT * FROM orders WHERE id = ?",
+			result: "SELECT * FROM orders WHERE id = ?",
+		},
+	}
+	for _, tc := range cases {
+		s, err := oh.RewriteSql(tc.sql)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.result, s)
+	}
+
+	sql := "/* app */ select /*+ NO_INDEX_MERGE(t) */ id FROM t WHERE a = 'x'"
+	digest := fingerprint.Digest(sql)
+	assert.Nil(t, oh.SetHints(digest, &sqlkit.QueryHints{MaxExecutionTime: 50}))
+	s, err := oh.RewriteSql(sql)
+	assert.Nil(t, err)
+	assert.Equal(t, "/* app */ select /*+ NO_INDEX_MERGE(t) MAX_EXECUTION_TIME(50) */ id FROM t WHERE a = 'x'", s)
+	oh.DeleteHints(digest)
+	s, err = oh.RewriteSql(sql)
+	assert.Nil(t, err)
+	assert.Equal(t, sql, s)
+
+	assert.Nil(t, oh.SetHints("SELECT * FROM orders WHERE note = ?", &sqlkit.QueryHints{ForceIndex: map[string][]string{"orders": {"idx_note"}}}))
+	s, err = oh.RewriteSql("SELECT * FROM orders WHERE note = 'set_var'")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM orders FORCE INDEX (idx_note) WHERE note='set_var'", s)
+	sql = "SELECT /*+ SET_VAR(sort_buffer_size=16777216) */ * FROM orders WHERE note = 'x'"
+	s, err = oh.RewriteSql(sql)
+	assert.Nil(t, err)
+	assert.Equal(t, sql, s)
+
+	assert.NotNil(t, oh.SetHints("SELECT 1", &sqlkit.QueryHints{SetVar: map[string]string{"a) */ DROP": "1"}}))
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/hints?action=add", strings.NewReader(`{"fingerprint": "UPDATE t SET a = 1", "hints": {"set_var": {"foreign_key_checks": "OFF"}}}`))
+	oh.SetHintsAPI(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	s, err = oh.RewriteSql("UPDATE t SET a = 2")
+	assert.Nil(t, err)
+	assert.Equal(t, "UPDATE /*+ SET_VAR(foreign_key_checks=OFF) */ t SET a = 2", s)
+}
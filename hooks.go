@@ -0,0 +1,241 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/qustavo/sqlhooks/v2"
+)
+
+var (
+	// ErrHooksBypassed returned by the hooks converted by `ToHooks` if the middleware returns without calling next,
+	// since sqlhooks can not return results in place of the driver(e.g. mock)
+	ErrHooksBypassed = errors.New("driver bypassed by middleware, which is not supported by sqlhooks")
+
+	// ErrHooksRewritten returned by the hooks converted by `ToHooks` if the middleware calls next with another query,
+	// since sqlhooks can not change the query
+	ErrHooksRewritten = errors.New("query rewritten by middleware, which is not supported by sqlhooks")
+
+	// ErrHooksRecalled returned to the middleware converted by `ToHooks` if it calls next more than once,
+	// since sqlhooks runs the driver only once
+	ErrHooksRecalled = errors.New("next called more than once by middleware, which is not supported by sqlhooks")
+)
+
+// FromHooks converts a `sqlhooks.Hooks` to a Middleware, the semantics are the same as `sqlhooks.Wrap`:
+// `Before` is called before next, `After` is called if next succeeded, otherwise `OnError` is called if implemented,
+// except that `OnError` is not called for `driver.ErrSkip`, which is not an error but a signal for database/sql to fallback.
+//
+// Usage:
+//
+//     sql.Register("log:sqlite3", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, sqlkit.FromHooks(logHooks), mock))
+//
+func FromHooks(hooks sqlhooks.Hooks) Middleware {
+	return &HooksMiddleware{Hooks: hooks}
+}
+
+// HooksMiddleware adapts a `sqlhooks.Hooks` to Middleware
+type HooksMiddleware struct {
+	Hooks sqlhooks.Hooks
+}
+
+func (hm *HooksMiddleware) MiddlewareName() string {
+	if n, ok := hm.Hooks.(interface{ MiddlewareName() string }); ok {
+		return n.MiddlewareName()
+	}
+	return fmt.Sprintf("%T", hm.Hooks)
+}
+
+func (hm *HooksMiddleware) ExecContext(next ExecContext) ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		list := namedToInterface(args)
+		ctx, err := hm.Hooks.Before(ctx, query, list...)
+		if err != nil {
+			return nil, err
+		}
+		result, err := next(ctx, query, args)
+		if err != nil {
+			return result, hm.onError(ctx, err, query, list...)
+		}
+		if _, err = hm.Hooks.After(ctx, query, list...); err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+}
+
+func (hm *HooksMiddleware) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		list := namedToInterface(args)
+		ctx, err := hm.Hooks.Before(ctx, query, list...)
+		if err != nil {
+			return nil, err
+		}
+		rows, err := next(ctx, query, args)
+		if err != nil {
+			return rows, hm.onError(ctx, err, query, list...)
+		}
+		if _, err = hm.Hooks.After(ctx, query, list...); err != nil {
+			return nil, err
+		}
+		return rows, nil
+	}
+}
+
+func (hm *HooksMiddleware) onError(ctx context.Context, err error, query string, args ...interface{}) error {
+	if err == driver.ErrSkip {
+		return err
+	}
+	h, ok := hm.Hooks.(sqlhooks.OnErrorer)
+	if !ok {
+		return err
+	}
+	if herr := h.OnError(ctx, err, query, args...); herr != nil {
+		return herr
+	}
+	return err
+}
+
+// ToHooks converts a Middleware to a `sqlhooks.Hooks`, the middleware runs in another goroutine from `Before` until
+// it calls next, and then resumes with the result of the driver in `After` or `OnError`.
+// NOTE: since sqlhooks can not operate the arguments & returns:
+//   - the middleware sees `driver.ResultNoRows` for exec and empty rows for query, and must call next at most once,
+//     the second call of next returns `ErrHooksRecalled`
+//   - `ErrHooksBypassed` is returned if the middleware does not call next, and `ErrHooksRewritten` if the query is changed
+//   - the error returned by the driver can not be swallowed by middleware
+//   - which of `ExecContext` or `QueryContext` is called is decided by the leading keyword of query
+//
+// Usage:
+//
+//     sql.Register("audit:mysql", sqlhooks.Wrap(&mysql.MySQLDriver{}, sqlkit.ToHooks(middleware)))
+//
+func ToHooks(mw Middleware) sqlhooks.Hooks {
+	return &MiddlewareHooks{Middleware: mw}
+}
+
+// MiddlewareHooks adapts a Middleware to `sqlhooks.Hooks` and `sqlhooks.OnErrorer`
+type MiddlewareHooks struct {
+	Middleware Middleware
+}
+
+type hooksCallCtxKey struct{}
+
+type hooksCall struct {
+	query  string
+	called chan context.Context // middleware -> hooks: next is called with ctx
+	result chan error           // hooks -> middleware: result of the driver
+	done   chan error           // middleware -> hooks: middleware returned
+	nexts  int32                // times of next called
+}
+
+func (mh *MiddlewareHooks) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	call := &hooksCall{
+		query:  query,
+		called: make(chan context.Context, 1),
+		result: make(chan error, 1),
+		done:   make(chan error, 1),
+	}
+	go mh.run(ctx, call, interfaceToNamed(args))
+	select {
+	case c := <-call.called:
+		if c == nil { // NOTE: query rewritten
+			call.result <- ErrHooksRewritten
+			<-call.done
+			return ctx, ErrHooksRewritten
+		}
+		return context.WithValue(c, hooksCallCtxKey{}, call), nil
+	case err := <-call.done:
+		if err == nil {
+			err = ErrHooksBypassed
+		}
+		return ctx, err
+	}
+}
+
+func (mh *MiddlewareHooks) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	return ctx, mh.resume(ctx, nil)
+}
+
+func (mh *MiddlewareHooks) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	if merr := mh.resume(ctx, err); merr != nil && err != driver.ErrSkip {
+		return merr
+	}
+	return err
+}
+
+func (mh *MiddlewareHooks) resume(ctx context.Context, err error) error {
+	call, ok := ctx.Value(hooksCallCtxKey{}).(*hooksCall)
+	if !ok {
+		return err
+	}
+	call.result <- err
+	return <-call.done
+}
+
+func (mh *MiddlewareHooks) run(ctx context.Context, call *hooksCall, args []driver.NamedValue) {
+	var err error
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("middleware panic: %v", p)
+		}
+		call.done <- err
+	}()
+	wait := func(c context.Context, q string) error {
+		if atomic.AddInt32(&call.nexts, 1) > 1 {
+			return ErrHooksRecalled
+		}
+		if q != call.query {
+			call.called <- nil
+		} else {
+			call.called <- c
+		}
+		return <-call.result
+	}
+	if isQuery(call.query) {
+		_, err = mh.Middleware.QueryContext(func(c context.Context, q string, _ []driver.NamedValue) (driver.Rows, error) {
+			if err := wait(c, q); err != nil {
+				return nil, err
+			}
+			return &EmptyRows{}, nil
+		})(ctx, call.query, args)
+	} else {
+		_, err = mh.Middleware.ExecContext(func(c context.Context, q string, _ []driver.NamedValue) (driver.Result, error) {
+			if err := wait(c, q); err != nil {
+				return nil, err
+			}
+			return driver.ResultNoRows, nil
+		})(ctx, call.query, args)
+	}
+}
+
+func interfaceToNamed(args []interface{}) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+	return named
+}
+
+// isQuery reports whether the query returns rows according to its leading keyword
+func isQuery(query string) bool {
+	query = strings.TrimLeft(query, " \t\r\n(")
+	i := strings.IndexAny(query, " \t\r\n(")
+	if i >= 0 {
+		query = query[:i]
+	}
+	switch strings.ToUpper(query) {
+	case "SELECT", "SHOW", "WITH", "DESC", "DESCRIBE", "EXPLAIN", "VALUES", "PRAGMA":
+		return true
+	default:
+		return false
+	}
+}
+
+var (
+	_ Middleware         = (*HooksMiddleware)(nil)
+	_ sqlhooks.Hooks     = (*MiddlewareHooks)(nil)
+	_ sqlhooks.OnErrorer = (*MiddlewareHooks)(nil)
+)
@@ -0,0 +1,138 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/qustavo/sqlhooks/v2"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+type hooksRecorder struct {
+	calls []string
+}
+
+func (h *hooksRecorder) Before(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.calls = append(h.calls, "before")
+	return ctx, nil
+}
+
+func (h *hooksRecorder) After(ctx context.Context, query string, args ...interface{}) (context.Context, error) {
+	h.calls = append(h.calls, "after")
+	return ctx, nil
+}
+
+func (h *hooksRecorder) OnError(ctx context.Context, err error, query string, args ...interface{}) error {
+	h.calls = append(h.calls, "error")
+	return err
+}
+
+func TestFromHooks(t *testing.T) {
+	hr := &hooksRecorder{}
+	mw := sqlkit.FromHooks(hr)
+	assert.Equal(t, "*sqlkit_test.hooksRecorder", sqlkit.MiddlewareName(mw))
+	sql.Register("sqlite3:fromhooks", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, mw))
+	db, err := sql.Open("sqlite3:fromhooks", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"before", "after"}, hr.calls)
+
+	hr.calls = nil
+	_, err = db.QueryContext(ctx, "SELECT id2 FROM t1")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"before", "error"}, hr.calls)
+
+	hr.calls = nil
+	next := func(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+		return nil, driver.ErrSkip
+	}
+	_, err = mw.ExecContext(next)(ctx, "INSERT INTO t1 VALUES(?, ?)", nil)
+	assert.Equal(t, driver.ErrSkip, err)
+	assert.Equal(t, []string{"before"}, hr.calls)
+}
+
+type errMiddleware struct {
+	recorder
+	errs []error
+}
+
+func (m *errMiddleware) ExecContext(next sqlkit.ExecContext) sqlkit.ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		*m.calls = append(*m.calls, "before")
+		result, err := next(ctx, query, args)
+		*m.calls = append(*m.calls, "after")
+		m.errs = append(m.errs, err)
+		return result, err
+	}
+}
+
+func TestToHooks(t *testing.T) {
+	var calls []string
+	m := &errMiddleware{recorder: recorder{name: "err", calls: &calls}}
+	sql.Register("sqlite3:tohooks", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, sqlkit.ToHooks(m)))
+	db, err := sql.Open("sqlite3:tohooks", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"before", "after"}, calls)
+	assert.Equal(t, []error{nil}, m.errs)
+
+	calls = nil
+	_, err = db.ExecContext(ctx, "INSERT INTO t2 VALUES(1)")
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"before", "after"}, calls)
+	assert.Equal(t, err, m.errs[1])
+
+	calls = nil
+	rows, err := db.QueryContext(ctx, "SELECT id FROM t1")
+	assert.Nil(t, err)
+	rows.Close()
+	assert.Equal(t, []string{"err:before", "err:after"}, calls)
+
+	mock := sqlkit.NewMock()
+	mock.AddExec("DELETE FROM t1", &sqlkit.Return[driver.Result]{Value: driver.RowsAffected(1)})
+	sql.Register("sqlite3:tohooks:mock", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, sqlkit.ToHooks(mock)))
+	db2, err := sql.Open("sqlite3:tohooks:mock", ":memory:")
+	assert.Nil(t, err)
+	defer db2.Close()
+	_, err = db2.ExecContext(ctx, "DELETE FROM t1")
+	assert.True(t, errors.Is(err, sqlkit.ErrHooksBypassed))
+
+	retry := &retryMiddleware{}
+	sql.Register("sqlite3:tohooks:retry", sqlhooks.Wrap(&sqlite3.SQLiteDriver{}, sqlkit.ToHooks(retry)))
+	db3, err := sql.Open("sqlite3:tohooks:retry", ":memory:")
+	assert.Nil(t, err)
+	defer db3.Close()
+	_, err = db3.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER)")
+	assert.Nil(t, err)
+	assert.Equal(t, []error{nil, sqlkit.ErrHooksRecalled}, retry.errs)
+}
+
+// retryMiddleware calls next twice
+type retryMiddleware struct {
+	recorder
+	errs []error
+}
+
+func (m *retryMiddleware) ExecContext(next sqlkit.ExecContext) sqlkit.ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		result, err := next(ctx, query, args)
+		m.errs = append(m.errs, err)
+		_, err = next(ctx, query, args)
+		m.errs = append(m.errs, err)
+		return result, nil
+	}
+}
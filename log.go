@@ -7,6 +7,7 @@ import (
 	"strconv"
 	"time"
 
+	"github.com/qustavo/sqlhooks/v2"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
@@ -54,6 +55,10 @@ func (h *LogHooks) log(ctx context.Context, query string, err error, args ...int
 	fields = append(fields, zap.String(h.fieldName("query"), query))
 	fields = append(fields, zap.Int64(h.fieldName("rt"), rt))
 	fields = append(fields, zap.String(h.fieldName("trace_id"), logkit.GetReqID(ctx)))
+	for _, field := range append(queryInfoFields(ctx), rewriteFields(ctx)...) {
+		field.Key = h.fieldName(field.Key)
+		fields = append(fields, field)
+	}
 	for i, arg := range args {
 		argi := "arg" + strconv.Itoa(i)
 		if h.FieldSize <= 0 {
@@ -70,6 +75,14 @@ func (h *LogHooks) log(ctx context.Context, query string, err error, args ...int
 	return ctx, nil
 }
 
+func (h *LogHooks) ExecContext(next ExecContext) ExecContext {
+	return FromHooks(h).ExecContext(next)
+}
+
+func (h *LogHooks) QueryContext(next QueryContext) QueryContext {
+	return FromHooks(h).QueryContext(next)
+}
+
 func (h *LogHooks) fieldName(name string) string {
 	if h.FieldNameMap != nil {
 		if v, ok := h.FieldNameMap[name]; ok && v != "" {
@@ -80,3 +93,9 @@ func (h *LogHooks) fieldName(name string) string {
 }
 
 var ctxKeyStartTime = struct{}{}
+
+var (
+	_ sqlhooks.Hooks     = (*LogHooks)(nil)
+	_ sqlhooks.OnErrorer = (*LogHooks)(nil)
+	_ Middleware         = (*LogHooks)(nil)
+)
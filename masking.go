@@ -0,0 +1,585 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrMaskingUnsupported returned by DataMasking if the result columns of the query can not be masked safely,
+// e.g. common table expressions on masked tables, unnamed expressions of masked columns with `*`
+var ErrMaskingUnsupported = errors.New("query can not be masked safely")
+
+// Mask masks the string representation of a value
+type Mask func(s string) string
+
+// builtinMasks masks which can be used by name in `DataMasking.Columns`, besides `partial:<first>,<last>`
+var builtinMasks = map[string]Mask{
+	"phone":   PartialMask(3, 4),
+	"id_card": PartialMask(3, 4),
+	"email":   EmailMask,
+	"full":    FullMask,
+}
+
+// PartialMask reveals the first and last runes and masks the others with `*`, e.g. `PartialMask(3, 4)("13812345678")` is `138****5678`,
+// the value is masked fully if not longer than first+last
+func PartialMask(first, last int) Mask {
+	return func(s string) string {
+		runes := []rune(s)
+		if len(runes) <= first+last {
+			return FullMask(s)
+		}
+		return string(runes[:first]) + strings.Repeat("*", len(runes)-first-last) + string(runes[len(runes)-last:])
+	}
+}
+
+// EmailMask reveals the first rune of the local part and the domain, e.g. `a****@example.com`
+func EmailMask(s string) string {
+	i := strings.LastIndex(s, "@")
+	if i <= 0 {
+		return FullMask(s)
+	}
+	runes := []rune(s[:i])
+	return string(runes[:1]) + strings.Repeat("*", len(runes)-1) + s[i:]
+}
+
+// FullMask masks all runes with `*`
+func FullMask(s string) string {
+	return strings.Repeat("*", len([]rune(s)))
+}
+
+// DataMasking is a middleware which masks the configured columns in the result rows of queries executed on behalf of roles:
+//   - the role is read from `QueryInfo.Role` of ctx by default, the queries without role are not masked unless MaskWithoutRole
+//   - the result columns are mapped to `table.column` by parsing the SELECT list(including `*`, aliases and derived tables),
+//     and the expressions of masked columns are masked fully
+//   - the access of roles in UnmaskedRoles to masked columns is logged as `unmasked access`
+//
+// queries whose result columns can not be mapped are refused with ErrMaskingUnsupported for masked roles.
+// NOTE: masking only protects the results, the conditions, e.g. `phone LIKE '138%'`, are not restricted.
+//
+// Usage:
+//
+//     dm := &sqlkit.DataMasking{
+//         Columns:       map[string]string{"users.phone": "phone", "users.email": "email", "users.address": "partial:6,0"},
+//         UnmaskedRoles: []string{"dba"},
+//     }
+//     err := dm.Provision(ctx)
+//     sql.Register("masking:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, audit, dm))
+//     ctx = sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Role: "support"})
+//
+type DataMasking struct {
+	// Columns masked columns of `table.column` to mask name, i.e. `phone`, `id_card`, `email`, `full`, `partial:<first>,<last>` or names of Masks
+	Columns map[string]string `json:"columns"`
+
+	// UnmaskedRoles roles which see the original values, the access is logged
+	UnmaskedRoles []string `json:"unmasked_roles,omitempty"`
+
+	// MaskWithoutRole masks the queries without role, default is false since the queries of applications usually carry no role
+	MaskWithoutRole bool `json:"mask_without_role,omitempty"`
+
+	// Masks custom masks by name
+	Masks map[string]Mask `json:"-"`
+
+	// RoleFunc return the role of ctx, default is `QueryInfo.Role`
+	RoleFunc func(context.Context) (string, bool) `json:"-"`
+
+	// Cache config of the cache of parsed queries, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser   *parser.Parser
+	mu       sync.Mutex // NOTE: parser is not goroutine safe
+	plans    *Cache[string, *maskingPlan]
+	masks    map[string]Mask
+	tables   map[string]map[string]string // NOTE: table -> column -> mask name, lower case
+	unmasked map[string]struct{}
+	logger   *zap.Logger
+}
+
+// maskingPlan the mask names of result columns, by position, or by name if `*` selected
+type maskingPlan struct {
+	masks  []string
+	names  map[string]string
+	tables []string // NOTE: masked tables touched, for logging
+}
+
+// masked reports whether any result column is masked
+func (plan *maskingPlan) masked() bool {
+	for _, mask := range plan.masks {
+		if mask != "" {
+			return true
+		}
+	}
+	return len(plan.names) > 0
+}
+
+// maskedField a result column, name is empty for unnamed expressions, mask is empty if not masked
+type maskedField struct {
+	name string
+	mask string
+}
+
+func (dm *DataMasking) SetLogger(logger *zap.Logger) error {
+	if logger == nil {
+		return errors.New("nil logger")
+	}
+	dm.logger = logger
+	return nil
+}
+
+func (dm *DataMasking) Provision(ctx context.Context) error {
+	if len(dm.Columns) == 0 {
+		return errors.New("data masking with empty columns")
+	}
+	dm.masks = make(map[string]Mask, len(builtinMasks)+len(dm.Masks))
+	for name, mask := range builtinMasks {
+		dm.masks[name] = mask
+	}
+	for name, mask := range dm.Masks {
+		dm.masks[name] = mask
+	}
+	dm.tables = make(map[string]map[string]string)
+	for column, name := range dm.Columns {
+		i := strings.Index(column, ".")
+		if i <= 0 || i == len(column)-1 {
+			return errors.Errorf("invalid masked column %s, should be `table.column`", column)
+		}
+		if _, err := dm.mask(name); err != nil {
+			return errors.WithMessagef(err, "column %s", column)
+		}
+		table := strings.ToLower(column[:i])
+		if dm.tables[table] == nil {
+			dm.tables[table] = make(map[string]string)
+		}
+		dm.tables[table][strings.ToLower(column[i+1:])] = name
+	}
+	if dm.RoleFunc == nil {
+		dm.RoleFunc = func(ctx context.Context) (string, bool) {
+			info, ok := GetQueryInfo(ctx)
+			return info.Role, ok && info.Role != ""
+		}
+	}
+	if dm.logger == nil {
+		dm.logger = zap.NewNop()
+	}
+	dm.unmasked = make(map[string]struct{}, len(dm.UnmaskedRoles))
+	for _, role := range dm.UnmaskedRoles {
+		dm.unmasked[strings.ToLower(role)] = struct{}{}
+	}
+	dm.parser = parser.New()
+	dm.plans = NewCache[string, *maskingPlan]("data_masking", dm.Cache)
+	return nil
+}
+
+// mask return the mask of name, `partial:<first>,<last>` is parsed
+func (dm *DataMasking) mask(name string) (Mask, error) {
+	if mask, ok := dm.masks[name]; ok {
+		return mask, nil
+	}
+	if s := strings.TrimPrefix(name, "partial:"); s != name {
+		parts := strings.Split(s, ",")
+		if len(parts) == 2 {
+			first, err1 := strconv.Atoi(strings.TrimSpace(parts[0]))
+			last, err2 := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err1 == nil && err2 == nil && first >= 0 && last >= 0 {
+				mask := PartialMask(first, last)
+				dm.masks[name] = mask
+				return mask, nil
+			}
+		}
+	}
+	return nil, errors.Errorf("unknown mask %s", name)
+}
+
+// masked reports whether the results of ctx should be masked, and the role of ctx
+func (dm *DataMasking) masked(ctx context.Context) (string, bool) {
+	role, ok := dm.RoleFunc(ctx)
+	if !ok {
+		return "", dm.MaskWithoutRole
+	}
+	_, unmasked := dm.unmasked[strings.ToLower(role)]
+	return role, !unmasked
+}
+
+func (dm *DataMasking) ExecContext(next ExecContext) ExecContext {
+	return next
+}
+
+func (dm *DataMasking) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		role, masked := dm.masked(ctx)
+		if !masked && role == "" {
+			return next(ctx, query, args)
+		}
+		plan, err := dm.plan(query)
+		if err != nil {
+			if masked { // NOTE: fail closed
+				return nil, err
+			}
+			dm.logger.Warn("unmasked access of unparsed query", append([]zap.Field{
+				zap.String("unmasked_role", role), zap.String("query", query), zap.Error(err)}, queryInfoFields(ctx)...)...)
+		} else if !masked && plan.masked() {
+			dm.logger.Info("unmasked access", append([]zap.Field{
+				zap.String("unmasked_role", role), zap.String("query", query), zap.Strings("tables", plan.tables)}, queryInfoFields(ctx)...)...)
+		}
+		return next(ctx, query, args)
+	}
+}
+
+func (dm *DataMasking) OnRow(next OnRow) OnRow {
+	var (
+		cols  []string
+		masks []Mask
+	)
+	return func(ctx context.Context, query string, columns []string, dest []driver.Value) error {
+		if err := next(ctx, query, columns, dest); err != nil {
+			return err
+		}
+		if len(columns) != len(cols) || len(columns) > 0 && &columns[0] != &cols[0] { // NOTE: columns changed on next result set
+			cols, masks = columns, nil
+			if _, masked := dm.masked(ctx); masked {
+				plan, err := dm.plan(query)
+				if err != nil {
+					return err
+				}
+				masks = dm.columnMasks(plan, columns)
+			}
+		}
+		for i, mask := range masks {
+			if mask != nil && i < len(dest) {
+				dest[i] = maskValue(mask, dest[i])
+			}
+		}
+		return nil
+	}
+}
+
+func (dm *DataMasking) OnClose(next OnClose) OnClose {
+	return next
+}
+
+// columnMasks return the masks of result columns, NOTE: all columns are masked fully if the columns mismatch the plan
+func (dm *DataMasking) columnMasks(plan *maskingPlan, columns []string) []Mask {
+	var (
+		masks  = make([]Mask, len(columns))
+		masked bool
+	)
+	for i, column := range columns {
+		var name string
+		if plan.names != nil {
+			name = plan.names[strings.ToLower(column)]
+		} else if len(plan.masks) == len(columns) {
+			name = plan.masks[i]
+		} else if len(plan.masks) > 0 {
+			name = "full"
+		}
+		if name != "" {
+			masks[i], _ = dm.mask(name)
+			masked = true
+		}
+	}
+	if !masked {
+		return nil
+	}
+	return masks
+}
+
+// maskValue masks the value as string, nil is not masked
+func maskValue(mask Mask, value driver.Value) driver.Value {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return mask(v)
+	case []byte:
+		return []byte(mask(string(v)))
+	case int64:
+		return mask(strconv.FormatInt(v, 10))
+	case float64:
+		return mask(strconv.FormatFloat(v, 'g', -1, 64))
+	case bool:
+		return mask(strconv.FormatBool(v))
+	case time.Time:
+		return mask(v.Format(time.RFC3339Nano))
+	default:
+		return "******"
+	}
+}
+
+func (dm *DataMasking) plan(sql string) (*maskingPlan, error) {
+	if plan, ok := dm.plans.Load(sql); ok {
+		return plan, nil
+	}
+	dm.mu.Lock()
+	stmtNodes, warns, err := dm.parser.Parse(sql, "", "")
+	dm.mu.Unlock()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parse sql failed: %s", sql)
+	}
+	if len(warns) > 0 {
+		dm.logger.Debug("data masking warnings", zap.Any("warns", warns), zap.String("sql", sql))
+	}
+	plan := &maskingPlan{}
+	for _, stmtNode := range stmtNodes {
+		tables, err := dm.planStmt(stmtNode, plan)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "sql: %s", sql)
+		}
+		if len(tables) > 0 && len(stmtNodes) > 1 {
+			return nil, errors.WithMessagef(ErrMaskingUnsupported, "multiple statements on masked tables: %s", sql)
+		}
+		plan.tables = append(plan.tables, tables...)
+	}
+	dm.plans.Store(sql, plan)
+	return plan, nil
+}
+
+// planStmt fills the masks of the result columns, and return the masked tables touched
+func (dm *DataMasking) planStmt(stmtNode ast.StmtNode, plan *maskingPlan) ([]string, error) {
+	c := &nodeCollector{}
+	stmtNode.Accept(c)
+	var (
+		tables []string
+		seen   = make(map[string]struct{})
+		with   bool
+	)
+	for _, n := range c.nodes {
+		switch n := n.(type) {
+		case *ast.TableName:
+			if _, ok := dm.tables[n.Name.L]; !ok {
+				continue
+			}
+			if _, ok := seen[n.Name.L]; !ok {
+				seen[n.Name.L] = struct{}{}
+				tables = append(tables, n.Name.L)
+			}
+		case *ast.WithClause:
+			with = true
+		}
+	}
+	if len(tables) == 0 {
+		return nil, nil
+	}
+	sort.Strings(tables)
+	rs, ok := stmtNode.(ast.ResultSetNode)
+	if !ok { // NOTE: only the results of SELECT are masked
+		return tables, nil
+	}
+	if with {
+		return nil, errors.WithMessage(ErrMaskingUnsupported, "common table expression on masked tables")
+	}
+	fields, wildcard, err := dm.resolve(rs)
+	if err != nil {
+		return nil, err
+	}
+	if !wildcard {
+		plan.masks = make([]string, len(fields))
+		for i, field := range fields {
+			plan.masks[i] = field.mask
+		}
+		return tables, nil
+	}
+	if plan.names, err = namedMasks(fields); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
+
+// resolve return the result columns of the result set, and whether `*` is selected
+func (dm *DataMasking) resolve(node ast.Node) ([]maskedField, bool, error) {
+	switch n := node.(type) {
+	case *ast.SelectStmt:
+		return dm.resolveSelect(n)
+	case *ast.SetOprStmt:
+		return dm.resolve(n.SelectList)
+	case *ast.SetOprSelectList:
+		var (
+			fields   []maskedField
+			wildcard bool
+		)
+		for i, sel := range n.Selects {
+			fs, w, err := dm.resolve(sel)
+			if err != nil {
+				return nil, false, err
+			}
+			if i == 0 {
+				fields, wildcard = fs, w
+				continue
+			}
+			if w || wildcard {
+				for _, f := range append(fs, fields...) {
+					if f.mask != "" {
+						return nil, false, errors.WithMessage(ErrMaskingUnsupported, "set operation with `*` on masked tables")
+					}
+				}
+			}
+			for j := range fields { // NOTE: the columns are named by the first select
+				if j < len(fs) {
+					fields[j].mask = mergeMask(fields[j].mask, fs[j].mask)
+				}
+			}
+		}
+		return fields, wildcard, nil
+	}
+	return nil, false, errors.WithMessagef(ErrMaskingUnsupported, "unsupported result set %T", node)
+}
+
+func (dm *DataMasking) resolveSelect(sel *ast.SelectStmt) ([]maskedField, bool, error) {
+	scope, err := dm.scope(sel.From)
+	if err != nil {
+		return nil, false, err
+	}
+	var (
+		fields   []maskedField
+		wildcard bool
+	)
+	if sel.Fields == nil {
+		return nil, false, nil
+	}
+	for _, field := range sel.Fields.Fields {
+		if field.WildCard != nil {
+			wildcard = true
+			for _, alias := range scope.aliases {
+				if field.WildCard.Table.L != "" && field.WildCard.Table.L != alias {
+					continue
+				}
+				for column, mask := range scope.tables[alias] {
+					fields = append(fields, maskedField{name: column, mask: mask})
+				}
+			}
+			continue
+		}
+		f := maskedField{name: field.AsName.L}
+		if e, ok := field.Expr.(*ast.ColumnNameExpr); ok {
+			f.mask = scope.lookup(e.Name)
+			if f.name == "" {
+				f.name = e.Name.Name.L
+			}
+		} else if dm.exprMasked(scope, field.Expr) {
+			f.mask = "full"
+		}
+		fields = append(fields, f)
+	}
+	return fields, wildcard, nil
+}
+
+// exprMasked reports whether the expression references masked columns or tables, COUNT is not masked
+func (dm *DataMasking) exprMasked(scope *maskingScope, expr ast.ExprNode) bool {
+	if agg, ok := expr.(*ast.AggregateFuncExpr); ok && strings.ToLower(agg.F) == ast.AggFuncCount {
+		return false
+	}
+	c := &nodeCollector{}
+	expr.Accept(c)
+	for _, n := range c.nodes {
+		switch n := n.(type) {
+		case *ast.ColumnNameExpr:
+			if scope.lookup(n.Name) != "" {
+				return true
+			}
+		case *ast.TableName:
+			if _, ok := dm.tables[n.Name.L]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// maskingScope the masked columns of the tables in FROM, by alias or name
+type maskingScope struct {
+	aliases []string
+	tables  map[string]map[string]string
+}
+
+func (dm *DataMasking) scope(from *ast.TableRefsClause) (*maskingScope, error) {
+	scope := &maskingScope{tables: make(map[string]map[string]string)}
+	if from == nil {
+		return scope, nil
+	}
+	c := &nodeCollector{skipSubquery: true}
+	from.TableRefs.Accept(c)
+	for _, n := range c.nodes {
+		ts, ok := n.(*ast.TableSource)
+		if !ok {
+			continue
+		}
+		switch src := ts.Source.(type) {
+		case *ast.TableName:
+			alias := ts.AsName.L
+			if alias == "" {
+				alias = src.Name.L
+			}
+			scope.add(alias, dm.tables[src.Name.L])
+		case *ast.SelectStmt, *ast.SetOprStmt:
+			fields, _, err := dm.resolve(src)
+			if err != nil {
+				return nil, err
+			}
+			columns, err := namedMasks(fields)
+			if err != nil {
+				return nil, err
+			}
+			scope.add(ts.AsName.L, columns)
+		}
+	}
+	return scope, nil
+}
+
+func (scope *maskingScope) add(alias string, columns map[string]string) {
+	if _, ok := scope.tables[alias]; !ok {
+		scope.aliases = append(scope.aliases, alias)
+	}
+	scope.tables[alias] = columns
+}
+
+// lookup return the mask of the column, the masks of the same unqualified column in different tables are merged
+func (scope *maskingScope) lookup(name *ast.ColumnName) string {
+	if name.Table.L != "" {
+		return scope.tables[name.Table.L][name.Name.L]
+	}
+	var mask string
+	for _, alias := range scope.aliases {
+		mask = mergeMask(mask, scope.tables[alias][name.Name.L])
+	}
+	return mask
+}
+
+// namedMasks return the masks of the result columns by name
+func namedMasks(fields []maskedField) (map[string]string, error) {
+	names := make(map[string]string)
+	for _, field := range fields {
+		if field.mask == "" {
+			continue
+		}
+		if field.name == "" {
+			return nil, errors.WithMessage(ErrMaskingUnsupported, "unnamed expression of masked columns")
+		}
+		names[field.name] = mergeMask(names[field.name], field.mask)
+	}
+	return names, nil
+}
+
+// mergeMask return the mask of a column which may be masked by both, `full` if they differ
+func mergeMask(a, b string) string {
+	switch {
+	case a == "":
+		return b
+	case b == "" || a == b:
+		return a
+	default:
+		return "full"
+	}
+}
+
+var (
+	_ Middleware     = (*DataMasking)(nil)
+	_ RowsMiddleware = (*DataMasking)(nil)
+)
@@ -0,0 +1,113 @@
+package sqlkit_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestMasks(t *testing.T) {
+	assert.Equal(t, "138****5678", sqlkit.PartialMask(3, 4)("13812345678"))
+	assert.Equal(t, "****", sqlkit.PartialMask(3, 4)("1234"))
+	assert.Equal(t, "张*三", sqlkit.PartialMask(1, 1)("张二三"))
+	assert.Equal(t, "a****@example.com", sqlkit.EmailMask("alice@example.com"))
+	assert.Equal(t, "*****", sqlkit.EmailMask("alice"))
+	assert.Equal(t, "***", sqlkit.FullMask("abc"))
+}
+
+func TestDataMasking(t *testing.T) {
+	b := &bytes.Buffer{}
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(b), zapcore.InfoLevel))
+	dm := &sqlkit.DataMasking{
+		Columns: map[string]string{
+			"users.phone":   "phone",
+			"users.id_card": "id_card",
+			"users.email":   "email",
+			"users.address": "partial:2,0",
+		},
+		UnmaskedRoles: []string{"dba"},
+	}
+	assert.Nil(t, dm.SetLogger(logger))
+	ctx := context.Background()
+	assert.Nil(t, dm.Provision(ctx))
+	sql.Register("sqlite3:masking", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, dm))
+	db, err := sql.Open("sqlite3:masking", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT, phone TEXT, id_card TEXT, email TEXT, address TEXT)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO users VALUES (1, 'alice', '13812345678', '110101199001011234', 'alice@example.com', 'Beijing'), "+
+		"(2, 'bob', '13900001111', NULL, 'bob@example.com', 'Shanghai')")
+	assert.Nil(t, err)
+
+	var (
+		name, phone, email, address string
+		idCard                      sql.NullString
+	)
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT phone FROM users WHERE id = ?", 1).Scan(&phone))
+	assert.Equal(t, "13812345678", phone) // NOTE: no role
+
+	support := sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Role: "support"})
+	assert.Nil(t, db.QueryRowContext(support, "SELECT u.name, u.phone AS p, id_card, email, address FROM users u WHERE id = ?", 1).
+		Scan(&name, &phone, &idCard, &email, &address))
+	assert.Equal(t, "alice", name)
+	assert.Equal(t, "138****5678", phone)
+	assert.Equal(t, "110***********1234", idCard.String)
+	assert.Equal(t, "a****@example.com", email)
+	assert.Equal(t, "Be*****", address)
+
+	rows, err := db.QueryContext(support, "SELECT * FROM users ORDER BY id")
+	assert.Nil(t, err)
+	var phones []string
+	for rows.Next() {
+		var id int
+		assert.Nil(t, rows.Scan(&id, &name, &phone, &idCard, &email, &address))
+		phones = append(phones, phone)
+		if id == 2 {
+			assert.False(t, idCard.Valid)
+		}
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []string{"138****5678", "139****1111"}, phones)
+
+	for query, expected := range map[string]string{
+		"SELECT x.p FROM (SELECT phone AS p FROM users WHERE id = 1) x":                                  "138****5678",
+		"SELECT upper(email) FROM users WHERE id = 1":                                                    "*****************",
+		"SELECT name FROM users WHERE id = 1 UNION SELECT phone FROM users WHERE id = 2 ORDER BY 1 DESC": "*****",
+	} {
+		assert.Nilf(t, db.QueryRowContext(support, query).Scan(&phone), query)
+		assert.Equalf(t, expected, phone, query)
+	}
+	var count int
+	assert.Nil(t, db.QueryRowContext(support, "SELECT COUNT(phone) FROM users").Scan(&count))
+	assert.Equal(t, 2, count)
+
+	_, err = db.QueryContext(support, "WITH x AS (SELECT phone FROM users) SELECT phone FROM x")
+	assert.True(t, errors.Is(err, sqlkit.ErrMaskingUnsupported))
+	_, err = db.QueryContext(support, "SELECT *, phone || '' FROM users")
+	assert.True(t, errors.Is(err, sqlkit.ErrMaskingUnsupported))
+
+	assert.Equal(t, 0, b.Len())
+	dba := sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "user.phone", Role: "dba"})
+	assert.Nil(t, db.QueryRowContext(dba, "SELECT phone FROM users WHERE id = ?", 1).Scan(&phone))
+	assert.Equal(t, "13812345678", phone)
+	assert.Nil(t, db.QueryRowContext(dba, "SELECT COUNT(*) FROM users u1, users u2").Scan(&count))
+	assert.Nil(t, db.QueryRowContext(dba, "SELECT 1").Scan(&count))
+	log := b.String()
+	assert.Equal(t, 1, strings.Count(log, "unmasked access"))
+	assert.Contains(t, log, `"unmasked_role":"dba"`)
+	assert.Contains(t, log, `"tables":["users"]`)
+	assert.Contains(t, log, `"query_name":"user.phone"`)
+}
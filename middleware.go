@@ -41,6 +41,45 @@ type ExecContext func(ctx context.Context, query string, args []driver.NamedValu
 
 type QueryContext func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error)
 
+// TxMiddleware is an optional extension of Middleware which intercepts the transaction lifecycle,
+// the ctx passed to `Commit` and `Rollback` is the one which reached the driver in `BeginTx`,
+// so values injected by middlewares in `BeginTx` can be retrieved when the transaction ends.
+type TxMiddleware interface {
+	BeginTx(BeginTx) BeginTx
+	Commit(Commit) Commit
+	Rollback(Rollback) Rollback
+}
+
+type BeginTx func(ctx context.Context, opts driver.TxOptions) (driver.Tx, error)
+
+type Commit func(ctx context.Context) error
+
+type Rollback func(ctx context.Context) error
+
+// PrepareMiddleware is an optional extension of Middleware which intercepts statement preparing and closing,
+// the query passed to `PrepareContext` can be rewritten or rejected before it is prepared on the server,
+// the ctx and query passed to `CloseStmt` are the ones passed to `PrepareContext` by the application.
+type PrepareMiddleware interface {
+	PrepareContext(PrepareContext) PrepareContext
+	CloseStmt(CloseStmt) CloseStmt
+}
+
+type PrepareContext func(ctx context.Context, query string) (driver.Stmt, error)
+
+type CloseStmt func(ctx context.Context, query string) error
+
+// ArgsMiddleware is an optional extension of Middleware which checks the args before they are converted by database/sql,
+// e.g. accepts the slices which are expanded by the middleware later, `next` returns `driver.ErrSkip` if the underlying
+// conn does not implement driver.NamedValueChecker, i.e. the default conversion of database/sql is used.
+// NOTE: only the conn is checked, the statements prepared are checked by the underlying driver.
+type ArgsMiddleware interface {
+	CheckNamedValue(CheckNamedValue) CheckNamedValue
+}
+
+type CheckNamedValue func(nv *driver.NamedValue) error
+
+//go:generate go run gen_wrappers.go
+
 // Wrap is used to create a new instrumented driver, it takes a vendor specific driver, and a Hooks instance to produce a new driver instance.
 // It's usually used inside a sql.Register() statement
 func Wrap(driver driver.Driver, wrapper Middleware) driver.Driver {
@@ -53,71 +92,214 @@ type Driver struct {
 	wrapper Middleware
 }
 
+// Middleware return the middleware which wraps the driver
+func (drv *Driver) Middleware() Middleware {
+	return drv.wrapper
+}
+
 // Open opens a connection
 func (drv *Driver) Open(name string) (driver.Conn, error) {
-	conn, err := drv.Driver.Open(name)
-	if err != nil {
-		return conn, err
+	return openConn(context.Background(), name, drv.wrapper, func(context.Context) (driver.Conn, error) {
+		return drv.Driver.Open(name)
+	})
+}
+
+// OpenConnector implements database/sql/driver.DriverContext, if the underlying driver does not implement it,
+// a connector which calls `drv.Open(name)` will be returned
+func (drv *Driver) OpenConnector(name string) (driver.Connector, error) {
+	if dc, ok := drv.Driver.(driver.DriverContext); ok {
+		connector, err := dc.OpenConnector(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Connector{connector, drv.wrapper, name}, nil
 	}
+	return &dsnConnector{name: name, driver: drv}, nil
+}
+
+// NewConnector is used to create a new instrumented connector, which can be used with sql.OpenDB without global registration,
+// e.g. `sql.OpenDB(sqlkit.NewConnector(connector, mock))`
+func NewConnector(connector driver.Connector, wrapper Middleware) driver.Connector {
+	return &Connector{connector, wrapper, ""}
+}
+
+// Connector implements a database/sql/driver.Connector
+type Connector struct {
+	Connector driver.Connector
+	wrapper   Middleware
+	dsn       string // NOTE: empty if created by NewConnector
+}
 
+// Connect opens a connection
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	return openConn(ctx, c.dsn, c.wrapper, c.Connector.Connect)
+}
+
+// Driver returns the wrapped driver of the underlying connector
+func (c *Connector) Driver() driver.Driver {
+	return &Driver{c.Connector.Driver(), c.wrapper}
+}
+
+// dsnConnector used for drivers which does not implement driver.DriverContext
+type dsnConnector struct {
+	name   string
+	driver *Driver
+}
+
+func (c *dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return openConn(ctx, c.name, c.driver.wrapper, func(context.Context) (driver.Conn, error) {
+		return c.driver.Driver.Open(c.name)
+	})
+}
+
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func wrapConn(conn driver.Conn, wrapper Middleware, stats *connStats) (driver.Conn, error) {
 	// Drivers that don't implement driver.ConnBeginTx are not supported.
 	if _, ok := conn.(driver.ConnBeginTx); !ok {
 		return nil, errors.New("driver must implement driver.ConnBeginTx")
 	}
 
-	wrapped := &Conn{conn, drv.wrapper}
-	if isExecer(conn) && isQueryer(conn) && isSessionResetter(conn) {
-		return &ExecerQueryerContextWithSessionResetter{wrapped,
-			&ExecerContext{wrapped}, &QueryerContext{wrapped},
-			&SessionResetter{wrapped}}, nil
-	} else if isExecer(conn) && isQueryer(conn) {
-		return &ExecerQueryerContext{wrapped, &ExecerContext{wrapped},
-			&QueryerContext{wrapped}}, nil
-	} else if isExecer(conn) {
-		// If conn implements an Execer interface, return a driver.Conn which
-		// also implements Execer
-		return &ExecerContext{wrapped}, nil
-	} else if isQueryer(conn) {
-		// If conn implements an Queryer interface, return a driver.Conn which
-		// also implements Queryer
-		return &QueryerContext{wrapped}, nil
+	return wrapConnOptionals(&Conn{conn, wrapper, stats}, optionalsOfConn(conn, wrapper)), nil
+}
+
+func optionalsOfConn(conn driver.Conn, wrapper Middleware) connOptional {
+	var mask connOptional
+	if isExecer(conn) {
+		mask |= connExecer
+	}
+	if isQueryer(conn) {
+		mask |= connQueryer
+	}
+	if isSessionResetter(conn) {
+		mask |= connSessionResetter
+	}
+	if _, ok := conn.(driver.Pinger); ok {
+		mask |= connPinger
+	}
+	if _, ok := conn.(driver.NamedValueChecker); ok {
+		mask |= connNamedValueChecker
+	} else if _, ok := wrapper.(ArgsMiddleware); ok {
+		mask |= connNamedValueChecker
+	}
+	if _, ok := conn.(driver.Validator); ok {
+		mask |= connValidator
+	}
+	return mask
+}
+
+func optionalsOfStmt(stmt driver.Stmt) stmtOptional {
+	var mask stmtOptional
+	if _, ok := stmt.(driver.NamedValueChecker); ok {
+		mask |= stmtNamedValueChecker
 	}
-	return wrapped, nil
+	if _, ok := stmt.(driver.ColumnConverter); ok {
+		mask |= stmtColumnConverter
+	}
+	return mask
 }
 
 // Conn implements a database/sql.driver.Conn
 type Conn struct {
 	Conn    driver.Conn
 	wrapper Middleware
+	stats   *connStats
+}
+
+func (conn *Conn) prepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if c, ok := conn.Conn.(driver.ConnPrepareContext); ok {
+		return c.PrepareContext(ctx, query)
+	}
+	// same as database/sql for drivers which does not implement driver.ConnPrepareContext
+	stmt, err := conn.Prepare(query)
+	if err == nil {
+		select {
+		default:
+		case <-ctx.Done():
+			stmt.Close()
+			return nil, ctx.Err()
+		}
+	}
+	return stmt, err
 }
 
 func (conn *Conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
 	var (
-		stmt driver.Stmt
-		err  error
+		stmt     driver.Stmt
+		err      error
+		prepared = query
 	)
 
-	if c, ok := conn.Conn.(driver.ConnPrepareContext); ok {
-		stmt, err = c.PrepareContext(ctx, query)
+	pm, ok := conn.wrapper.(PrepareMiddleware)
+	if ok {
+		stmt, err = pm.PrepareContext(func(ctx context.Context, query string) (driver.Stmt, error) {
+			prepared = query
+			return conn.prepareContext(ctx, query)
+		})(ctx, query)
 	} else {
-		stmt, err = conn.Prepare(query)
+		stmt, err = conn.prepareContext(ctx, query)
 	}
 
 	if err != nil {
 		return stmt, err
 	}
 
-	return &Stmt{
-		Stmt:    stmt,
-		query:   query,
-		wrapper: conn.wrapper}, nil
+	return wrapStmtOptionals(&Stmt{
+		Stmt:      stmt,
+		ctx:       ctx,
+		query:     query,
+		rewritten: prepared != query,
+		stats:     conn.stats,
+		wrapper:   conn.wrapper}, optionalsOfStmt(stmt)), nil
 }
 
 func (conn *Conn) Prepare(query string) (driver.Stmt, error) { return conn.Conn.Prepare(query) }
-func (conn *Conn) Close() error                              { return conn.Conn.Close() }
-func (conn *Conn) Begin() (driver.Tx, error)                 { return conn.Conn.Begin() }
+func (conn *Conn) Begin() (driver.Tx, error) {
+	return conn.BeginTx(context.Background(), driver.TxOptions{})
+}
+
 func (conn *Conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
-	return conn.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	tm, ok := conn.wrapper.(TxMiddleware)
+	if !ok {
+		return conn.Conn.(driver.ConnBeginTx).BeginTx(ctx, opts)
+	}
+	var txCtx context.Context
+	tx, err := tm.BeginTx(func(c context.Context, o driver.TxOptions) (driver.Tx, error) {
+		txCtx = c
+		return conn.Conn.(driver.ConnBeginTx).BeginTx(c, o)
+	})(ctx, opts)
+	if err != nil {
+		return tx, err
+	}
+	if txCtx == nil { // NOTE: the driver is bypassed by a middleware, e.g. mock
+		txCtx = ctx
+	}
+	return &Tx{
+		Tx:      tx,
+		ctx:     txCtx,
+		wrapper: tm,
+	}, nil
+}
+
+// Tx implements a database/sql/driver.Tx
+type Tx struct {
+	Tx      driver.Tx
+	ctx     context.Context
+	wrapper TxMiddleware
+}
+
+func (tx *Tx) Commit() error {
+	return tx.wrapper.Commit(func(context.Context) error {
+		return tx.Tx.Commit()
+	})(tx.ctx)
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.wrapper.Rollback(func(context.Context) error {
+		return tx.Tx.Rollback()
+	})(tx.ctx)
 }
 
 // ExecerContext implements a database/sql.driver.ExecerContext
@@ -153,6 +335,7 @@ func (conn *ExecerContext) execContext(ctx context.Context, query string, args [
 }
 
 func (conn *ExecerContext) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	conn.stats.used(query)
 	return conn.wrapper.ExecContext(conn.execContext)(ctx, query, args)
 }
 
@@ -197,7 +380,8 @@ func (conn *QueryerContext) queryContext(ctx context.Context, query string, args
 }
 
 func (conn *QueryerContext) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-	return conn.wrapper.QueryContext(conn.queryContext)(ctx, query, args)
+	conn.stats.used(query)
+	return wrapQueryContext(conn.wrapper, conn.queryContext)(ctx, query, args)
 }
 
 // ExecerQueryerContext implements database/sql.driver.ExecerContext and
@@ -221,11 +405,58 @@ type SessionResetter struct {
 	*Conn
 }
 
+// Pinger implements database/sql.driver.Pinger
+type Pinger struct {
+	*Conn
+}
+
+func (p *Pinger) Ping(ctx context.Context) error {
+	return p.Conn.Conn.(driver.Pinger).Ping(ctx)
+}
+
+// NamedValueChecker implements database/sql.driver.NamedValueChecker
+type NamedValueChecker struct {
+	*Conn
+}
+
+func (c *NamedValueChecker) CheckNamedValue(nv *driver.NamedValue) error {
+	next := func(nv *driver.NamedValue) error {
+		if checker, ok := c.Conn.Conn.(driver.NamedValueChecker); ok {
+			return checker.CheckNamedValue(nv)
+		}
+		return driver.ErrSkip
+	}
+	if am, ok := c.Conn.wrapper.(ArgsMiddleware); ok {
+		return am.CheckNamedValue(next)(nv)
+	}
+	return next(nv)
+}
+
+// Validator implements database/sql.driver.Validator
+type Validator struct {
+	*Conn
+}
+
+func (v *Validator) IsValid() bool {
+	return v.Conn.Conn.(driver.Validator).IsValid()
+}
+
 // Stmt implements a database/sql/driver.Stmt
 type Stmt struct {
-	Stmt    driver.Stmt
-	query   string
-	wrapper Middleware
+	Stmt      driver.Stmt
+	ctx       context.Context
+	query     string
+	rewritten bool // NOTE: the prepared query is rewritten by PrepareMiddleware
+	stats     *connStats
+	wrapper   Middleware
+}
+
+type stmtCtxKey struct{}
+
+// GetStmtContext return the ctx used to prepare the statement if the query is executed by a prepared statement
+func GetStmtContext(ctx context.Context) (context.Context, bool) {
+	stmtCtx, ok := ctx.Value(stmtCtxKey{}).(context.Context)
+	return stmtCtx, ok
 }
 
 func (stmt *Stmt) execContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
@@ -242,6 +473,8 @@ func (stmt *Stmt) execContext(ctx context.Context, args []driver.NamedValue) (dr
 }
 
 func (stmt *Stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	stmt.stats.used(stmt.query)
+	ctx = context.WithValue(ctx, stmtCtxKey{}, stmt.ctx)
 	return stmt.wrapper.ExecContext(func(c context.Context, q string, a []driver.NamedValue) (driver.Result, error) {
 		return stmt.execContext(c, a)
 	})(ctx, stmt.query, args)
@@ -260,13 +493,49 @@ func (stmt *Stmt) queryContext(ctx context.Context, args []driver.NamedValue) (d
 }
 
 func (stmt *Stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
-	return stmt.wrapper.QueryContext(func(c context.Context, q string, a []driver.NamedValue) (driver.Rows, error) {
+	stmt.stats.used(stmt.query)
+	ctx = context.WithValue(ctx, stmtCtxKey{}, stmt.ctx)
+	return wrapQueryContext(stmt.wrapper, func(c context.Context, q string, a []driver.NamedValue) (driver.Rows, error) {
 		return stmt.queryContext(c, a)
 	})(ctx, stmt.query, args)
 }
 
-func (stmt *Stmt) Close() error                                    { return stmt.Stmt.Close() }
-func (stmt *Stmt) NumInput() int                                   { return stmt.Stmt.NumInput() }
+func (stmt *Stmt) Close() error {
+	pm, ok := stmt.wrapper.(PrepareMiddleware)
+	if !ok {
+		return stmt.Stmt.Close()
+	}
+	return pm.CloseStmt(func(context.Context, string) error {
+		return stmt.Stmt.Close()
+	})(stmt.ctx, stmt.query)
+}
+
+// StmtNamedValueChecker implements database/sql.driver.NamedValueChecker for Stmt
+type StmtNamedValueChecker struct {
+	*Stmt
+}
+
+func (s *StmtNamedValueChecker) CheckNamedValue(nv *driver.NamedValue) error {
+	return s.Stmt.Stmt.(driver.NamedValueChecker).CheckNamedValue(nv)
+}
+
+// StmtColumnConverter implements database/sql.driver.ColumnConverter for Stmt
+type StmtColumnConverter struct {
+	*Stmt
+}
+
+func (s *StmtColumnConverter) ColumnConverter(idx int) driver.ValueConverter {
+	return s.Stmt.Stmt.(driver.ColumnConverter).ColumnConverter(idx)
+}
+
+// NumInput return -1 if the prepared query is rewritten, since the number of placeholders may differ from the original
+func (stmt *Stmt) NumInput() int {
+	if stmt.rewritten {
+		return -1
+	}
+	return stmt.Stmt.NumInput()
+}
+
 func (stmt *Stmt) Exec(args []driver.Value) (driver.Result, error) { return stmt.Stmt.Exec(args) }
 func (stmt *Stmt) Query(args []driver.Value) (driver.Rows, error)  { return stmt.Stmt.Query(args) }
 
@@ -295,7 +564,7 @@ func isSessionResetter(conn driver.Conn) bool {
 	return ok
 }
 
-func (s *SessionResetter) ResetSession(ctx context.Context) error {
-	c := s.Conn.Conn.(driver.SessionResetter)
-	return c.ResetSession(ctx)
-}
+var (
+	_ driver.DriverContext = (*Driver)(nil)
+	_ driver.Connector     = (*Connector)(nil)
+)
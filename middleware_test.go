@@ -0,0 +1,296 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+type txCtxKey struct{}
+
+type txRecorder struct {
+	recorder
+	opts []driver.TxOptions
+}
+
+func (r *txRecorder) BeginTx(next sqlkit.BeginTx) sqlkit.BeginTx {
+	return func(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+		*r.calls = append(*r.calls, r.name+":begin")
+		r.opts = append(r.opts, opts)
+		return next(context.WithValue(ctx, txCtxKey{}, r.name), opts)
+	}
+}
+
+func (r *txRecorder) Commit(next sqlkit.Commit) sqlkit.Commit {
+	return func(ctx context.Context) error {
+		*r.calls = append(*r.calls, r.name+":commit:"+ctx.Value(txCtxKey{}).(string))
+		return next(ctx)
+	}
+}
+
+func (r *txRecorder) Rollback(next sqlkit.Rollback) sqlkit.Rollback {
+	return func(ctx context.Context) error {
+		*r.calls = append(*r.calls, r.name+":rollback:"+ctx.Value(txCtxKey{}).(string))
+		return next(ctx)
+	}
+}
+
+func TestTxMiddleware(t *testing.T) {
+	var calls []string
+	tr := &txRecorder{recorder: recorder{name: "tx", calls: &calls}}
+	sql.Register("sqlite3:tx", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, tr))
+	db, err := sql.Open("sqlite3:tx", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+
+	calls = nil
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Rollback())
+	assert.Equal(t, []string{"tx:begin", "tx:rollback:tx"}, calls)
+	assert.Equal(t, []driver.TxOptions{{ReadOnly: true}}, tr.opts)
+
+	calls = nil
+	tx, err = db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	_, err = tx.ExecContext(ctx, "INSERT INTO t1 (text) VALUES(?)", "foo")
+	assert.Nil(t, err)
+	assert.Nil(t, tx.Commit())
+	assert.Equal(t, []string{"tx:begin", "tx:before", "tx:after", "tx:commit:tx"}, calls)
+}
+
+func TestMockTx(t *testing.T) {
+	mock := sqlkit.NewMock(sqlkit.WithMockTx(true))
+	mock.AddExec("UPDATE t1 SET text = ?", sqlkit.NewReturn[driver.Result](driver.RowsAffected(2), nil))
+	sql.Register("sqlite3:mocktx", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, mock))
+	db, err := sql.Open("sqlite3:mocktx", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	ctx := context.Background()
+
+	tx, err := db.BeginTx(ctx, nil)
+	assert.Nil(t, err)
+	result, err := tx.ExecContext(ctx, "UPDATE t1 SET text = ?", "foo")
+	assert.Nil(t, err)
+	affected, err := result.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), affected)
+	_, err = tx.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.True(t, errors.Is(err, sqlkit.ErrMockTxMiss))
+	assert.Nil(t, tx.Rollback())
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+}
+
+type prepareRecorder struct {
+	recorder
+	rewrites map[string]string
+}
+
+func (r *prepareRecorder) PrepareContext(next sqlkit.PrepareContext) sqlkit.PrepareContext {
+	return func(ctx context.Context, query string) (driver.Stmt, error) {
+		*r.calls = append(*r.calls, r.name+":prepare")
+		if rewritten, ok := r.rewrites[query]; ok {
+			query = rewritten
+		}
+		return next(ctx, query)
+	}
+}
+
+func (r *prepareRecorder) CloseStmt(next sqlkit.CloseStmt) sqlkit.CloseStmt {
+	return func(ctx context.Context, query string) error {
+		*r.calls = append(*r.calls, r.name+":close:"+query)
+		return next(ctx, query)
+	}
+}
+
+func TestPrepareMiddleware(t *testing.T) {
+	var calls []string
+	pr := &prepareRecorder{
+		recorder: recorder{name: "p", calls: &calls},
+		rewrites: map[string]string{
+			"SELECT text FROM t1": "SELECT text FROM t2",
+		},
+	}
+	sql.Register("sqlite3:prepare", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, pr))
+	db, err := sql.Open("sqlite3:prepare", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t2 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t2 (text) VALUES(?)", "foo")
+	assert.Nil(t, err)
+
+	calls = nil
+	stmt, err := db.PrepareContext(ctx, "SELECT text FROM t1")
+	assert.Nil(t, err)
+	var text string
+	err = stmt.QueryRowContext(ctx).Scan(&text)
+	assert.Nil(t, err)
+	assert.Equal(t, "foo", text)
+	assert.Nil(t, stmt.Close())
+	assert.Equal(t, []string{"p:prepare", "p:before", "p:after", "p:close:SELECT text FROM t1"}, calls)
+}
+
+func TestConnector(t *testing.T) {
+	ctx := context.Background()
+	query := "SELECT id FROM t1"
+	openDB := func(mock *sqlkit.Mock) *sql.DB {
+		drv := sqlkit.Wrap(&sqlite3.SQLiteDriver{}, mock)
+		connector, err := drv.(driver.DriverContext).OpenConnector(":memory:")
+		assert.Nil(t, err)
+		assert.Equal(t, drv, connector.Driver())
+		return sql.OpenDB(connector)
+	}
+	mock1 := sqlkit.NewMock()
+	mock1.AddQuery(query, sqlkit.NewReturn[driver.Rows](sqlkit.NewRows([]string{"id"}).AddRow(1), nil))
+	db1 := openDB(mock1)
+	defer db1.Close()
+	mock2 := sqlkit.NewMock()
+	mock2.AddQuery(query, sqlkit.NewReturn[driver.Rows](sqlkit.NewRows([]string{"id"}).AddRow(2), nil))
+	db2 := openDB(mock2)
+	defer db2.Close()
+
+	var id int
+	assert.Nil(t, db1.QueryRowContext(ctx, query).Scan(&id))
+	assert.Equal(t, 1, id)
+	assert.Nil(t, db2.QueryRowContext(ctx, query).Scan(&id))
+	assert.Equal(t, 2, id)
+
+	cfg := mysql.NewConfig()
+	connector, err := mysql.NewConnector(cfg)
+	assert.Nil(t, err)
+	wrapped := sqlkit.NewConnector(connector, mock1)
+	assert.IsType(t, &sqlkit.Driver{}, wrapped.Driver())
+	assert.Equal(t, mock1, wrapped.Driver().(*sqlkit.Driver).Middleware())
+}
+
+type fakeConn struct{}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (c *fakeConn) Close() error                              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)                 { return &sqlkit.EmptyTx{}, nil }
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	return &sqlkit.EmptyTx{}, nil
+}
+
+type fakePingerConn struct{ fakeConn }
+
+func (c *fakePingerConn) Ping(ctx context.Context) error { return nil }
+
+type fakeValidatorConn struct{ fakeConn }
+
+func (c *fakeValidatorConn) IsValid() bool                               { return false }
+func (c *fakeValidatorConn) ResetSession(ctx context.Context) error      { return nil }
+func (c *fakeValidatorConn) CheckNamedValue(nv *driver.NamedValue) error { return nil }
+
+type fakeDriver struct {
+	conn driver.Conn
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	return d.conn, nil
+}
+
+func TestOptionalInterfaces(t *testing.T) {
+	var cases = []struct {
+		conn   driver.Conn
+		expect []bool // ExecerContext, QueryerContext, SessionResetter, Pinger, NamedValueChecker, Validator
+	}{
+		{&fakeConn{}, []bool{false, false, false, false, false, false}},
+		{&fakePingerConn{}, []bool{false, false, false, true, false, false}},
+		{&fakeValidatorConn{}, []bool{false, false, true, false, true, true}},
+		{&sqlite3.SQLiteConn{}, []bool{true, true, false, true, false, false}},
+	}
+	for _, tc := range cases {
+		conn, err := sqlkit.Wrap(&fakeDriver{tc.conn}, sqlkit.NewMock()).Open("")
+		assert.Nil(t, err)
+		_, ok1 := conn.(driver.ExecerContext)
+		_, ok2 := conn.(driver.QueryerContext)
+		_, ok3 := conn.(driver.SessionResetter)
+		_, ok4 := conn.(driver.Pinger)
+		_, ok5 := conn.(driver.NamedValueChecker)
+		_, ok6 := conn.(driver.Validator)
+		_, ok7 := conn.(driver.ConnPrepareContext)
+		_, ok8 := conn.(driver.ConnBeginTx)
+		assert.Equalf(t, tc.expect, []bool{ok1, ok2, ok3, ok4, ok5, ok6}, "%T", tc.conn)
+		assert.Truef(t, ok7 && ok8, "%T", tc.conn)
+	}
+	conn, err := sqlkit.Wrap(&fakeDriver{&fakeValidatorConn{}}, sqlkit.NewMock()).Open("")
+	assert.Nil(t, err)
+	assert.False(t, conn.(driver.Validator).IsValid())
+}
+
+func TestOptionalInterfacesMySQL(t *testing.T) {
+	sql.Register("mysql:optionals", sqlkit.Wrap(&mysql.MySQLDriver{}, sqlkit.NewMock()))
+	db, err := sql.Open("mysql:optionals", dsn)
+	assert.Nil(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	if err := db.PingContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := db.Conn(ctx)
+	assert.Nil(t, err)
+	defer conn.Close()
+	err = conn.Raw(func(dc any) error {
+		_, ok1 := dc.(driver.SessionResetter)
+		_, ok2 := dc.(driver.Pinger)
+		_, ok3 := dc.(driver.NamedValueChecker)
+		_, ok4 := dc.(driver.Validator)
+		assert.Equal(t, []bool{true, true, true, true}, []bool{ok1, ok2, ok3, ok4})
+		return nil
+	})
+	assert.Nil(t, err)
+
+	// uint64 with high bit set is only accepted by the NamedValueChecker of mysql driver
+	var v uint64
+	err = db.QueryRowContext(ctx, "SELECT ?", uint64(1<<63)).Scan(&v)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(1<<63), v)
+	stmt, err := db.PrepareContext(ctx, "SELECT ?")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	err = stmt.QueryRowContext(ctx, uint64(1<<63)).Scan(&v)
+	assert.Nil(t, err)
+}
+
+func TestOptionalInterfacesSQLite(t *testing.T) {
+	sql.Register("sqlite3:optionals", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, sqlkit.NewMock()))
+	db, err := sql.Open("sqlite3:optionals", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	ctx := context.Background()
+	assert.Nil(t, db.PingContext(ctx))
+
+	conn, err := db.Conn(ctx)
+	assert.Nil(t, err)
+	defer conn.Close()
+	err = conn.Raw(func(dc any) error {
+		_, ok1 := dc.(driver.ExecerContext)
+		_, ok2 := dc.(driver.QueryerContext)
+		_, ok3 := dc.(driver.Pinger)
+		_, ok4 := dc.(driver.SessionResetter)
+		assert.Equal(t, []bool{true, true, true, false}, []bool{ok1, ok2, ok3, ok4})
+		return nil
+	})
+	assert.Nil(t, err)
+}
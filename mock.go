@@ -35,15 +35,28 @@ import (
 	"fmt"
 	"io"
 	"strings"
+	"sync"
 
+	"github.com/ccmonky/sqlkit/fingerprint"
 	"github.com/pkg/errors"
+	"go.uber.org/atomic"
 )
 
+// ErrMockTxMiss returned by Mock if a query misses the returns while a mocked transaction is open,
+// since it would be executed by the driver in autocommit and can not be rolled back
+var ErrMockTxMiss = errors.New("mock returns missed in mocked transaction")
+
 type Mock struct {
 	Name         string
 	Playback     bool
+	MockTx       bool
+	Fingerprint  bool // NOTE: if true, returns are keyed by fingerprint instead of the exact query
 	ExecReturns  *SyncMap[string, *Return[driver.Result]]
 	QueryReturns *SyncMap[string, *Return[driver.Rows]]
+
+	execRecords  *Cache[string, *Return[driver.Result]] // NOTE: returns recorded from the driver if bounded by `WithMockCache`
+	queryRecords *Cache[string, *Return[driver.Rows]]
+	txs          atomic.Int64 // NOTE: number of open mocked transactions
 }
 
 func NewMock(opts ...MockOption) *Mock {
@@ -54,9 +67,23 @@ func NewMock(opts ...MockOption) *Mock {
 	for _, opt := range opts {
 		opt(&mock)
 	}
+	if mock.Fingerprint {
+		mock.ExecReturns = rekey(mock.ExecReturns, fingerprint.Key)
+		mock.QueryReturns = rekey(mock.QueryReturns, fingerprint.Key)
+	}
 	return &mock
 }
 
+// rekey return a new map with the keys converted by fn
+func rekey[V any](m *SyncMap[string, V], fn func(string) string) *SyncMap[string, V] {
+	n := NewSyncMap[string, V]()
+	m.Range(func(k, v any) bool {
+		n.Store(fn(k.(string)), v.(V))
+		return true
+	})
+	return n
+}
+
 type MockOption func(*Mock)
 
 func WithMockName(name string) MockOption {
@@ -71,6 +98,31 @@ func WithMockPlayback(pb bool) MockOption {
 	}
 }
 
+// WithMockFingerprint if true, returns are keyed by fingerprint, so the same statement with different literals has the same return
+func WithMockFingerprint(fp bool) MockOption {
+	return func(mock *Mock) {
+		mock.Fingerprint = fp
+	}
+}
+
+// WithMockTx if true, transactions will not reach the driver, so that transactional flows can be replayed with mocked returns,
+// NOTE: while a mocked transaction is open, queries missing the returns are refused with `ErrMockTxMiss`,
+// since the mock can not tell the connection of a query, queries of other connections are refused too
+func WithMockTx(mockTx bool) MockOption {
+	return func(mock *Mock) {
+		mock.MockTx = mockTx
+	}
+}
+
+// WithMockCache bounds the returns recorded from the driver by the cache config, e.g. for a long running mock,
+// the returns added explicitly(e.g. `AddExec`) are kept in ExecReturns & QueryReturns and never evicted
+func WithMockCache(config CacheConfig) MockOption {
+	return func(mock *Mock) {
+		mock.execRecords = NewCache[string, *Return[driver.Result]]("mock_exec", config)
+		mock.queryRecords = NewCache[string, *Return[driver.Rows]]("mock_query", config)
+	}
+}
+
 func WithMockExecReturns(m map[string]*Return[driver.Result]) MockOption {
 	return func(mock *Mock) {
 		for k, v := range m {
@@ -110,35 +162,89 @@ func (m *Mock) Dump() error {
 }
 
 func (m *Mock) AddExec(query string, ret *Return[driver.Result]) {
-	m.ExecReturns.Store(query, ret)
+	m.ExecReturns.Store(m.key(query), ret)
 }
 
 func (m *Mock) AddQuery(query string, ret *Return[driver.Rows]) {
-	m.QueryReturns.Store(query, ret)
+	m.QueryReturns.Store(m.key(query), ret)
+}
+
+// key return the key of query in returns
+func (m *Mock) key(query string) string {
+	if m.Fingerprint {
+		return fingerprint.Fingerprint(query)
+	}
+	return query
 }
 
 func (m *Mock) ExecContext(next ExecContext) ExecContext {
 	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
-		if ret, ok := m.ExecReturns.Load(query); ok {
+		key := m.key(query)
+		if ret, ok := m.ExecReturns.Load(key); ok {
 			return ret.Value, ret.Err
 		}
+		if m.execRecords != nil {
+			if ret, ok := m.execRecords.Load(key); ok {
+				return ret.Value, ret.Err
+			}
+		}
+		if m.txs.Load() > 0 {
+			return nil, errors.WithMessagef(ErrMockTxMiss, "exec: %s", query)
+		}
 		results, err := next(ctx, query, args)
-		m.ExecReturns.Store(query, NewReturn(results, err))
+		if m.execRecords != nil {
+			m.execRecords.Store(key, NewReturn(results, err))
+		} else {
+			m.ExecReturns.Store(key, NewReturn(results, err))
+		}
 		return results, err
 	}
 }
 
 func (m *Mock) QueryContext(next QueryContext) QueryContext {
 	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
-		if ret, ok := m.QueryReturns.Load(query); ok {
+		key := m.key(query)
+		if ret, ok := m.QueryReturns.Load(key); ok {
 			return ret.Value, ret.Err
 		}
+		if m.queryRecords != nil {
+			if ret, ok := m.queryRecords.Load(key); ok {
+				return ret.Value, ret.Err
+			}
+		}
+		if m.txs.Load() > 0 {
+			return nil, errors.WithMessagef(ErrMockTxMiss, "query: %s", query)
+		}
 		rows, err := next(ctx, query, args)
-		m.QueryReturns.Store(query, NewReturn(rows, err))
+		if m.queryRecords != nil {
+			m.queryRecords.Store(key, NewReturn(rows, err))
+		} else {
+			m.QueryReturns.Store(key, NewReturn(rows, err))
+		}
 		return rows, err
 	}
 }
 
+func (m *Mock) BeginTx(next BeginTx) BeginTx {
+	return func(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+		if m.MockTx {
+			m.txs.Inc()
+			return &mockTx{mock: m}, nil
+		}
+		return next(ctx, opts)
+	}
+}
+
+// Commit commit the mocked(EmptyTx) or the real transaction created in BeginTx
+func (m *Mock) Commit(next Commit) Commit {
+	return next
+}
+
+// Rollback rollback the mocked(EmptyTx) or the real transaction created in BeginTx
+func (m *Mock) Rollback(next Rollback) Rollback {
+	return next
+}
+
 func NewReturn[T any](value T, err error) *Return[T] {
 	return &Return[T]{
 		Value: value,
@@ -295,6 +401,28 @@ func (rs *EmptyRows) Columns() []string              { return nil }
 func (rs *EmptyRows) Close() error                   { return nil }
 func (rs *EmptyRows) Next(dest []driver.Value) error { return io.EOF }
 
+// EmptyTx a transaction which does nothing, used by `Mock`
+type EmptyTx struct{}
+
+func (tx *EmptyTx) Commit() error   { return nil }
+func (tx *EmptyTx) Rollback() error { return nil }
+
+// mockTx the transaction returned by Mock if `MockTx`, which closes the mocked transaction once it ends
+type mockTx struct {
+	EmptyTx
+	mock *Mock
+	once sync.Once
+}
+
+func (tx *mockTx) Commit() error   { return tx.end() }
+func (tx *mockTx) Rollback() error { return tx.end() }
+
+func (tx *mockTx) end() error {
+	tx.once.Do(func() { tx.mock.txs.Dec() })
+	return nil
+}
+
 var (
-	_ Middleware = (*Mock)(nil)
+	_ Middleware   = (*Mock)(nil)
+	_ TxMiddleware = (*Mock)(nil)
 )
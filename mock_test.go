@@ -4,9 +4,11 @@ import (
 	"context"
 	"database/sql"
 	"database/sql/driver"
+	"fmt"
 	"testing"
 
 	"github.com/go-sql-driver/mysql"
+	"github.com/mattn/go-sqlite3"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ccmonky/sqlkit"
@@ -42,3 +44,36 @@ func TestMock(t *testing.T) {
 		t.Log(d)
 	}
 }
+
+func TestMockCache(t *testing.T) {
+	mock := sqlkit.NewMock(sqlkit.WithMockCache(sqlkit.CacheConfig{Size: 2}))
+	mock.AddExec("DELETE FROM t", &sqlkit.Return[driver.Result]{Value: driver.RowsAffected(9)})
+	sql.Register("sqlite3:mock:cache", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, mock))
+	db, err := sql.Open("sqlite3:mock:cache", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	assert.Nil(t, err)
+	for i := 1; i <= 3; i++ {
+		_, err = db.ExecContext(ctx, fmt.Sprintf("INSERT INTO t VALUES (%d)", i))
+		assert.Nil(t, err)
+	}
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (1)") // NOTE: evicted, executed again
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t VALUES (3)") // NOTE: recorded, not executed
+	assert.Nil(t, err)
+	var count int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t").Scan(&count))
+	assert.Equal(t, 4, count)
+
+	result, err := db.ExecContext(ctx, "DELETE FROM t")
+	assert.Nil(t, err)
+	affected, err := result.RowsAffected()
+	assert.Nil(t, err)
+	assert.Equal(t, int64(9), affected)
+	_, ok := mock.ExecReturns.Load("DELETE FROM t")
+	assert.True(t, ok)
+}
@@ -0,0 +1,166 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// NamedParams rewrites the named placeholders `:name` and `@name` to `?`, and the `sql.Named` args to positional ones
+// in the order of the placeholders, so named params can be used with drivers which do not support them, e.g. MySQL.
+// `:name` is always a named placeholder, while `@name` is only if a named arg with the same name is passed,
+// otherwise it is kept as a user variable. A name can be referred multiple times, and positional `?` can be mixed
+// with named placeholders. The rewritten statements are cached per query.
+// NOTE: `@name` is not supported by prepared statements, since the args are unknown when prepared.
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{Rewriter: &sqlkit.NamedParams{}}
+//     err := rm.Provision(ctx)
+//     sql.Register("named:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, rm))
+//     rows, err := db.QueryContext(ctx, "SELECT * FROM t WHERE dt >= :from AND dt < :to AND type = @type",
+//         sql.Named("from", from), sql.Named("to", to), sql.Named("type", "x"))
+//     // => SELECT * FROM t WHERE dt >= ? AND dt < ? AND type = ?, [from to x]
+//
+type NamedParams struct {
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	cache  *Cache[string, *namedQuery]
+	logger *zap.Logger
+}
+
+// namedQuery the rewritten sql, and the names of the placeholders in order, "" for positional ones
+type namedQuery struct {
+	sql   string
+	names []string
+}
+
+func (np *NamedParams) Name() string {
+	return "named_params"
+}
+
+func (np *NamedParams) Provision(ctx context.Context) error {
+	if np.logger == nil {
+		np.logger = zap.NewNop()
+	}
+	np.cache = NewCache[string, *namedQuery](np.Name(), np.Cache)
+	return nil
+}
+
+func (np *NamedParams) SetLogger(logger *zap.Logger) {
+	np.logger = logger
+}
+
+func (np *NamedParams) Rewrite(query string, args []any) (string, []any, error) {
+	return np.RewriteContext(context.Background(), query, args)
+}
+
+func (np *NamedParams) RewriteContext(ctx context.Context, query string, args []any) (string, []any, error) {
+	var (
+		named      map[string]any
+		positional []any
+	)
+	for _, arg := range args {
+		if na, ok := arg.(sql.NamedArg); ok {
+			if named == nil {
+				named = make(map[string]any)
+			}
+			named[na.Name] = na.Value
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	var at []string // NOTE: names of `@name`, nil when prepare or executed by statement
+	if _, ok := GetStmtContext(ctx); !ok && args != nil {
+		for name := range named {
+			at = append(at, name)
+		}
+		sort.Strings(at)
+	}
+	nq := np.parse(query, at)
+	if nq.sql == query && named == nil {
+		return query, args, nil
+	}
+	if args == nil { // NOTE: prepare
+		return nq.sql, nil, nil
+	}
+	var (
+		newArgs = make([]any, 0, len(nq.names))
+		used    = make(map[string]struct{}, len(named))
+	)
+	for _, name := range nq.names {
+		if name == "" {
+			if len(positional) == 0 {
+				return "", nil, errors.Errorf("sql: too few positional arguments: %s", query)
+			}
+			newArgs = append(newArgs, positional[0])
+			positional = positional[1:]
+			continue
+		}
+		value, ok := named[name]
+		if !ok {
+			return "", nil, errors.Errorf("sql: named argument %s not found: %s", name, query)
+		}
+		used[name] = struct{}{}
+		newArgs = append(newArgs, value)
+	}
+	if len(positional) > 0 {
+		return "", nil, errors.Errorf("sql: too many positional arguments: %s", query)
+	}
+	for name := range named {
+		if _, ok := used[name]; !ok {
+			return "", nil, errors.Errorf("sql: named argument %s not used: %s", name, query)
+		}
+	}
+	return nq.sql, newArgs, nil
+}
+
+// parse return the rewritten query, `@name` is a named placeholder only if name is in at
+func (np *NamedParams) parse(query string, at []string) *namedQuery {
+	key := query
+	if len(at) > 0 {
+		key += "\x00" + strings.Join(at, ",")
+	}
+	if nq, ok := np.cache.Load(key); ok {
+		return nq
+	}
+	var (
+		sb   strings.Builder
+		nq   = &namedQuery{}
+		last int
+	)
+	scanSql(query, func(kind byte, start, end int) {
+		switch kind {
+		case '?':
+			nq.names = append(nq.names, "")
+		case ':', '@':
+			name := query[start+1 : end]
+			if kind == '@' && !sortedContains(at, name) {
+				return
+			}
+			sb.WriteString(query[last:start])
+			sb.WriteString("?")
+			last = end
+			nq.names = append(nq.names, name)
+		}
+	})
+	sb.WriteString(query[last:])
+	nq.sql = sb.String()
+	np.cache.Store(key, nq)
+	return nq
+}
+
+func sortedContains(ss []string, s string) bool {
+	i := sort.SearchStrings(ss, s)
+	return i < len(ss) && ss[i] == s
+}
+
+var (
+	_ RewriterInterface = (*NamedParams)(nil)
+	_ ContextRewriter   = (*NamedParams)(nil)
+)
@@ -0,0 +1,100 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestNamedParams(t *testing.T) {
+	np := &sqlkit.NamedParams{}
+	assert.Nil(t, np.Provision(context.Background()))
+	var cases = []struct {
+		sql     string
+		args    []any
+		nsql    string
+		newArgs []any
+	}{
+		{
+			sql:     "SELECT * FROM t WHERE id = ?",
+			args:    []any{1},
+			nsql:    "SELECT * FROM t WHERE id = ?",
+			newArgs: []any{1},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE dt >= :from AND dt < :to AND (a = :from OR b = ?) AND type = @type",
+			args:    []any{sql.Named("to", 2), sql.Named("type", "x"), 3, sql.Named("from", 1)},
+			nsql:    "SELECT * FROM t WHERE dt >= ? AND dt < ? AND (a = ? OR b = ?) AND type = ?",
+			newArgs: []any{1, 2, 1, 3, "x"},
+		},
+		{
+			sql:     "SELECT @@version, @v := 1, ':name', `@name`, a::text FROM t /* :c */ WHERE a = :a -- :b\n",
+			args:    []any{sql.Named("a", 1)},
+			nsql:    "SELECT @@version, @v := 1, ':name', `@name`, a::text FROM t /* :c */ WHERE a = ? -- :b\n",
+			newArgs: []any{1},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE a = @a AND b = @b",
+			args:    []any{sql.Named("a", 1)},
+			nsql:    "SELECT * FROM t WHERE a = ? AND b = @b",
+			newArgs: []any{1},
+		},
+	}
+	for _, c := range cases {
+		nsql, newArgs, err := np.Rewrite(c.sql, c.args)
+		assert.Nilf(t, err, c.sql)
+		assert.Equalf(t, c.nsql, nsql, c.sql)
+		assert.Equalf(t, c.newArgs, newArgs, c.sql)
+	}
+
+	var errCases = []struct {
+		sql  string
+		args []any
+	}{
+		{"SELECT * FROM t WHERE a = :a", []any{}},
+		{"SELECT * FROM t WHERE a = :a", []any{sql.Named("a", 1), sql.Named("b", 2)}},
+		{"SELECT * FROM t WHERE a = :a AND b = ?", []any{sql.Named("a", 1)}},
+		{"SELECT * FROM t WHERE a = :a", []any{sql.Named("a", 1), 2}},
+	}
+	for _, c := range errCases {
+		_, _, err := np.Rewrite(c.sql, c.args)
+		assert.NotNilf(t, err, c.sql)
+	}
+	nsql, args, err := np.Rewrite("SELECT * FROM t WHERE a = :a AND b = @b", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a = ? AND b = @b", nsql)
+	assert.Nil(t, args)
+}
+
+func TestNamedParamsMiddleware(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.NamedParams{},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:named", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:named", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER, name VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id, name) VALUES (:id, :name), (:id + 1, @name)", sql.Named("name", "x"), sql.Named("id", 1))
+	assert.Nil(t, err)
+
+	var count int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t WHERE id >= :min AND name = :name", sql.Named("min", 1), sql.Named("name", "x")).Scan(&count))
+	assert.Equal(t, 2, count)
+
+	stmt, err := db.PrepareContext(ctx, "SELECT COUNT(*) FROM t WHERE id > :min")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	assert.Nil(t, stmt.QueryRowContext(ctx, sql.Named("min", 1)).Scan(&count))
+	assert.Equal(t, 1, count)
+}
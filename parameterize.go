@@ -0,0 +1,302 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ccmonky/sqlkit/fingerprint"
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pingcap/tidb/types"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// Parameterize extracts inline literals into `?` placeholders and appends the values to the args, so the statements
+// are stable for audit and plan cache. The literals compared in WHERE(including IN-lists, BETWEEN and LIKE) and LIMIT
+// of SELECT, UPDATE and DELETE are extracted, literals in function arguments, select fields, INSERT values and NULL are kept.
+// NOTE: statements which can not be parsed or contain `LOCK IN SHARE MODE` are passed through unchanged.
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{
+//         Rewriter: &sqlkit.Parameterize{Skip: []string{"SELECT * FROM t WHERE type = 'x'"}},
+//     }
+//     // SELECT * FROM t WHERE id IN (1, 2) AND name = 'x' LIMIT 10
+//     // => SELECT * FROM t WHERE id IN (?,?) AND name=? LIMIT ?, [1 2 x 10]
+//
+type Parameterize struct {
+	// Skip statements which are not parameterized, can be raw sqls, fingerprints or digests, see `fingerprint.Key`
+	Skip []string `json:"skip,omitempty"`
+
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser *parser.Parser
+	mu     sync.Mutex // NOTE: parser is not goroutine safe
+	cache  *Cache[string, *parameterized]
+	logger *zap.Logger
+	skips  map[string]struct{}
+}
+
+// parameterized the parameterized sql, and the args in order
+type parameterized struct {
+	sql   string
+	args  []paramArg
+	nargs int // NOTE: number of original args
+}
+
+// paramArg the index of original arg if value is nil, otherwise the extracted literal
+type paramArg struct {
+	index int
+	value any
+}
+
+// paramSentinel the string literal format marks where the extracted literal is
+const paramSentinel = "__sqlkit_param_%d__"
+
+func (p *Parameterize) Name() string {
+	return "parameterize"
+}
+
+func (p *Parameterize) Provision(ctx context.Context) error {
+	if p.logger == nil {
+		p.logger = zap.NewNop()
+	}
+	p.parser = parser.New()
+	p.cache = NewCache[string, *parameterized](p.Name(), p.Cache)
+	p.skips = make(map[string]struct{}, len(p.Skip))
+	for _, s := range p.Skip {
+		p.skips[fingerprint.Key(s)] = struct{}{}
+	}
+	return nil
+}
+
+func (p *Parameterize) SetLogger(logger *zap.Logger) {
+	p.logger = logger
+}
+
+func (p *Parameterize) skip(query string) bool {
+	if len(p.skips) == 0 {
+		return false
+	}
+	fp := fingerprint.Fingerprint(query)
+	if _, ok := p.skips[fp]; ok {
+		return true
+	}
+	_, ok := p.skips[fingerprint.DigestFingerprint(fp)]
+	return ok
+}
+
+func (p *Parameterize) Rewrite(query string, args []any) (string, []any, error) {
+	for _, arg := range args {
+		if _, ok := arg.(sql.NamedArg); ok {
+			return query, args, nil // NOTE: named args are not supported since the placeholders are positional
+		}
+	}
+	pz, err := p.parameterize(query)
+	if err != nil {
+		return "", nil, err
+	}
+	if pz.sql == query || args == nil { // NOTE: args is nil when prepare
+		return pz.sql, args, nil
+	}
+	if len(args) != pz.nargs {
+		return "", nil, errors.Errorf("sql: expected %d arguments, got %d: %s", pz.nargs, len(args), query)
+	}
+	newArgs := make([]any, len(pz.args))
+	for i, pa := range pz.args {
+		if pa.value != nil {
+			newArgs[i] = pa.value
+		} else {
+			newArgs[i] = args[pa.index]
+		}
+	}
+	return pz.sql, newArgs, nil
+}
+
+func (p *Parameterize) parameterize(query string) (*parameterized, error) {
+	if pz, ok := p.cache.Load(query); ok {
+		return pz, nil
+	}
+	pz := &parameterized{sql: query}
+	if p.skip(query) {
+		p.cache.Store(query, pz)
+		return pz, nil
+	}
+	p.mu.Lock()
+	stmtNodes, warns, err := p.parser.Parse(query, "", "")
+	p.mu.Unlock()
+	if err != nil { // NOTE: sqls not supported by the parser(e.g. other dialects) are passed through
+		p.logger.Warn("parameterize skipped since parser sql failed", zap.String("sql", query), zap.Error(err))
+		p.cache.Store(query, pz)
+		return pz, nil
+	}
+	if len(warns) > 0 {
+		p.logger.Debug("parameterize warnings", zap.Any("warns", warns), zap.String("sql", query))
+	}
+	if len(stmtNodes) != 1 {
+		p.cache.Store(query, pz) // NOTE: multi-statements are not supported
+		return pz, nil
+	}
+	visitor := &paramVisitor{}
+	c := &nodeCollector{}
+	stmtNodes[0].Accept(c)
+	for _, n := range c.nodes {
+		switch n := n.(type) {
+		case *ast.SelectStmt:
+			if n.LockInfo != nil && n.LockInfo.LockType == ast.SelectLockForShare {
+				p.cache.Store(query, pz) // NOTE: `LOCK IN SHARE MODE` is restored as `FOR SHARE` which MySQL 5.7 does not support
+				return pz, nil
+			}
+			visitor.where(n.Where)
+			visitor.limit(n.Limit)
+		case *ast.UpdateStmt:
+			visitor.where(n.Where)
+			visitor.limit(n.Limit)
+		case *ast.DeleteStmt:
+			visitor.where(n.Where)
+			visitor.limit(n.Limit)
+		}
+	}
+	if len(visitor.values) == 0 {
+		p.cache.Store(query, pz)
+		return pz, nil
+	}
+	restored, err := restore(stmtNodes[0])
+	if err != nil {
+		p.logger.Warn("parameterize skipped since restore failed", zap.String("sql", query), zap.Error(err))
+		p.cache.Store(query, pz)
+		return pz, nil
+	}
+	pz = newParameterized(restored, visitor.values)
+	p.cache.Store(query, pz)
+	return pz, nil
+}
+
+// newParameterized replaces the sentinels of the restored sql with placeholders, and records the args in order
+func newParameterized(restored string, values []any) *parameterized {
+	var (
+		sb   strings.Builder
+		pz   = &parameterized{}
+		last int
+	)
+	scanSql(restored, func(kind byte, start, end int) {
+		switch kind {
+		case '?':
+			pz.args = append(pz.args, paramArg{index: pz.nargs})
+			pz.nargs++
+		case '\'':
+			i, ok := paramSentinelIndex(restored[start:end])
+			if !ok || i >= len(values) {
+				return
+			}
+			sb.WriteString(restored[last:start])
+			sb.WriteString("?")
+			last = end
+			pz.args = append(pz.args, paramArg{value: values[i]})
+		}
+	})
+	sb.WriteString(restored[last:])
+	pz.sql = sb.String()
+	return pz
+}
+
+// paramSentinelIndex return the index of the sentinel literal, e.g. `'__sqlkit_param_1__'` => 1
+func paramSentinelIndex(literal string) (int, bool) {
+	const prefix, suffix = "'__sqlkit_param_", "__'"
+	if !strings.HasPrefix(literal, prefix) || !strings.HasSuffix(literal, suffix) {
+		return 0, false
+	}
+	i, err := strconv.Atoi(literal[len(prefix) : len(literal)-len(suffix)])
+	return i, err == nil
+}
+
+// paramVisitor replaces the compared literals with sentinels and collects the values
+type paramVisitor struct {
+	values []any
+}
+
+func (v *paramVisitor) where(where ast.ExprNode) {
+	if where != nil {
+		where.Accept(v)
+	}
+}
+
+func (v *paramVisitor) limit(limit *ast.Limit) {
+	if limit == nil {
+		return
+	}
+	v.replace(&limit.Count)
+	v.replace(&limit.Offset)
+}
+
+func (v *paramVisitor) Enter(in ast.Node) (ast.Node, bool) {
+	switch n := in.(type) {
+	case *ast.SubqueryExpr, *ast.FuncCallExpr, *ast.AggregateFuncExpr, *ast.FuncCastExpr:
+		return in, true // NOTE: subqueries are visited as SelectStmt
+	case *ast.BinaryOperationExpr:
+		switch n.Op {
+		case opcode.EQ, opcode.NE, opcode.LT, opcode.LE, opcode.GT, opcode.GE, opcode.NullEQ:
+			v.replace(&n.L)
+			v.replace(&n.R)
+		}
+	case *ast.PatternInExpr:
+		for i := range n.List {
+			v.replace(&n.List[i])
+		}
+	case *ast.BetweenExpr:
+		v.replace(&n.Left)
+		v.replace(&n.Right)
+	case *ast.PatternLikeExpr:
+		v.replace(&n.Pattern)
+	}
+	return in, false
+}
+
+func (v *paramVisitor) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// replace replaces the literal with a sentinel if it can be parameterized
+func (v *paramVisitor) replace(expr *ast.ExprNode) {
+	if *expr == nil {
+		return
+	}
+	if _, ok := (*expr).(ast.ParamMarkerExpr); ok {
+		return
+	}
+	ve, ok := (*expr).(ast.ValueExpr)
+	if !ok {
+		return
+	}
+	var value any
+	switch val := ve.GetValue().(type) {
+	case int64, float64, string:
+		value = val
+	case uint64: // NOTE: the extracted values are passed to driver directly, so they should be valid driver.Value
+		if val > math.MaxInt64 {
+			value = strconv.FormatUint(val, 10)
+		} else {
+			value = int64(val)
+		}
+	case []byte:
+		value = val
+	case *types.MyDecimal:
+		value = val.String()
+	case types.BinaryLiteral:
+		value = []byte(val)
+	default:
+		return // NOTE: NULL and others are kept
+	}
+	*expr = ast.NewValueExpr(fmt.Sprintf(paramSentinel, len(v.values)), "", "")
+	v.values = append(v.values, value)
+}
+
+var _ RewriterInterface = (*Parameterize)(nil)
@@ -0,0 +1,130 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestParameterize(t *testing.T) {
+	p := &sqlkit.Parameterize{Skip: []string{"SELECT * FROM t WHERE type = 'x'"}}
+	assert.Nil(t, p.Provision(context.Background()))
+	var cases = []struct {
+		sql     string
+		args    []any
+		psql    string
+		newArgs []any
+	}{
+		{
+			sql:  "SELECT * FROM t WHERE id IN (1, 2) AND name = 'x' LIMIT 10",
+			args: []any{},
+
+			psql:    "SELECT * FROM t WHERE id IN (?,?) AND name=? LIMIT ?",
+			newArgs: []any{int64(1), int64(2), "x", int64(10)},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE a = ? AND b > 1.5 AND c BETWEEN 1 AND ? AND d LIKE 'x%' LIMIT 5, ?",
+			args:    []any{"a", 9, 20},
+			psql:    "SELECT * FROM t WHERE a=? AND b>? AND c BETWEEN ? AND ? AND d LIKE ? LIMIT ?,?",
+			newArgs: []any{"a", "1.5", int64(1), 9, "x%", int64(5), 20},
+		},
+		{
+			sql:  "SELECT id, 'const' FROM t WHERE d IS NULL AND e = NULL AND f = ABS(-1) AND id IN (SELECT id FROM u WHERE v = 'it''s')",
+			args: []any{},
+
+			psql:    "SELECT id,'const' FROM t WHERE d IS NULL AND e=NULL AND f=ABS(-1) AND id IN (SELECT id FROM u WHERE v=?)",
+			newArgs: []any{"it's"},
+		},
+		{
+			sql:  "UPDATE t SET name = 'y' WHERE id = 3 LIMIT 1",
+			args: []any{},
+
+			psql:    "UPDATE t SET name='y' WHERE id=? LIMIT ?",
+			newArgs: []any{int64(3), int64(1)},
+		},
+		{
+			sql:     "DELETE FROM t WHERE id = ? OR id = 4",
+			args:    []any{3},
+			psql:    "DELETE FROM t WHERE id=? OR id=?",
+			newArgs: []any{3, int64(4)},
+		},
+		{
+			sql:     "INSERT INTO t (id, name) VALUES (1, 'x')",
+			args:    []any{},
+			psql:    "INSERT INTO t (id, name) VALUES (1, 'x')",
+			newArgs: []any{},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE type = 'y'",
+			args:    []any{},
+			psql:    "SELECT * FROM t WHERE type = 'y'",
+			newArgs: []any{},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE name = :name",
+			args:    []any{sql.Named("name", "x")},
+			psql:    "SELECT * FROM t WHERE name = :name",
+			newArgs: []any{sql.Named("name", "x")},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE id = 1 FOR UPDATE",
+			args:    []any{},
+			psql:    "SELECT * FROM t WHERE id=? FOR UPDATE",
+			newArgs: []any{int64(1)},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE id = 1 LOCK IN SHARE MODE",
+			args:    []any{},
+			psql:    "SELECT * FROM t WHERE id = 1 LOCK IN SHARE MODE",
+			newArgs: []any{},
+		},
+		{
+			sql:     "SELECT * FROM t WHERE id = 1 AND name GLOB 'x*'",
+			args:    []any{},
+			psql:    "SELECT * FROM t WHERE id = 1 AND name GLOB 'x*'",
+			newArgs: []any{},
+		},
+	}
+	for _, c := range cases {
+		psql, newArgs, err := p.Rewrite(c.sql, c.args)
+		assert.Nilf(t, err, c.sql)
+		assert.Equalf(t, c.psql, psql, c.sql)
+		assert.Equalf(t, c.newArgs, newArgs, c.sql)
+	}
+	_, _, err := p.Rewrite("SELECT * FROM t WHERE a = ? AND b = 1", []any{})
+	assert.NotNil(t, err)
+	psql, args, err := p.Rewrite("SELECT * FROM t WHERE a = ? AND b = 1", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE a=? AND b=?", psql)
+	assert.Nil(t, args)
+}
+
+func TestParameterizeMiddleware(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.Parameterize{},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:parameterize", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:parameterize", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER, name VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id, name) VALUES (1, 'x'), (2, 'y'), (3, 'z')")
+	assert.Nil(t, err)
+
+	var count int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT COUNT(*) FROM t WHERE id IN (1, 2) AND name <> 'x'").Scan(&count))
+	assert.Equal(t, 1, count)
+	var name string
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT name FROM t WHERE id > ? ORDER BY id LIMIT 1", 1).Scan(&name))
+	assert.Equal(t, "y", name)
+}
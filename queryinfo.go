@@ -0,0 +1,248 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// QueryInfo metadata of a query provided by application, which is read by the builtin middlewares,
+// e.g. LogHooks and Audit log it, QueryMetrics use `Name` as label, FlowControl limits by `Name` and `Priority`
+//
+// Usage:
+//
+//     ctx = sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Name: "user.get", Tenant: "t1"})
+//     row := db.QueryRowContext(ctx, "SELECT * FROM user WHERE id=?", id)
+//
+type QueryInfo struct {
+	// Name used to identify the query, e.g. `user.get`, NOTE: it is used as metrics label, so keep it low cardinality
+	Name string `json:"name,omitempty"`
+
+	// Tags extra labels of the query
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// Caller file:line of the application call site, see `CallerCapture`
+	Caller string `json:"caller,omitempty"`
+
+	// Tenant the tenant which the query belongs to
+	Tenant string `json:"tenant,omitempty"`
+
+	// Role the role on whose behalf the query is executed, e.g. `support`, see `DataMasking`
+	Role string `json:"role,omitempty"`
+
+	// Priority of the query, the larger the more important, see `FlowControl`
+	Priority int `json:"priority,omitempty"`
+}
+
+type queryInfoCtxKey struct{}
+
+// WithQueryInfo return a context carrying the query info
+func WithQueryInfo(ctx context.Context, info QueryInfo) context.Context {
+	return context.WithValue(ctx, queryInfoCtxKey{}, info)
+}
+
+// GetQueryInfo return the query info carried by ctx
+func GetQueryInfo(ctx context.Context) (QueryInfo, bool) {
+	info, ok := ctx.Value(queryInfoCtxKey{}).(QueryInfo)
+	return info, ok
+}
+
+// getQueryInfo return the pointer of query info carried by ctx, or nil if not carried
+func getQueryInfo(ctx context.Context) *QueryInfo {
+	if info, ok := GetQueryInfo(ctx); ok {
+		return &info
+	}
+	return nil
+}
+
+// ZapFields return the non-empty fields for logging
+func (info QueryInfo) ZapFields() []zap.Field {
+	var fields []zap.Field
+	if info.Name != "" {
+		fields = append(fields, zap.String("query_name", info.Name))
+	}
+	if len(info.Tags) > 0 {
+		fields = append(fields, zap.Any("query_tags", info.Tags))
+	}
+	if info.Caller != "" {
+		fields = append(fields, zap.String("caller", info.Caller))
+	}
+	if info.Tenant != "" {
+		fields = append(fields, zap.String("tenant", info.Tenant))
+	}
+	if info.Role != "" {
+		fields = append(fields, zap.String("role", info.Role))
+	}
+	if info.Priority != 0 {
+		fields = append(fields, zap.Int("priority", info.Priority))
+	}
+	return fields
+}
+
+// queryInfoFields return the zap fields of query info carried by ctx
+func queryInfoFields(ctx context.Context) []zap.Field {
+	info, ok := GetQueryInfo(ctx)
+	if !ok {
+		return nil
+	}
+	return info.ZapFields()
+}
+
+// CallerCapture is a middleware which fills `QueryInfo.Caller` with the application call site if not specified,
+// it should be the first of middlewares, since the stack is walked on every query.
+//
+// Usage:
+//
+//     sql.Register("sqlkit:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, &sqlkit.CallerCapture{Skip: []string{"gorm.io/"}}, audit))
+//
+type CallerCapture struct {
+	// Skip function name prefixes of frames to be skipped besides database/sql and sqlkit, e.g. orm packages
+	Skip []string `json:"skip,omitempty"`
+}
+
+var callerSkipPrefixes = []string{
+	"runtime.",
+	"database/sql.",
+	"github.com/ccmonky/sqlkit.",
+	"github.com/ccmonky/sqlkit/",
+	"github.com/qustavo/sqlhooks/",
+}
+
+func (cc *CallerCapture) ExecContext(next ExecContext) ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		return next(cc.withCaller(ctx), query, args)
+	}
+}
+
+func (cc *CallerCapture) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		return next(cc.withCaller(ctx), query, args)
+	}
+}
+
+func (cc *CallerCapture) PrepareContext(next PrepareContext) PrepareContext {
+	return func(ctx context.Context, query string) (driver.Stmt, error) {
+		return next(cc.withCaller(ctx), query)
+	}
+}
+
+func (cc *CallerCapture) CloseStmt(next CloseStmt) CloseStmt {
+	return next
+}
+
+func (cc *CallerCapture) withCaller(ctx context.Context) context.Context {
+	info, _ := GetQueryInfo(ctx)
+	if info.Caller != "" {
+		return ctx
+	}
+	info.Caller = cc.caller()
+	return WithQueryInfo(ctx, info)
+}
+
+func (cc *CallerCapture) caller() string {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !cc.skip(frame.Function) {
+			return frame.File + ":" + strconv.Itoa(frame.Line)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
+
+func (cc *CallerCapture) skip(function string) bool {
+	for _, prefix := range callerSkipPrefixes {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	for _, prefix := range cc.Skip {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// QueryMetrics is a middleware which exports query duration histogram labeled by `QueryInfo.Name`
+type QueryMetrics struct {
+	// Name used as the label of metrics
+	Name string `json:"name"`
+}
+
+func (qm *QueryMetrics) Provision(ctx context.Context) error {
+	queryMetrics.init.Do(func() {
+		initQueryMetrics()
+	})
+	return nil
+}
+
+func (qm *QueryMetrics) ExecContext(next ExecContext) ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		start := time.Now()
+		result, err := next(ctx, query, args)
+		qm.observe(ctx, "exec", start, err)
+		return result, err
+	}
+}
+
+func (qm *QueryMetrics) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		start := time.Now()
+		rows, err := next(ctx, query, args)
+		qm.observe(ctx, "query", start, err)
+		return rows, err
+	}
+}
+
+func (qm *QueryMetrics) observe(ctx context.Context, typ string, start time.Time, err error) {
+	if err == driver.ErrSkip {
+		return
+	}
+	info, _ := GetQueryInfo(ctx)
+	queryName := info.Name
+	if queryName == "" {
+		queryName = "unknown"
+	}
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	queryMetrics.duration.WithLabelValues(qm.Name, queryName, typ, status).Observe(time.Since(start).Seconds())
+}
+
+var queryMetrics = struct {
+	init     sync.Once
+	duration *prometheus.HistogramVec
+}{
+	init: sync.Once{},
+}
+
+func initQueryMetrics() {
+	const ns, sub = "sqlkit", "query"
+	queryMetrics.duration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: ns,
+		Subsystem: sub,
+		Name:      "duration_seconds",
+		Help:      "Histogram of query durations labeled by query name.",
+		Buckets:   []float64{.001, .005, .01, .05, .1, .5, 1, 5, 10},
+	}, []string{"name", "query_name", "type", "status"})
+}
+
+var (
+	_ Middleware        = (*CallerCapture)(nil)
+	_ PrepareMiddleware = (*CallerCapture)(nil)
+	_ Middleware        = (*QueryMetrics)(nil)
+)
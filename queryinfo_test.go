@@ -0,0 +1,66 @@
+package sqlkit_test
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+type queryInfoRecorder struct {
+	recorder
+	infos []sqlkit.QueryInfo
+}
+
+func (r *queryInfoRecorder) ExecContext(next sqlkit.ExecContext) sqlkit.ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		info, _ := sqlkit.GetQueryInfo(ctx)
+		r.infos = append(r.infos, info)
+		return next(ctx, query, args)
+	}
+}
+
+func TestQueryInfo(t *testing.T) {
+	b := &bytes.Buffer{}
+	logger := zap.New(zapcore.NewCore(zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()), zapcore.AddSync(b), zapcore.DebugLevel))
+	qr := &queryInfoRecorder{}
+	qm := &sqlkit.QueryMetrics{Name: "test"}
+	assert.Nil(t, qm.Provision(context.Background()))
+	sql.Register("sqlite3:queryinfo", sqlkit.WrapChain(&sqlite3.SQLiteDriver{},
+		&sqlkit.CallerCapture{},
+		qr,
+		qm,
+		&sqlkit.LogHooks{Logger: logger, Level: zapcore.InfoLevel, FieldNameMap: map[string]string{"query_name": "qn"}},
+	))
+	db, err := sql.Open("sqlite3:queryinfo", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+
+	ctx := sqlkit.WithQueryInfo(context.Background(), sqlkit.QueryInfo{Name: "t1.create", Tenant: "foo", Tags: map[string]string{"k": "v"}})
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(context.Background(), "INSERT INTO t1 (id, text) VALUES(1, 'foo')")
+	assert.Nil(t, err)
+
+	assert.Len(t, qr.infos, 2)
+	assert.Equal(t, "t1.create", qr.infos[0].Name)
+	assert.Equal(t, "foo", qr.infos[0].Tenant)
+	assert.Equal(t, map[string]string{"k": "v"}, qr.infos[0].Tags)
+	for _, info := range qr.infos {
+		assert.Contains(t, info.Caller, "queryinfo_test.go:")
+	}
+	logs := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Len(t, logs, 2)
+	assert.Contains(t, logs[0], `"qn":"t1.create"`)
+	assert.Contains(t, logs[0], `"tenant":"foo"`)
+	assert.Contains(t, logs[1], `"caller":`)
+}
@@ -0,0 +1,200 @@
+package sqlkit
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Registry a registry of pluggable components, the components are registered by type name,
+// so they can be decoded from JSON objects with a `type` field, e.g. `{"type":"shadow_table","suffix":"_s"}`,
+// the other fields are decoded into the component created by the registered factory.
+//
+// Usage:
+//
+//     sqlkit.RewriterRegistry.Register("my_rewriter", func() sqlkit.RewriterBase { return &MyRewriter{} })
+//     rewriter, err := sqlkit.RewriterRegistry.Unmarshal([]byte(`{"type":"my_rewriter","option":"x"}`))
+//
+type Registry[T any] struct {
+	mu        sync.RWMutex
+	factories map[string]func() T
+	names     map[reflect.Type]string
+}
+
+// NewRegistry creates a new registry
+func NewRegistry[T any]() *Registry[T] {
+	return &Registry[T]{
+		factories: make(map[string]func() T),
+		names:     make(map[reflect.Type]string),
+	}
+}
+
+// Register registers the factory with type name, NOTE: the factory should return a pointer to a new instance
+func (r *Registry[T]) Register(name string, factory func() T) error {
+	if name == "" || factory == nil {
+		return errors.New("register with empty name or nil factory")
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.factories[name]; ok {
+		return errors.Errorf("type %s already registered", name)
+	}
+	r.factories[name] = factory
+	r.names[reflect.TypeOf(factory())] = name
+	return nil
+}
+
+// MustRegister is like Register but panics if failed
+func (r *Registry[T]) MustRegister(name string, factory func() T) {
+	if err := r.Register(name, factory); err != nil {
+		panic(err)
+	}
+}
+
+// Types return the registered type names in order
+func (r *Registry[T]) Types() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New creates a new instance of the type name
+func (r *Registry[T]) New(name string) (T, error) {
+	r.mu.RLock()
+	factory, ok := r.factories[name]
+	r.mu.RUnlock()
+	if !ok {
+		var zero T
+		return zero, errors.Errorf("type %s not registered", name)
+	}
+	return factory(), nil
+}
+
+// TypeOf return the type name of the component
+func (r *Registry[T]) TypeOf(v T) (string, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	name, ok := r.names[reflect.TypeOf(v)]
+	return name, ok
+}
+
+// Unmarshal decodes the component from the JSON object with a `type` field
+func (r *Registry[T]) Unmarshal(data []byte) (T, error) {
+	var zero T
+	var typed struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return zero, errors.WithMessagef(err, "unmarshal type failed: %s", data)
+	}
+	if typed.Type == "" {
+		return zero, errors.Errorf("type is required: %s", data)
+	}
+	v, err := r.New(typed.Type)
+	if err != nil {
+		return zero, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return zero, errors.WithMessagef(err, "unmarshal %s failed", typed.Type)
+	}
+	return v, nil
+}
+
+// UnmarshalList decodes the components from the JSON array of objects with a `type` field
+func (r *Registry[T]) UnmarshalList(data []byte) ([]T, error) {
+	var raws []json.RawMessage
+	if err := json.Unmarshal(data, &raws); err != nil {
+		return nil, errors.WithMessagef(err, "unmarshal list failed: %s", data)
+	}
+	if raws == nil {
+		return nil, nil
+	}
+	vs := make([]T, 0, len(raws))
+	for i, raw := range raws {
+		v, err := r.Unmarshal(raw)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "unmarshal element %d failed", i)
+		}
+		vs = append(vs, v)
+	}
+	return vs, nil
+}
+
+// Marshal encodes the component to the JSON object with a `type` field, which can be decoded by Unmarshal
+func (r *Registry[T]) Marshal(v T) ([]byte, error) {
+	name, ok := r.TypeOf(v)
+	if !ok {
+		return nil, errors.Errorf("type %T not registered", v)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, errors.WithMessagef(err, "type %T is not encoded as JSON object", v)
+	}
+	typ, _ := json.Marshal(name)
+	fields["type"] = typ
+	return json.Marshal(fields)
+}
+
+// MarshalList encodes the components to the JSON array of objects with a `type` field
+func (r *Registry[T]) MarshalList(vs []T) ([]byte, error) {
+	if vs == nil {
+		return []byte("null"), nil
+	}
+	raws := make([]json.RawMessage, 0, len(vs))
+	for _, v := range vs {
+		raw, err := r.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		raws = append(raws, raw)
+	}
+	return json.Marshal(raws)
+}
+
+// RewriterRegistry the registry of rewriters, a rewriter can be a RewriterInterface, SqlRewriter or ArgsRewriter
+var RewriterRegistry = NewRegistry[RewriterBase]()
+
+// AuditRuleRegistry the registry of audit rules
+var AuditRuleRegistry = NewRegistry[AuditRule]()
+
+func init() {
+	RewriterRegistry.MustRegister("rewrite", func() RewriterBase { return &Rewrite{} })
+	RewriterRegistry.MustRegister("rewriter", func() RewriterBase { return &Rewriter{} })
+	RewriterRegistry.MustRegister("shadow_table", func() RewriterBase { return &ShadowTable{} })
+	RewriterRegistry.MustRegister("tenant_isolation", func() RewriterBase { return &TenantIsolation{} })
+	RewriterRegistry.MustRegister("soft_delete", func() RewriterBase { return &SoftDelete{} })
+	RewriterRegistry.MustRegister("optimizer_hints", func() RewriterBase { return &OptimizerHints{} })
+	RewriterRegistry.MustRegister("parameterize", func() RewriterBase { return &Parameterize{} })
+	RewriterRegistry.MustRegister("expand_slices", func() RewriterBase { return &ExpandSlices{} })
+	RewriterRegistry.MustRegister("named_params", func() RewriterBase { return &NamedParams{} })
+	RewriterRegistry.MustRegister("sqlite_dialect", func() RewriterBase { return &SQLiteDialect{} })
+	RewriterRegistry.MustRegister("column_encryption", func() RewriterBase { return &ColumnEncryption{} })
+
+	AuditRuleRegistry.MustRegister("pattern", func() AuditRule { return &PatternRule{} })
+	AuditRuleRegistry.MustRegister("explain", func() AuditRule { return &ExplainRule{} })
+}
+
+// UnmarshalRewriter decodes the RewriterInterface from the JSON object with a `type` field, see `RewriterRegistry`
+func UnmarshalRewriter(data []byte) (RewriterInterface, error) {
+	v, err := RewriterRegistry.Unmarshal(data)
+	if err != nil {
+		return nil, err
+	}
+	rewriter, ok := v.(RewriterInterface)
+	if !ok {
+		return nil, errors.Errorf("%T is not a RewriterInterface", v)
+	}
+	return rewriter, nil
+}
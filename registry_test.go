@@ -0,0 +1,139 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+	"github.com/ccmonky/sqlkit/mysql"
+)
+
+func TestRewriterRegistry(t *testing.T) {
+	assert.NotNil(t, sqlkit.RewriterRegistry.Register("shadow_table", func() sqlkit.RewriterBase { return &sqlkit.ShadowTable{} }))
+	assert.Contains(t, sqlkit.RewriterRegistry.Types(), "soft_delete")
+
+	data := []byte(`{
+		"type": "rewrite",
+		"global_rewriter": {"sql_rewriters": [{"type": "shadow_table", "suffix": "_s", "exclude_tables": ["dict"]}]},
+		"custom_rewriters": {"SELECT 1": {"sql_rewriters": [{"type": "soft_delete", "tables": ["t"]}]}}
+	}`)
+	rewriter, err := sqlkit.UnmarshalRewriter(data)
+	assert.Nil(t, err)
+	rewrite, ok := rewriter.(*sqlkit.Rewrite)
+	assert.True(t, ok)
+	st, ok := rewrite.GlobalRewriter.SqlRewriters[0].(*sqlkit.ShadowTable)
+	assert.True(t, ok)
+	assert.Equal(t, "_s", st.Suffix)
+	assert.Equal(t, []string{"dict"}, st.ExcludeTables)
+	assert.Nil(t, rewriter.Provision(context.Background()))
+	s, _, err := rewriter.Rewrite("SELECT * FROM t", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t_s", s)
+
+	encoded, err := sqlkit.RewriterRegistry.Marshal(rewriter)
+	assert.Nil(t, err)
+	decoded, err := sqlkit.UnmarshalRewriter(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, "_s", decoded.(*sqlkit.Rewrite).GlobalRewriter.SqlRewriters[0].(*sqlkit.ShadowTable).Suffix)
+
+	var errCases = []string{
+		`{"suffix": "_s"}`,
+		`{"type": "unknown"}`,
+		`{"type": "rewriter", "args_rewriters": [{"type": "shadow_table", "suffix": "_s"}]}`,
+		`{"type": "rewriter", "sql_rewriters": [{"type": "tenant_isolation"}]}`,
+	}
+	for _, c := range errCases {
+		_, err := sqlkit.UnmarshalRewriter([]byte(c))
+		assert.NotNilf(t, err, c)
+	}
+}
+
+func TestRewriteMiddlewareReload(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.ShadowTable{Suffix: "_a"},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:reload", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:reload", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id) VALUES (1)")
+	assert.Nil(t, err)
+
+	var id int
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT id FROM t").Scan(&id))
+	assert.Equal(t, 1, id)
+	assert.Nil(t, rm.ReloadJSON(ctx, []byte(`{"type":"shadow_table","suffix":"_b"}`)))
+	assert.Equal(t, "_b", rm.CurrentRewriter().(*sqlkit.ShadowTable).Suffix)
+	_, err = db.ExecContext(ctx, "CREATE TABLE t (id INTEGER)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t (id) VALUES (2)")
+	assert.Nil(t, err)
+	assert.Nil(t, db.QueryRowContext(ctx, "SELECT id FROM t").Scan(&id))
+	assert.Equal(t, 2, id)
+
+	assert.NotNil(t, rm.ReloadJSON(ctx, []byte(`{"type":"shadow_table"}`)))
+	assert.Equal(t, "_b", rm.CurrentRewriter().(*sqlkit.ShadowTable).Suffix)
+}
+
+func TestAuditRules(t *testing.T) {
+	rules, err := sqlkit.UnmarshalAuditRules([]byte(`[
+		{"type": "pattern", "pattern": "(?i)^select \\*"},
+		{"type": "pattern", "pattern": "(?i)^delete", "alarm_type": "banned", "reason": "no delete"},
+		{"type": "explain", "types": ["range"], "tables": ["orders"], "alarm_rows": 100, "banned_rows": 1000}
+	]`))
+	assert.Nil(t, err)
+	assert.Len(t, rules, 3)
+	assert.Nil(t, rules.Provision(context.Background()))
+
+	table, typ := "orders", "range"
+	rows := func(n int) []mysql.ExplainRow {
+		return []mysql.ExplainRow{{Table: &table, Type: &typ, Rows: &n}}
+	}
+	var cases = []struct {
+		query     string
+		ers       []mysql.ExplainRow
+		alarmType sqlkit.AlarmType
+		reason    string
+	}{
+		{"SELECT id FROM orders", rows(10), sqlkit.Normal, ""},
+		{"SELECT * FROM orders", rows(10), sqlkit.Alarm, "rule:pattern:(?i)^select \\*"},
+		{"DELETE FROM orders", rows(10), sqlkit.Banned, "rule:pattern:no delete"},
+		{"SELECT id FROM orders", rows(500), sqlkit.Alarm, "rule:explain:type:range"},
+		{"SELECT * FROM orders", rows(5000), sqlkit.Banned, "rule:explain:type:range"},
+	}
+	for _, c := range cases {
+		alarmType, reason := rules.Detect(c.query, c.ers)
+		assert.Equalf(t, c.alarmType, alarmType, c.query)
+		assert.Equalf(t, c.reason, reason, c.query)
+	}
+
+	encoded, err := json.Marshal(rules)
+	assert.Nil(t, err)
+	decoded, err := sqlkit.UnmarshalAuditRules(encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, "no delete", decoded[1].(*sqlkit.PatternRule).Reason)
+
+	audit := &sqlkit.Audit{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"database_name":"db","rules":[{"type":"pattern","pattern":"^DELETE"}]}`), audit))
+	assert.Nil(t, audit.Provision(context.Background()))
+	alarmType, _ := audit.DetectRules("DELETE FROM t", nil)
+	assert.Equal(t, sqlkit.Alarm, alarmType)
+	assert.Nil(t, audit.SetRules(context.Background(), nil))
+	alarmType, _ = audit.DetectRules("DELETE FROM t", nil)
+	assert.Equal(t, sqlkit.Normal, alarmType)
+	assert.NotNil(t, audit.SetRules(context.Background(), sqlkit.AuditRuleList{&sqlkit.PatternRule{Pattern: "("}}))
+
+	_, err = sqlkit.UnmarshalAuditRules([]byte(`[{"type":"unknown"}]`))
+	assert.NotNil(t, err)
+}
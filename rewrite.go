@@ -2,18 +2,65 @@ package sqlkit
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"sync"
 
+	"github.com/ccmonky/sqlkit/fingerprint"
 	"github.com/pingcap/tidb/parser"
 	"github.com/pingcap/tidb/parser/ast"
 	"github.com/pingcap/tidb/parser/format"
 	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/opcode"
 	_ "github.com/pingcap/tidb/types/parser_driver"
 	"github.com/pkg/errors"
 	"go.uber.org/zap"
 )
 
+// restoreFlags used to restore the rewritten statements, NOTE: string literals must be quoted
+const restoreFlags = format.RestoreKeyWordUppercase | format.RestoreStringSingleQuotes | format.RestoreStringWithoutDefaultCharset
+
+// restore restores the node with restoreFlags
+func restore(node ast.Node) (string, error) {
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(restoreFlags, &sb)); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
+// restoreBackQuoted restores the node with names backquoted, since they may be reserved words, e.g. `key`,
+// the backquotes of user variables are removed, since `@name` is also the named parameter of some drivers, e.g. sqlite3
+func restoreBackQuoted(node ast.Node) (string, error) {
+	var sb strings.Builder
+	if err := node.Restore(format.NewRestoreCtx(restoreFlags|format.RestoreNameBackQuotes, &sb)); err != nil {
+		return "", err
+	}
+	restored := sb.String()
+	sb.Reset()
+	last := 0
+	scanSql(restored, func(kind byte, start, end int) {
+		if kind != '`' || start == 0 || restored[start-1] != '@' || end-start < 3 {
+			return
+		}
+		name := restored[start+1 : end-1]
+		for i := 0; i < len(name); i++ {
+			if !isNameByte(name[i]) {
+				return
+			}
+		}
+		sb.WriteString(restored[last:start])
+		sb.WriteString(name)
+		last = end
+	})
+	if last == 0 {
+		return restored, nil
+	}
+	sb.WriteString(restored[last:])
+	return sb.String(), nil
+}
+
 type RewriterBase interface {
 	Name() string
 	Provision(context.Context) error
@@ -25,6 +72,18 @@ type RewriterInterface interface {
 	Rewrite(sql string, args []any) (string, []any, error)
 }
 
+// ContextRewriter is an optional extension of RewriterInterface which rewrites with ctx, e.g. the tenant is read from ctx,
+// RewriteMiddleware calls `RewriteContext` instead of `Rewrite` if implemented
+type ContextRewriter interface {
+	RewriteContext(ctx context.Context, sql string, args []any) (string, []any, error)
+}
+
+// ArgChecker is an optional extension of RewriterInterface which accepts the args rejected by database/sql,
+// e.g. the slices expanded by `ExpandSlices`, RewriteMiddleware checks the args with `CheckArg` if implemented
+type ArgChecker interface {
+	CheckArg(arg any) bool
+}
+
 type SqlRewriter interface {
 	RewriterBase
 	RewriteSql(sql string) (string, error)
@@ -39,6 +98,12 @@ type ArgsRewriter interface {
 type Rewrite struct {
 	GlobalRewriter  *Rewriter            `json:"global_rewriter,omitempty"`
 	CustomRewriters map[string]*Rewriter `json:"custom_rewriters,omitempty"`
+
+	// Fingerprint if true, CustomRewriters are matched by fingerprint instead of the exact query,
+	// the keys can be raw sqls, fingerprints or digests, see `fingerprint.Key`
+	Fingerprint bool `json:"fingerprint,omitempty"`
+
+	customs map[string]*Rewriter
 }
 
 func (r *Rewrite) Name() string {
@@ -51,12 +116,17 @@ func (r *Rewrite) Provision(ctx context.Context) error {
 			return errors.WithMessagef(err, "global rewriter provision failed")
 		}
 	}
+	r.customs = make(map[string]*Rewriter, len(r.CustomRewriters))
 	for sql, rr := range r.CustomRewriters {
 		if rr != nil {
 			if err := rr.Provision(ctx); err != nil {
 				return errors.WithMessagef(err, "custom rewriter provision failed: %s", sql)
 			}
 		}
+		if r.Fingerprint {
+			sql = fingerprint.Key(sql)
+		}
+		r.customs[sql] = rr
 	}
 	return nil
 }
@@ -81,7 +151,7 @@ func (r Rewrite) Rewrite(sql string, args []any) (string, []any, error) {
 		}
 	}
 	if r.CustomRewriters != nil {
-		if cr, ok := r.CustomRewriters[sql]; ok {
+		if cr, ok := r.lookup(sql); ok && cr != nil {
 			sql, args, err = cr.Rewrite(sql, args)
 			if err != nil {
 				return "", nil, errors.WithMessagef(err, "custom rewrite failed: %s: %s", cr.Name(), sql)
@@ -91,6 +161,20 @@ func (r Rewrite) Rewrite(sql string, args []any) (string, []any, error) {
 	return sql, args, nil
 }
 
+// lookup return the custom rewriter of the sql
+func (r Rewrite) lookup(sql string) (*Rewriter, bool) {
+	if !r.Fingerprint {
+		cr, ok := r.CustomRewriters[sql]
+		return cr, ok
+	}
+	fp := fingerprint.Fingerprint(sql)
+	if cr, ok := r.customs[fp]; ok {
+		return cr, true
+	}
+	cr, ok := r.customs[fingerprint.DigestFingerprint(fp)]
+	return cr, ok
+}
+
 type Rewriter struct {
 	SqlRewriters  []SqlRewriter  `json:"sql_rewriters,omitempty"`
 	ArgsRewriters []ArgsRewriter `json:"args_rewriters,omitempty"`
@@ -113,7 +197,7 @@ func (rr Rewriter) Name() string {
 			arName += ar.Name() + "->"
 		}
 	}
-	return "[sql]%s[args]%s"
+	return fmt.Sprintf("[sql]%s[args]%s", srName, arName)
 }
 
 func (rr Rewriter) Provision(ctx context.Context) error {
@@ -156,13 +240,108 @@ func (rr Rewriter) Rewrite(sql string, args []any) (string, []any, error) {
 	return sql, args, nil
 }
 
+// rewriterJSON the JSON form of Rewriter, the rewriters are objects with a `type` field, see `RewriterRegistry`
+type rewriterJSON struct {
+	SqlRewriters  json.RawMessage `json:"sql_rewriters,omitempty"`
+	ArgsRewriters json.RawMessage `json:"args_rewriters,omitempty"`
+}
+
+func (rr Rewriter) MarshalJSON() ([]byte, error) {
+	var (
+		rj  rewriterJSON
+		err error
+	)
+	if len(rr.SqlRewriters) > 0 {
+		srs := make([]RewriterBase, len(rr.SqlRewriters))
+		for i, sr := range rr.SqlRewriters {
+			srs[i] = sr
+		}
+		if rj.SqlRewriters, err = RewriterRegistry.MarshalList(srs); err != nil {
+			return nil, err
+		}
+	}
+	if len(rr.ArgsRewriters) > 0 {
+		ars := make([]RewriterBase, len(rr.ArgsRewriters))
+		for i, ar := range rr.ArgsRewriters {
+			ars[i] = ar
+		}
+		if rj.ArgsRewriters, err = RewriterRegistry.MarshalList(ars); err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(rj)
+}
+
+func (rr *Rewriter) UnmarshalJSON(data []byte) error {
+	var rj rewriterJSON
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	rr.SqlRewriters, rr.ArgsRewriters = nil, nil
+	if len(rj.SqlRewriters) > 0 {
+		vs, err := RewriterRegistry.UnmarshalList(rj.SqlRewriters)
+		if err != nil {
+			return errors.WithMessage(err, "unmarshal sql rewriters failed")
+		}
+		for _, v := range vs {
+			sr, ok := v.(SqlRewriter)
+			if !ok {
+				return errors.Errorf("%T is not a SqlRewriter", v)
+			}
+			rr.SqlRewriters = append(rr.SqlRewriters, sr)
+		}
+	}
+	if len(rj.ArgsRewriters) > 0 {
+		vs, err := RewriterRegistry.UnmarshalList(rj.ArgsRewriters)
+		if err != nil {
+			return errors.WithMessage(err, "unmarshal args rewriters failed")
+		}
+		for _, v := range vs {
+			ar, ok := v.(ArgsRewriter)
+			if !ok {
+				return errors.Errorf("%T is not an ArgsRewriter", v)
+			}
+			rr.ArgsRewriters = append(rr.ArgsRewriters, ar)
+		}
+	}
+	return nil
+}
+
+var (
+	// ErrShadowWriteRefused returned by ShadowTable if a statement writes to a table which has no shadow copy
+	ErrShadowWriteRefused = errors.New("write to table without shadow refused")
+
+	// ErrShadowUnsupported returned by ShadowTable if an unsupported statement references tables which may be shadowed
+	ErrShadowUnsupported = errors.New("statement unsupported by shadow table")
+)
+
+// ShadowTable rewrites table names to shadow ones, usually used with `RewriteMiddleware.When` = `IsShadow`
+// to route load-test traffic to shadow tables.
+// Supported statements: SELECT(including UNION), INSERT, REPLACE, UPDATE, DELETE, CREATE TABLE, ALTER TABLE and TRUNCATE,
+// other statements are kept as is if they reference no tables or only ExcludeTables(writes to which are refused),
+// otherwise they are refused with ErrShadowUnsupported, e.g. `DROP TABLE t`, `RENAME TABLE`, `LOAD DATA`.
+// Multi-statements are rewritten one by one, and the names of common table expressions are never renamed.
+// NOTE: `CREATE TABLE t LIKE t` is rewritten to `CREATE TABLE t_shadow LIKE t`, so shadow tables can be created from originals.
 type ShadowTable struct {
 	Prefix string `json:"prefix,omitempty"`
 	Suffix string `json:"suffix,omitempty"`
 
-	parser *parser.Parser
-	cache  sync.Map
-	logger *zap.Logger
+	// Tables tables which have shadow copies(allow list), reads of other tables fall back to the originals, while writes are refused.
+	// If empty, all tables are considered to have shadow copies.
+	Tables []string `json:"tables,omitempty"`
+
+	// ExcludeTables tables which must never be shadowed(deny list), e.g. dictionary tables, which are treated as tables without shadow copies
+	ExcludeTables []string `json:"exclude_tables,omitempty"`
+
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser   *parser.Parser
+	mu       sync.Mutex // NOTE: parser is not goroutine safe
+	cache    *Cache[string, string]
+	logger   *zap.Logger
+	tables   map[string]struct{}
+	excludes map[string]struct{}
 }
 
 func (st *ShadowTable) Name() string {
@@ -174,68 +353,400 @@ func (st *ShadowTable) Provision(ctx context.Context) error {
 		return errors.New("shadow table with empty prefix and suffix")
 	}
 	st.parser = parser.New()
+	st.cache = NewCache[string, string](st.Name(), st.Cache)
+	st.tables = tableSet(st.Tables)
+	st.excludes = tableSet(st.ExcludeTables)
 	return nil
 }
 
+func tableSet(tables []string) map[string]struct{} {
+	if len(tables) == 0 {
+		return nil
+	}
+	set := make(map[string]struct{}, len(tables))
+	for _, table := range tables {
+		set[strings.ToLower(table)] = struct{}{}
+	}
+	return set
+}
+
 func (st *ShadowTable) SetLogger(logger *zap.Logger) {
 	st.logger = logger
 }
 
+// HasShadow reports whether the table has a shadow copy
+func (st *ShadowTable) HasShadow(table string) bool {
+	table = strings.ToLower(table)
+	if _, ok := st.excludes[table]; ok {
+		return false
+	}
+	if len(st.tables) == 0 {
+		return true
+	}
+	_, ok := st.tables[table]
+	return ok
+}
+
+// partial reports whether some tables have no shadow copies
+func (st *ShadowTable) partial() bool {
+	return len(st.tables) > 0 || len(st.excludes) > 0
+}
+
+// CreateShadowTableSql return the sql which creates the shadow table from the original one
+func (st *ShadowTable) CreateShadowTableSql(table string) string {
+	return "CREATE TABLE IF NOT EXISTS " + st.shadowName(table) + " LIKE " + table
+}
+
+func (st *ShadowTable) shadowName(name string) string {
+	return st.Prefix + name + st.Suffix
+}
+
 func (st *ShadowTable) Enter(in ast.Node) (ast.Node, bool) {
+	return (&shadowTableVisitor{st: st}).Enter(in)
+}
+
+func (st *ShadowTable) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// shadowTableVisitor renames tables, aliases and column qualifiers of a statement
+type shadowTableVisitor struct {
+	st         *ShadowTable
+	aliases    map[string]bool             // alias -> whether renamed, nil means all renamed
+	qualifiers map[*ast.TableName]struct{} // table names which are actually qualifiers, e.g. `DELETE a FROM t AS a`
+	keeps      map[*ast.TableName]struct{} // table names which are kept, e.g. `LIKE t` of `CREATE TABLE`
+	ctes       map[string]struct{}         // names of common table expressions, which are never renamed
+}
+
+func (v *shadowTableVisitor) Enter(in ast.Node) (ast.Node, bool) {
 	switch n := in.(type) {
 	case *ast.TableName:
-		n.Name = model.NewCIStr(st.Prefix + n.Name.String() + st.Suffix)
+		if _, ok := v.keeps[n]; ok || v.isCTE(n) {
+			break
+		}
+		if _, ok := v.qualifiers[n]; ok {
+			if v.renameQualifier(n.Name.String()) {
+				n.Name = model.NewCIStr(v.st.shadowName(n.Name.String()))
+			}
+		} else if v.st.HasShadow(n.Name.String()) {
+			n.Name = model.NewCIStr(v.st.shadowName(n.Name.String()))
+		}
 	case *ast.TableSource:
-		if n.AsName.String() != "" {
-			n.AsName = model.NewCIStr(st.Prefix + n.AsName.String() + st.Suffix)
+		if n.AsName.String() != "" && v.renameAlias(n.AsName.String()) {
+			n.AsName = model.NewCIStr(v.st.shadowName(n.AsName.String()))
 		}
 	case *ast.ColumnName:
-		if n.Table.String() != "" {
-			n.Table = model.NewCIStr(st.Prefix + n.Table.String() + st.Suffix)
+		if n.Table.String() != "" && v.renameQualifier(n.Table.String()) {
+			n.Table = model.NewCIStr(v.st.shadowName(n.Table.String()))
 		}
 	}
 	return in, false
 }
 
-func (st *ShadowTable) Leave(in ast.Node) (ast.Node, bool) {
+func (v *shadowTableVisitor) Leave(in ast.Node) (ast.Node, bool) {
 	return in, true
 }
 
+func (v *shadowTableVisitor) renameAlias(alias string) bool {
+	if v.aliases == nil {
+		return true
+	}
+	return v.aliases[strings.ToLower(alias)]
+}
+
+func (v *shadowTableVisitor) renameQualifier(qualifier string) bool {
+	if _, ok := v.ctes[strings.ToLower(qualifier)]; ok {
+		return false
+	}
+	if v.aliases == nil {
+		return true
+	}
+	if renamed, ok := v.aliases[strings.ToLower(qualifier)]; ok {
+		return renamed
+	}
+	return v.st.HasShadow(qualifier)
+}
+
+// isCTE reports whether the table name references a common table expression
+func (v *shadowTableVisitor) isCTE(tn *ast.TableName) bool {
+	if tn.Schema.L != "" {
+		return false
+	}
+	_, ok := v.ctes[tn.Name.L]
+	return ok
+}
+
+// collect collects aliases and qualifiers of the statement before renaming
+func (v *shadowTableVisitor) collect(node ast.Node) {
+	c := &nodeCollector{}
+	node.Accept(c)
+	v.aliases = make(map[string]bool)
+	v.qualifiers = make(map[*ast.TableName]struct{})
+	for _, n := range c.nodes {
+		switch n := n.(type) {
+		case *ast.TableSource:
+			if n.AsName.String() == "" {
+				continue
+			}
+			if tn, ok := n.Source.(*ast.TableName); ok {
+				v.aliases[n.AsName.L] = v.st.HasShadow(tn.Name.String())
+			} else {
+				v.aliases[n.AsName.L] = true // NOTE: derived table
+			}
+		case *ast.DeleteStmt:
+			if n.IsMultiTable && n.Tables != nil {
+				for _, tn := range n.Tables.Tables {
+					v.qualifiers[tn] = struct{}{}
+				}
+			}
+		}
+	}
+}
+
+// checkWrites refuse the statement if it writes to a table without shadow copy
+func (v *shadowTableVisitor) checkWrites(node ast.StmtNode) error {
+	var tables []string
+	switch n := node.(type) {
+	case *ast.InsertStmt:
+		tables = writeTables(n.Table)
+	case *ast.UpdateStmt:
+		tables = writeTables(n.TableRefs) // NOTE: all tables joined are considered written
+	case *ast.DeleteStmt:
+		if n.IsMultiTable && n.Tables != nil {
+			for _, tn := range n.Tables.Tables {
+				if !v.renameQualifier(tn.Name.String()) {
+					return errors.WithMessagef(ErrShadowWriteRefused, "table %s", tn.Name.String())
+				}
+			}
+			return nil
+		}
+		tables = writeTables(n.TableRefs)
+	case *ast.CreateTableStmt:
+		tables = []string{n.Table.Name.String()}
+	case *ast.AlterTableStmt:
+		tables = []string{n.Table.Name.String()}
+	case *ast.TruncateTableStmt:
+		tables = []string{n.Table.Name.String()}
+	}
+	for _, table := range tables {
+		if !v.st.HasShadow(table) {
+			return errors.WithMessagef(ErrShadowWriteRefused, "table %s", table)
+		}
+	}
+	return nil
+}
+
+// writeTables return the table names in the table refs except those in subqueries
+func writeTables(refs *ast.TableRefsClause) []string {
+	if refs == nil || refs.TableRefs == nil {
+		return nil
+	}
+	c := &nodeCollector{skipSubquery: true}
+	refs.TableRefs.Accept(c)
+	var tables []string
+	for _, n := range c.nodes {
+		if tn, ok := n.(*ast.TableName); ok {
+			tables = append(tables, tn.Name.String())
+		}
+	}
+	return tables
+}
+
+// nodeCollector collects nodes of a statement
+type nodeCollector struct {
+	skipSubquery bool
+	nodes        []ast.Node
+}
+
+func (c *nodeCollector) Enter(in ast.Node) (ast.Node, bool) {
+	if c.skipSubquery {
+		switch in.(type) {
+		case *ast.SelectStmt, *ast.SetOprStmt, *ast.SubqueryExpr:
+			return in, true
+		}
+	}
+	c.nodes = append(c.nodes, in)
+	return in, false
+}
+
+func (c *nodeCollector) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+// errJoinWithoutOn returned by injectPredicates if a predicate should be added to an outer join without ON condition
+var errJoinWithoutOn = errors.New("outer join without ON condition")
+
+// injectPredicates adds the predicates of the tables in the join tree to where, or to the ON condition of the outer join
+// if the table is on the nullable side, predicate returns nil if the table is not concerned
+func injectPredicates(node ast.ResultSetNode, where *ast.ExprNode, predicate func(*ast.TableSource, *ast.TableName) ast.ExprNode) error {
+	switch n := node.(type) {
+	case *ast.Join:
+		if n.Right == nil {
+			return injectPredicates(n.Left, where, predicate)
+		}
+		left, right := where, where
+		if n.Tp == ast.LeftJoin || n.Tp == ast.RightJoin {
+			var on *ast.ExprNode
+			if n.On != nil {
+				on = &n.On.Expr
+			}
+			if n.Tp == ast.LeftJoin {
+				right = on
+			} else {
+				left = on
+			}
+		}
+		if err := injectPredicates(n.Left, left, predicate); err != nil {
+			return err
+		}
+		return injectPredicates(n.Right, right, predicate)
+	case *ast.TableSource:
+		tn, ok := n.Source.(*ast.TableName)
+		if !ok {
+			return nil // NOTE: derived tables are visited as SelectStmt
+		}
+		expr := predicate(n, tn)
+		if expr == nil {
+			return nil
+		}
+		if where == nil {
+			return errors.WithMessagef(errJoinWithoutOn, "table %s", tn.Name.String())
+		}
+		*where = andExpr(*where, expr)
+		return nil
+	default:
+		return errors.Errorf("unsupported table refs %T", node)
+	}
+}
+
+// andExpr return `(expr) AND predicate`, or predicate if expr is nil
+func andExpr(expr, predicate ast.ExprNode) ast.ExprNode {
+	if expr == nil {
+		return predicate
+	}
+	return &ast.BinaryOperationExpr{
+		Op: opcode.LogicAnd,
+		L:  &ast.ParenthesesExpr{Expr: expr},
+		R:  predicate,
+	}
+}
+
+// qualifier return the column qualifier of the table source, i.e. the alias or the table name
+func qualifier(ts *ast.TableSource, tn *ast.TableName) (model.CIStr, model.CIStr) {
+	if ts.AsName.String() != "" {
+		return model.CIStr{}, ts.AsName
+	}
+	return tn.Schema, tn.Name
+}
+
+func (st *ShadowTable) Rewrite(sql string, args []any) (string, []any, error) {
+	sql, err := st.RewriteSql(sql)
+	return sql, args, err
+}
+
 func (st *ShadowTable) RewriteSql(sql string) (string, error) {
 	if result, ok := st.cache.Load(sql); ok {
-		return result.(string), nil
+		return result, nil
 	}
+	st.mu.Lock()
 	stmtNodes, warns, err := st.parser.Parse(sql, "", "")
+	st.mu.Unlock()
 	if err != nil {
 		return "", errors.WithMessagef(err, "parser sql faield: %s", sql)
 	}
 	if len(warns) > 0 && st.logger != nil {
 		st.logger.Debug("shadow table warnings", zap.Any("warns", warns), zap.String("sql", sql))
 	}
-	stmtNode := stmtNodes[0]
-	switch stmtNode.(type) {
-	case *ast.SelectStmt, *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+	if len(stmtNodes) == 0 {
+		return sql, nil
+	}
+	results := make([]string, 0, len(stmtNodes))
+	for _, stmtNode := range stmtNodes {
+		result, ok, err := st.rewriteStmt(stmtNode)
+		if err != nil {
+			return "", errors.WithMessagef(err, "sql: %s", sql)
+		}
+		if !ok {
+			if len(stmtNodes) == 1 {
+				return sql, nil
+			}
+			result = strings.TrimSuffix(strings.TrimSpace(stmtNode.Text()), ";")
+		}
+		results = append(results, result)
+	}
+	result := strings.Join(results, "; ")
+	st.cache.Store(sql, result)
+	return result, nil
+}
+
+// rewriteStmt rewrites a single statement, return false if the statement is not supported
+func (st *ShadowTable) rewriteStmt(stmtNode ast.StmtNode) (string, bool, error) {
+	visitor := &shadowTableVisitor{st: st, ctes: make(map[string]struct{})}
+	c := &nodeCollector{}
+	stmtNode.Accept(c)
+	for _, n := range c.nodes {
+		if with, ok := n.(*ast.WithClause); ok {
+			for _, cte := range with.CTEs {
+				visitor.ctes[cte.Name.L] = struct{}{}
+			}
+		}
+	}
+	switch n := stmtNode.(type) {
+	case *ast.SelectStmt, *ast.SetOprStmt, *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+	case *ast.AlterTableStmt, *ast.TruncateTableStmt:
+	case *ast.CreateTableStmt:
+		if n.ReferTable != nil {
+			visitor.keeps = map[*ast.TableName]struct{}{n.ReferTable: {}}
+		}
 	default:
-		return sql, nil // FIXME
+		return "", false, st.checkUnsupported(stmtNode, c.nodes)
+	}
+	if st.partial() {
+		visitor.collect(stmtNode)
+		if err := visitor.checkWrites(stmtNode); err != nil {
+			return "", false, err
+		}
 	}
-	node, accept := stmtNode.Accept(st)
+	node, accept := stmtNode.Accept(visitor)
 	if !accept {
-		return "", errors.WithMessagef(err, "accept failed for sql: %s", sql)
+		return "", false, errors.New("accept failed")
 	}
-	var sb strings.Builder
-	ctx := format.NewRestoreCtx(format.RestoreKeyWordUppercase, &sb)
-	err = node.Restore(ctx)
+	result, err := restore(node)
 	if err != nil {
-		return "", errors.WithMessagef(err, "restore failed for sql: %s; ctx: %v", sql, ctx)
+		return "", false, errors.WithMessagef(err, "restore failed")
 	}
-	st.cache.Store(sql, sb.String())
-	return sb.String(), nil
+	return result, true, nil
+}
+
+// checkUnsupported refuse the unsupported statement if it references tables which may be shadowed,
+// or writes to excluded tables, NOTE: fail closed since the statement would reach the original tables
+func (st *ShadowTable) checkUnsupported(stmtNode ast.StmtNode, nodes []ast.Node) error {
+	var tables []string
+	for _, n := range nodes {
+		tn, ok := n.(*ast.TableName)
+		if !ok {
+			continue
+		}
+		if _, ok := st.excludes[tn.Name.L]; !ok {
+			return errors.WithMessagef(ErrShadowUnsupported, "%T on table %s", stmtNode, tn.Name.String())
+		}
+		tables = append(tables, tn.Name.String())
+	}
+	if len(tables) == 0 {
+		return nil
+	}
+	switch stmtNode.(type) {
+	case *ast.DropTableStmt, *ast.RenameTableStmt, *ast.CreateIndexStmt, *ast.DropIndexStmt, *ast.LoadDataStmt,
+		*ast.CreateViewStmt, *ast.RepairTableStmt, *ast.LockTablesStmt:
+		return errors.WithMessagef(ErrShadowWriteRefused, "%T on table %s", stmtNode, strings.Join(tables, ","))
+	}
+	return nil
 }
 
 func (st *ShadowTable) Sqls() map[string]string {
 	snapshot := make(map[string]string)
-	st.cache.Range(func(k, v any) bool {
-		snapshot[k.(string)] = v.(string)
+	st.cache.Range(func(k, v string) bool {
+		snapshot[k] = v
 		return true
 	})
 	return snapshot
@@ -245,4 +756,5 @@ var (
 	_ RewriterInterface = (*Rewrite)(nil)
 	_ RewriterInterface = (*Rewriter)(nil)
 	_ SqlRewriter       = (*ShadowTable)(nil)
+	_ RewriterInterface = (*ShadowTable)(nil)
 )
@@ -0,0 +1,297 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"go.uber.org/atomic"
+	"go.uber.org/zap"
+)
+
+// ErrStmtRewriteMismatch returned if a prepared statement is executed with ctx which `When` differs from the one prepared,
+// e.g. a statement prepared for production traffic is executed for shadow traffic
+var ErrStmtRewriteMismatch = errors.New("rewrite of statement differs between prepare and execution")
+
+// RewriteMiddleware is a middleware which rewrites the query and args with the Rewriter before they reach the driver,
+// for prepared statements, the query is rewritten when prepared and the args are rewritten when executed
+// (NOTE: `ErrStmtRewriteMismatch` is returned if `When` differs between prepare and execution).
+// The original->rewritten pairs are recorded in ctx, see `GetRewrites`.
+// In dry-run mode the rewrites are computed and recorded but the original queries are executed, see `DryRunRecords`.
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{
+//         Rewriter: &sqlkit.Rewrite{
+//             GlobalRewriter: &sqlkit.Rewriter{
+//                 SqlRewriters: []sqlkit.SqlRewriter{&sqlkit.ShadowTable{Suffix: "_shadow"}},
+//             },
+//         },
+//     }
+//     err := rm.Provision(ctx)
+//     sql.Register("rewrite:mysql", sqlkit.WrapChain(&mysql.MySQLDriver{}, rm, audit))
+//
+type RewriteMiddleware struct {
+	Rewriter RewriterInterface `json:"-"`
+
+	// When rewrites only if it returns true, e.g. `IsShadow`, default is always
+	When func(context.Context) bool `json:"-"`
+
+	// DryRun computes the rewrites but executes the original queries, the rewrites, errors and timings are recorded
+	// to validate the rewriter against real traffic before enabling it, can be turned off at runtime by `SetDryRun`
+	DryRun bool `json:"dry_run,omitempty"`
+
+	// DryRunCache config of the cache of dry-run records, default is `DefaultCacheSize` queries without TTL
+	DryRunCache CacheConfig `json:"dry_run_cache,omitempty"`
+
+	logger        *zap.Logger
+	current       atomic.Value // NOTE: rewriterHolder, replaced by Reload
+	dryRun        atomic.Bool
+	dryRunMu      sync.Mutex // NOTE: guards the dry-run records
+	dryRunRecords *Cache[string, *DryRunRecord]
+}
+
+// rewriterHolder holds the current rewriter, since atomic.Value requires the values of the same concrete type
+type rewriterHolder struct {
+	RewriterInterface
+}
+
+// RewriteRecord an original->rewritten pair of query and args
+type RewriteRecord struct {
+	Rewriter      string `json:"rewriter"`
+	OriginalQuery string `json:"original_query"`
+	OriginalArgs  []any  `json:"original_args,omitempty"`
+	Query         string `json:"query"`
+	Args          []any  `json:"args,omitempty"`
+}
+
+type rewritesCtxKey struct{}
+
+// GetRewrites return the rewrite records carried by ctx in order
+func GetRewrites(ctx context.Context) []RewriteRecord {
+	records, _ := ctx.Value(rewritesCtxKey{}).([]RewriteRecord)
+	return records
+}
+
+func withRewrite(ctx context.Context, record RewriteRecord) context.Context {
+	records := GetRewrites(ctx)
+	newRecords := make([]RewriteRecord, len(records), len(records)+1)
+	copy(newRecords, records)
+	return context.WithValue(ctx, rewritesCtxKey{}, append(newRecords, record))
+}
+
+// rewriteFields return the original query for logging if rewritten
+func rewriteFields(ctx context.Context) []zap.Field {
+	records := GetRewrites(ctx)
+	if len(records) == 0 {
+		return nil
+	}
+	return []zap.Field{zap.String("original_query", records[0].OriginalQuery)}
+}
+
+func (rm *RewriteMiddleware) Provision(ctx context.Context) error {
+	if rm.Rewriter == nil {
+		return errors.New("rewrite middleware with nil rewriter")
+	}
+	if rm.logger == nil {
+		rm.logger = zap.NewNop()
+	}
+	rm.Rewriter.SetLogger(rm.logger)
+	if err := rm.Rewriter.Provision(ctx); err != nil {
+		return err
+	}
+	rm.current.Store(rewriterHolder{rm.Rewriter})
+	rm.dryRun.Store(rm.DryRun)
+	rm.dryRunRecords = NewCache[string, *DryRunRecord]("dry_run", rm.DryRunCache)
+	return nil
+}
+
+// Reload provisions the rewriter and replaces the current one with it, the statements being rewritten are not affected,
+// and the dry-run records of the previous rewriter are cleared, e.g. used to hot reload the rewriter when config changed
+func (rm *RewriteMiddleware) Reload(ctx context.Context, rewriter RewriterInterface) error {
+	if rewriter == nil {
+		return errors.New("reload with nil rewriter")
+	}
+	if rm.logger == nil {
+		rm.logger = zap.NewNop()
+	}
+	rewriter.SetLogger(rm.logger)
+	if err := rewriter.Provision(ctx); err != nil {
+		return errors.WithMessagef(err, "provision %s failed", rewriter.Name())
+	}
+	rm.current.Store(rewriterHolder{rewriter})
+	if rm.dryRunRecords != nil {
+		rm.ClearDryRunRecords()
+	}
+	rm.logger.Info("rewriter reloaded", zap.String("rewriter", rewriter.Name()))
+	return nil
+}
+
+// ReloadJSON decodes the rewriter from the JSON object with a `type` field(see `RewriterRegistry`) and reloads it, e.g.
+// `{"type":"rewrite","global_rewriter":{"sql_rewriters":[{"type":"shadow_table","suffix":"_s"}]}}`
+func (rm *RewriteMiddleware) ReloadJSON(ctx context.Context, data []byte) error {
+	rewriter, err := UnmarshalRewriter(data)
+	if err != nil {
+		return err
+	}
+	return rm.Reload(ctx, rewriter)
+}
+
+// CurrentRewriter return the rewriter in use, which is `Rewriter` if not reloaded
+func (rm *RewriteMiddleware) CurrentRewriter() RewriterInterface {
+	if h, ok := rm.current.Load().(rewriterHolder); ok {
+		return h.RewriterInterface
+	}
+	return rm.Rewriter
+}
+
+func (rm *RewriteMiddleware) SetLogger(logger *zap.Logger) error {
+	if logger == nil {
+		return errors.New("nil logger")
+	}
+	rm.logger = logger
+	if rewriter := rm.CurrentRewriter(); rewriter != nil {
+		rewriter.SetLogger(logger)
+	}
+	return nil
+}
+
+func (rm *RewriteMiddleware) ExecContext(next ExecContext) ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		ctx, query, args, err := rm.rewrite(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, query, args)
+	}
+}
+
+func (rm *RewriteMiddleware) QueryContext(next QueryContext) QueryContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		ctx, query, args, err := rm.rewrite(ctx, query, args)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, query, args)
+	}
+}
+
+func (rm *RewriteMiddleware) PrepareContext(next PrepareContext) PrepareContext {
+	return func(ctx context.Context, query string) (driver.Stmt, error) {
+		ctx, query, _, err := rm.rewrite(ctx, query, nil)
+		if err != nil {
+			return nil, err
+		}
+		return next(ctx, query)
+	}
+}
+
+func (rm *RewriteMiddleware) CloseStmt(next CloseStmt) CloseStmt {
+	return next
+}
+
+func (rm *RewriteMiddleware) CheckNamedValue(next CheckNamedValue) CheckNamedValue {
+	return func(nv *driver.NamedValue) error {
+		if rm.IsDryRun() { // NOTE: the args reach the driver as is
+			return next(nv)
+		}
+		if ac, ok := rm.CurrentRewriter().(ArgChecker); ok && ac.CheckArg(nv.Value) {
+			return nil
+		}
+		return next(nv)
+	}
+}
+
+func (rm *RewriteMiddleware) rewrite(ctx context.Context, query string, args []driver.NamedValue) (context.Context, string, []driver.NamedValue, error) {
+	dryRun := rm.IsDryRun()
+	if rm.When != nil {
+		when := rm.When(ctx)
+		if stmtCtx, ok := GetStmtContext(ctx); ok && !dryRun && rm.When(stmtCtx) != when {
+			return ctx, query, args, errors.WithMessagef(ErrStmtRewriteMismatch, "query: %s", query)
+		}
+		if !when {
+			return ctx, query, args, nil
+		}
+	}
+	rewriter := rm.CurrentRewriter()
+	originalArgs := NamedValuesToArgs(args)
+	var (
+		rewritten     string
+		rewrittenArgs []any
+		err           error
+		start         = time.Now()
+	)
+	if cr, ok := rewriter.(ContextRewriter); ok {
+		rewritten, rewrittenArgs, err = cr.RewriteContext(ctx, query, originalArgs)
+	} else {
+		rewritten, rewrittenArgs, err = rewriter.Rewrite(query, originalArgs)
+	}
+	if dryRun {
+		rm.recordDryRun(rewriter.Name(), query, originalArgs, rewritten, rewrittenArgs, err, time.Since(start))
+		return ctx, query, args, nil
+	}
+	if err != nil {
+		rm.logger.Error("rewrite failed", zap.String("query", query), zap.Error(err))
+		return ctx, query, args, errors.WithMessagef(err, "rewrite failed: %s", query)
+	}
+	argsRewritten := !reflect.DeepEqual(originalArgs, rewrittenArgs)
+	if rewritten == query && !argsRewritten {
+		return ctx, query, args, nil
+	}
+	ctx = withRewrite(ctx, RewriteRecord{
+		Rewriter:      rewriter.Name(),
+		OriginalQuery: query,
+		OriginalArgs:  originalArgs,
+		Query:         rewritten,
+		Args:          rewrittenArgs,
+	})
+	if argsRewritten {
+		args = ArgsToNamedValues(rewrittenArgs)
+	}
+	return ctx, rewritten, args, nil
+}
+
+// NamedValuesToArgs converts driver.NamedValue to args, the named ones are converted to sql.NamedArg
+func NamedValuesToArgs(named []driver.NamedValue) []any {
+	if named == nil {
+		return nil
+	}
+	args := make([]any, len(named))
+	for _, nv := range named {
+		if nv.Name != "" {
+			args[nv.Ordinal-1] = sql.Named(nv.Name, nv.Value)
+		} else {
+			args[nv.Ordinal-1] = nv.Value
+		}
+	}
+	return args
+}
+
+// ArgsToNamedValues converts args to driver.NamedValue, which is the reverse of NamedValuesToArgs,
+// NOTE: the ordinals are assigned by position like database/sql
+func ArgsToNamedValues(args []any) []driver.NamedValue {
+	if args == nil {
+		return nil
+	}
+	named := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i].Ordinal = i + 1
+		if na, ok := arg.(sql.NamedArg); ok {
+			named[i].Name = na.Name
+			named[i].Value = na.Value
+		} else {
+			named[i].Value = arg
+		}
+	}
+	return named
+}
+
+var (
+	_ Middleware        = (*RewriteMiddleware)(nil)
+	_ PrepareMiddleware = (*RewriteMiddleware)(nil)
+	_ ArgsMiddleware    = (*RewriteMiddleware)(nil)
+)
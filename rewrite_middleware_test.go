@@ -0,0 +1,116 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+type upperArgs struct{}
+
+func (ua *upperArgs) Name() string                        { return "upper_args" }
+func (ua *upperArgs) Provision(ctx context.Context) error { return nil }
+func (ua *upperArgs) SetLogger(*zap.Logger)               {}
+
+func (ua *upperArgs) RewriteArgs(args []any) ([]any, error) {
+	rewritten := make([]any, len(args))
+	for i, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			rewritten[i] = strings.ToUpper(v)
+		case sql.NamedArg:
+			if s, ok := v.Value.(string); ok {
+				v.Value = strings.ToUpper(s)
+			}
+			rewritten[i] = v
+		default:
+			rewritten[i] = arg
+		}
+	}
+	return rewritten, nil
+}
+
+type rewritesRecorder struct {
+	recorder
+	records [][]sqlkit.RewriteRecord
+}
+
+func (r *rewritesRecorder) ExecContext(next sqlkit.ExecContext) sqlkit.ExecContext {
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+		r.records = append(r.records, sqlkit.GetRewrites(ctx))
+		return next(ctx, query, args)
+	}
+}
+
+func TestRewriteMiddleware(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.Rewrite{
+			GlobalRewriter: &sqlkit.Rewriter{
+				SqlRewriters:  []sqlkit.SqlRewriter{&sqlkit.ShadowTable{Suffix: "_shadow"}},
+				ArgsRewriters: []sqlkit.ArgsRewriter{&upperArgs{}},
+			},
+		},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	var calls []string
+	rr := &rewritesRecorder{recorder: recorder{name: "rewrites", calls: &calls}}
+	sql.Register("sqlite3:rewrite", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm, rr))
+	db, err := sql.Open("sqlite3:rewrite", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	assert.Len(t, rr.records, 1)
+	assert.Equal(t, "CREATE TABLE t1_shadow (id INT,text VARCHAR(16))", rr.records[0][0].Query)
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 (id, text) VALUES (?, ?)", 1, "foo")
+	assert.Nil(t, err)
+	assert.Equal(t, []sqlkit.RewriteRecord{{
+		Rewriter:      "rewrite",
+		OriginalQuery: "INSERT INTO t1 (id, text) VALUES (?, ?)",
+		OriginalArgs:  []any{int64(1), "foo"},
+		Query:         "INSERT INTO t1_shadow (id,text) VALUES (?,?)",
+		Args:          []any{int64(1), "FOO"},
+	}}, rr.records[1])
+
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 (id, text) VALUES (@id, @text)", sql.Named("id", 2), sql.Named("text", "bar"))
+	assert.Nil(t, err)
+
+	stmt, err := db.PrepareContext(ctx, "INSERT INTO t1 (id, text) VALUES (?, ?)")
+	assert.Nil(t, err)
+	_, err = stmt.ExecContext(ctx, 3, "baz")
+	assert.Nil(t, err)
+	assert.Nil(t, stmt.Close())
+
+	var texts []string
+	rows, err := db.QueryContext(ctx, "SELECT text FROM t1 ORDER BY id")
+	assert.Nil(t, err)
+	for rows.Next() {
+		var text string
+		assert.Nil(t, rows.Scan(&text))
+		texts = append(texts, text)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []string{"FOO", "BAR", "BAZ"}, texts)
+}
+
+func TestNamedValuesToArgs(t *testing.T) {
+	named := []driver.NamedValue{
+		{Ordinal: 1, Value: 1},
+		{Ordinal: 2, Name: "foo", Value: "bar"},
+	}
+	args := sqlkit.NamedValuesToArgs(named)
+	assert.Equal(t, []any{1, sql.Named("foo", "bar")}, args)
+	assert.Equal(t, named, sqlkit.ArgsToNamedValues(args))
+}
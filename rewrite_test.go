@@ -16,6 +16,24 @@ func TestRewrite(t *testing.T) {
 	assert.Equal(t, args, []any{1})
 }
 
+func TestRewriteFingerprint(t *testing.T) {
+	r := &sqlkit.Rewrite{
+		CustomRewriters: map[string]*sqlkit.Rewriter{
+			"select * from t where id = 1": {
+				SqlRewriters: []sqlkit.SqlRewriter{&sqlkit.ShadowTable{Suffix: "_shadow"}},
+			},
+		},
+		Fingerprint: true,
+	}
+	assert.Nil(t, r.Provision(context.Background()))
+	sql, _, err := r.Rewrite("SELECT * FROM t WHERE id = ?", []any{1})
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t_shadow WHERE id=?", sql)
+	sql, _, err = r.Rewrite("SELECT * FROM t WHERE name = ?", []any{1})
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT * FROM t WHERE name = ?", sql)
+}
+
 func TestShadowTable(t *testing.T) {
 	st := sqlkit.ShadowTable{
 		Suffix: "_shadow",
@@ -59,3 +77,112 @@ func BenchmarkRegisterTypeMultiple(b *testing.B) {
 		_, _ = st.RewriteSql(sql)
 	}
 }
+
+func TestShadowTableStatements(t *testing.T) {
+	st := sqlkit.ShadowTable{
+		Suffix:        "_shadow",
+		ExcludeTables: []string{"dict"},
+	}
+	err := st.Provision(context.Background())
+	assert.Nil(t, err)
+	var cases = []struct {
+		sql    string
+		shadow string
+		err    error
+	}{
+		{
+			sql:    "REPLACE INTO t (id, a) VALUES (?, ?)",
+			shadow: "REPLACE INTO t_shadow (id,a) VALUES (?,?)",
+		},
+		{
+			sql:    "INSERT INTO t (id, a) VALUES (1, 'it''s')",
+			shadow: "INSERT INTO t_shadow (id,a) VALUES (1,'it''s')",
+		},
+		{
+			sql:    "INSERT INTO t (id, a) SELECT id, name FROM s WHERE s.id > ?",
+			shadow: "INSERT INTO t_shadow (id,a) SELECT id,name FROM s_shadow WHERE s_shadow.id>?",
+		},
+		{
+			sql:    "INSERT INTO t (id, a) VALUES (?, ?) ON DUPLICATE KEY UPDATE a = VALUES(a), t.b = t.b + 1",
+			shadow: "INSERT INTO t_shadow (id,a) VALUES (?,?) ON DUPLICATE KEY UPDATE a=VALUES(a),t_shadow.b=t_shadow.b+1",
+		},
+		{
+			sql:    "CREATE TABLE t (id INT PRIMARY KEY, a VARCHAR(16))",
+			shadow: "CREATE TABLE t_shadow (id INT PRIMARY KEY,a VARCHAR(16))",
+		},
+		{
+			sql:    "CREATE TABLE t LIKE t",
+			shadow: "CREATE TABLE t_shadow LIKE t",
+		},
+		{
+			sql:    "ALTER TABLE t ADD COLUMN b INT",
+			shadow: "ALTER TABLE t_shadow ADD COLUMN b INT",
+		},
+		{
+			sql:    "TRUNCATE TABLE t",
+			shadow: "TRUNCATE TABLE t_shadow",
+		},
+		{
+			sql:    "SELECT a FROM t WHERE id = ? UNION ALL SELECT name FROM dict",
+			shadow: "SELECT a FROM t_shadow WHERE id=? UNION ALL SELECT name FROM dict",
+		},
+		{
+			sql:    "SET NAMES utf8mb4; UPDATE t SET a = ? WHERE id = ?; SELECT name FROM dict",
+			shadow: "SET NAMES utf8mb4; UPDATE t_shadow SET a=? WHERE id=?; SELECT name FROM dict",
+		},
+		{
+			sql:    "SHOW TABLES",
+			shadow: "SHOW TABLES",
+		},
+		{
+			sql: "TRUNCATE TABLE dict",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+		{
+			sql: "UPDATE t SET a = ?; DELETE FROM dict",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+		{
+			sql:    "WITH c AS (SELECT id FROM t) SELECT c.id FROM c",
+			shadow: "WITH c AS (SELECT id FROM t_shadow) SELECT c.id FROM c",
+		},
+		{
+			sql:    "SELECT COUNT(*) FROM dict; SET @x = (SELECT name FROM dict)",
+			shadow: "SELECT COUNT(1) FROM dict; SET @x = (SELECT name FROM dict)",
+		},
+		{
+			sql: "DROP TABLE orders",
+			err: sqlkit.ErrShadowUnsupported,
+		},
+		{
+			sql: "RENAME TABLE orders TO o2",
+			err: sqlkit.ErrShadowUnsupported,
+		},
+		{
+			sql: "CREATE INDEX idx_a ON orders (a)",
+			err: sqlkit.ErrShadowUnsupported,
+		},
+		{
+			sql: "UPDATE t SET a = ?; SET @x = (SELECT MAX(id) FROM orders)",
+			err: sqlkit.ErrShadowUnsupported,
+		},
+		{
+			sql: "LOAD DATA INFILE '/tmp/dict.csv' INTO TABLE dict",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+		{
+			sql: "DROP TABLE dict",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+	}
+	for _, tc := range cases {
+		s, err := st.RewriteSql(tc.sql)
+		if tc.err != nil {
+			assert.ErrorIs(t, err, tc.err, tc.sql)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.shadow, s)
+	}
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS t_shadow LIKE t", st.CreateShadowTableSql("t"))
+}
@@ -0,0 +1,183 @@
+package sqlkit
+
+import (
+	"context"
+	"database/sql/driver"
+	"io"
+	"reflect"
+)
+
+// RowsMiddleware is an optional extension of Middleware which observes and transforms the result stream of queries,
+// `OnRow` is called on every `Next` of the rows(NOTE: `io.EOF` is returned at the end of rows, and should be passed through),
+// and `OnClose` is called with the total rows and bytes read when the rows is closed.
+// NOTE: `OnRow(next)` and `OnClose(next)` are called once for every rows returned, so the state kept in closures is scoped to the rows.
+// The ctx and query are the ones which reached the driver in `QueryContext`.
+type RowsMiddleware interface {
+	OnRow(OnRow) OnRow
+	OnClose(OnClose) OnClose
+}
+
+type OnRow func(ctx context.Context, query string, columns []string, dest []driver.Value) error
+
+type OnClose func(ctx context.Context, query string, stats RowsStats) error
+
+// RowsStats statistics of rows read from driver
+type RowsStats struct {
+	Rows  int64 `json:"rows"`
+	Bytes int64 `json:"bytes"`
+}
+
+// wrapQueryContext wraps the rows returned by next if wrapper implements RowsMiddleware
+func wrapQueryContext(wrapper Middleware, next QueryContext) QueryContext {
+	rm, ok := wrapper.(RowsMiddleware)
+	if !ok {
+		return wrapper.QueryContext(next)
+	}
+	return func(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+		var rowsCtx context.Context
+		rowsQuery := query
+		rows, err := wrapper.QueryContext(func(c context.Context, q string, a []driver.NamedValue) (driver.Rows, error) {
+			rowsCtx, rowsQuery = c, q
+			return next(c, q, a)
+		})(ctx, query, args)
+		if err != nil || rows == nil {
+			return rows, err
+		}
+		if rowsCtx == nil { // NOTE: the driver is bypassed by a middleware, e.g. mock
+			rowsCtx = ctx
+		}
+		return newWrappedRows(rowsCtx, rowsQuery, rows, rm), nil
+	}
+}
+
+// WrappedRows implements a database/sql/driver.Rows and all optional interfaces of it,
+// for those not implemented by the underlying rows, the same defaults as database/sql are returned.
+type WrappedRows struct {
+	Rows    driver.Rows
+	ctx     context.Context
+	query   string
+	columns []string
+	stats   RowsStats
+	onRow   OnRow
+	onClose OnClose
+}
+
+func newWrappedRows(ctx context.Context, query string, rows driver.Rows, wrapper RowsMiddleware) *WrappedRows {
+	wrapped := &WrappedRows{
+		Rows:  rows,
+		ctx:   ctx,
+		query: query,
+	}
+	wrapped.onRow = wrapper.OnRow(wrapped.next)
+	wrapped.onClose = wrapper.OnClose(func(context.Context, string, RowsStats) error {
+		return wrapped.Rows.Close()
+	})
+	return wrapped
+}
+
+func (rows *WrappedRows) Columns() []string {
+	if rows.columns == nil {
+		rows.columns = rows.Rows.Columns()
+	}
+	return rows.columns
+}
+
+func (rows *WrappedRows) next(_ context.Context, _ string, _ []string, dest []driver.Value) error {
+	err := rows.Rows.Next(dest)
+	if err != nil {
+		return err
+	}
+	rows.stats.Rows++
+	for _, v := range dest {
+		rows.stats.Bytes += valueSize(v)
+	}
+	return nil
+}
+
+func (rows *WrappedRows) Next(dest []driver.Value) error {
+	return rows.onRow(rows.ctx, rows.query, rows.Columns(), dest)
+}
+
+func (rows *WrappedRows) Close() error {
+	return rows.onClose(rows.ctx, rows.query, rows.stats)
+}
+
+// Stats return the statistics of rows read so far
+func (rows *WrappedRows) Stats() RowsStats {
+	return rows.stats
+}
+
+func (rows *WrappedRows) HasNextResultSet() bool {
+	if r, ok := rows.Rows.(driver.RowsNextResultSet); ok {
+		return r.HasNextResultSet()
+	}
+	return false
+}
+
+func (rows *WrappedRows) NextResultSet() error {
+	if r, ok := rows.Rows.(driver.RowsNextResultSet); ok {
+		rows.columns = nil
+		return r.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (rows *WrappedRows) ColumnTypeScanType(index int) reflect.Type {
+	if r, ok := rows.Rows.(driver.RowsColumnTypeScanType); ok {
+		return r.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+func (rows *WrappedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if r, ok := rows.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return r.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (rows *WrappedRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if r, ok := rows.Rows.(driver.RowsColumnTypeLength); ok {
+		return r.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (rows *WrappedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if r, ok := rows.Rows.(driver.RowsColumnTypeNullable); ok {
+		return r.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (rows *WrappedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if r, ok := rows.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return r.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+// valueSize approximate size of driver.Value in bytes
+func valueSize(v driver.Value) int64 {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	case bool:
+		return 1
+	default:
+		return 8 // int64, float64, time.Time
+	}
+}
+
+var (
+	_ driver.RowsNextResultSet              = (*WrappedRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*WrappedRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*WrappedRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*WrappedRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*WrappedRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*WrappedRows)(nil)
+)
@@ -0,0 +1,90 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+var errTooManyRows = errors.New("too many rows")
+
+type rowsLimiter struct {
+	recorder
+	maxRows int
+	stats   []sqlkit.RowsStats
+}
+
+func (r *rowsLimiter) OnRow(next sqlkit.OnRow) sqlkit.OnRow {
+	n := 0
+	return func(ctx context.Context, query string, columns []string, dest []driver.Value) error {
+		err := next(ctx, query, columns, dest)
+		if err != nil {
+			return err
+		}
+		n++
+		if n > r.maxRows {
+			return errTooManyRows
+		}
+		for i, column := range columns {
+			if column == "text" {
+				dest[i] = "***"
+			}
+		}
+		return nil
+	}
+}
+
+func (r *rowsLimiter) OnClose(next sqlkit.OnClose) sqlkit.OnClose {
+	return func(ctx context.Context, query string, stats sqlkit.RowsStats) error {
+		r.stats = append(r.stats, stats)
+		return next(ctx, query, stats)
+	}
+}
+
+func TestRowsMiddleware(t *testing.T) {
+	var calls []string
+	rl := &rowsLimiter{recorder: recorder{name: "rows", calls: &calls}, maxRows: 2}
+	sql.Register("sqlite3:rows", sqlkit.Wrap(&sqlite3.SQLiteDriver{}, sqlkit.Chain(rl)))
+	db, err := sql.Open("sqlite3:rows", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE t1 (id INTEGER, text VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO t1 (id, text) VALUES(1, 'foo'), (2, 'bar'), (3, 'baz')")
+	assert.Nil(t, err)
+
+	rows, err := db.QueryContext(ctx, "SELECT id, text FROM t1 WHERE id < ?", 3)
+	assert.Nil(t, err)
+	types, err := rows.ColumnTypes()
+	assert.Nil(t, err)
+	assert.Equal(t, "INTEGER", types[0].DatabaseTypeName())
+	assert.Equal(t, "VARCHAR(16)", types[1].DatabaseTypeName())
+	var texts []string
+	for rows.Next() {
+		var (
+			id   int
+			text string
+		)
+		assert.Nil(t, rows.Scan(&id, &text))
+		texts = append(texts, text)
+	}
+	assert.Nil(t, rows.Err())
+	assert.Equal(t, []string{"***", "***"}, texts)
+	assert.Equal(t, []sqlkit.RowsStats{{Rows: 2, Bytes: 22}}, rl.stats)
+
+	rows, err = db.QueryContext(ctx, "SELECT id, text FROM t1")
+	assert.Nil(t, err)
+	for rows.Next() {
+	}
+	assert.Equal(t, errTooManyRows, rows.Err())
+}
@@ -0,0 +1,70 @@
+package sqlkit
+
+// scanSql scans the sql and calls fn with the kind and byte range of placeholders(kind `?`),
+// named placeholders or variables(kind `:` or `@`, e.g. `:name`, `@name`, range includes the prefix) and
+// quoted strings or identifiers(kind is the quote char, range includes the quotes), comments are skipped.
+func scanSql(sql string, fn func(kind byte, start, end int)) {
+	for i := 0; i < len(sql); i++ {
+		switch c := sql[i]; c {
+		case '?':
+			fn(c, i, i+1)
+		case ':', '@':
+			if i+1 < len(sql) && sql[i+1] == c { // NOTE: `@@system_var` or `::`
+				i++
+				continue
+			}
+			if i > 0 && (isNameByte(sql[i-1]) || sql[i-1] == ':' || sql[i-1] == '@') {
+				continue
+			}
+			if i+1 >= len(sql) || !isNameByte(sql[i+1]) || sql[i+1] >= '0' && sql[i+1] <= '9' {
+				continue
+			}
+			start := i
+			for i++; i+1 < len(sql) && isNameByte(sql[i+1]); i++ {
+			}
+			fn(c, start, i+1)
+		case '\'', '"', '`':
+			start := i
+			for i++; i < len(sql); i++ {
+				if sql[i] == '\\' && c != '`' {
+					i++
+					continue
+				}
+				if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c { // NOTE: doubled quote
+						i++
+						continue
+					}
+					break
+				}
+			}
+			if i >= len(sql) { // NOTE: unterminated
+				fn(c, start, len(sql))
+				return
+			}
+			fn(c, start, i+1)
+		case '#':
+			i = skipLine(sql, i)
+		case '-':
+			if i+2 < len(sql) && sql[i+1] == '-' && (sql[i+2] == ' ' || sql[i+2] == '\t' || sql[i+2] == '\n') {
+				i = skipLine(sql, i)
+			}
+		case '/':
+			if i+1 < len(sql) && sql[i+1] == '*' {
+				for i += 2; i+1 < len(sql) && !(sql[i] == '*' && sql[i+1] == '/'); i++ {
+				}
+				i++
+			}
+		}
+	}
+}
+
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
+
+func skipLine(sql string, i int) int {
+	for ; i < len(sql) && sql[i] != '\n'; i++ {
+	}
+	return i
+}
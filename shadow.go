@@ -0,0 +1,48 @@
+package sqlkit
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// ShadowHeader http header used to propagate the shadow(load-test) flag, see `ShadowHandler` and `SetShadowHeader`
+var ShadowHeader = "X-Shadow-Traffic"
+
+type shadowCtxKey struct{}
+
+// WithShadow marks ctx as shadow(load-test) traffic
+func WithShadow(ctx context.Context) context.Context {
+	return context.WithValue(ctx, shadowCtxKey{}, true)
+}
+
+// IsShadow reports whether ctx is marked as shadow(load-test) traffic, it can be used as `RewriteMiddleware.When`
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{
+//         Rewriter: &sqlkit.ShadowTable{Suffix: "_shadow", Tables: []string{"orders", "users"}},
+//         When:     sqlkit.IsShadow,
+//     }
+//
+func IsShadow(ctx context.Context) bool {
+	shadow, _ := ctx.Value(shadowCtxKey{}).(bool)
+	return shadow
+}
+
+// ShadowHandler marks the request context as shadow traffic if the `ShadowHeader` is true
+func ShadowHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shadow, _ := strconv.ParseBool(r.Header.Get(ShadowHeader)); shadow {
+			r = r.WithContext(WithShadow(r.Context()))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetShadowHeader propagates the shadow flag of ctx to the outgoing request header
+func SetShadowHeader(ctx context.Context, header http.Header) {
+	if IsShadow(ctx) {
+		header.Set(ShadowHeader, "true")
+	}
+}
@@ -0,0 +1,127 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestShadowTableFallback(t *testing.T) {
+	st := &sqlkit.ShadowTable{Suffix: "_shadow", Tables: []string{"orders"}}
+	assert.Nil(t, st.Provision(context.Background()))
+	var cases = []struct {
+		sql    string
+		shadow string
+		err    error
+	}{
+		{
+			sql:    "SELECT o.id, d.name, dict.id FROM orders AS o JOIN dict AS d ON o.dict_id = d.id JOIN dict ON orders.id = dict.id",
+			shadow: "SELECT o_shadow.id,d.name,dict.id FROM (orders_shadow AS o_shadow JOIN dict AS d ON o_shadow.dict_id=d.id) JOIN dict ON orders_shadow.id=dict.id",
+		},
+		{
+			sql:    "UPDATE orders SET amount = 1 WHERE dict_id IN (SELECT id FROM dict)",
+			shadow: "UPDATE orders_shadow SET amount=1 WHERE dict_id IN (SELECT id FROM dict)",
+		},
+		{
+			sql:    "INSERT INTO orders (id) SELECT id FROM dict",
+			shadow: "INSERT INTO orders_shadow (id) SELECT id FROM dict",
+		},
+		{
+			sql:    "DELETE o FROM orders AS o JOIN dict AS d ON o.dict_id = d.id",
+			shadow: "DELETE o_shadow FROM orders_shadow AS o_shadow JOIN dict AS d ON o_shadow.dict_id=d.id",
+		},
+		{
+			sql: "INSERT INTO dict (id) VALUES (1)",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+		{
+			sql: "DELETE d FROM orders AS o JOIN dict AS d ON o.dict_id = d.id",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+		{
+			sql: "UPDATE orders JOIN dict ON orders.dict_id = dict.id SET orders.amount = 1",
+			err: sqlkit.ErrShadowWriteRefused,
+		},
+	}
+	for _, tc := range cases {
+		s, err := st.RewriteSql(tc.sql)
+		if tc.err != nil {
+			assert.True(t, errors.Is(err, tc.err), tc.sql)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.shadow, s)
+	}
+}
+
+func TestShadowRouting(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.ShadowTable{Suffix: "_shadow", Tables: []string{"orders"}},
+		When:     sqlkit.IsShadow,
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:shadow", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:shadow", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	for _, ddl := range []string{
+		"CREATE TABLE orders (id INTEGER, dict_id INTEGER)",
+		"CREATE TABLE orders_shadow (id INTEGER, dict_id INTEGER)",
+		"CREATE TABLE dict (id INTEGER, name VARCHAR(16))",
+		"INSERT INTO dict (id, name) VALUES (1, 'foo')",
+	} {
+		_, err = db.ExecContext(ctx, ddl)
+		assert.Nil(t, err)
+	}
+
+	shadowCtx := sqlkit.WithShadow(ctx)
+	_, err = db.ExecContext(ctx, "INSERT INTO orders (id, dict_id) VALUES (1, 1)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(shadowCtx, "INSERT INTO orders (id, dict_id) VALUES (2, 1)")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(shadowCtx, "INSERT INTO dict (id, name) VALUES (2, 'bar')")
+	assert.True(t, errors.Is(err, sqlkit.ErrShadowWriteRefused))
+
+	var id int
+	var name string
+	query := "SELECT orders.id, dict.name FROM orders JOIN dict ON orders.dict_id = dict.id"
+	assert.Nil(t, db.QueryRowContext(ctx, query).Scan(&id, &name))
+	assert.Equal(t, 1, id)
+	assert.Nil(t, db.QueryRowContext(shadowCtx, query).Scan(&id, &name))
+	assert.Equal(t, 2, id)
+	assert.Equal(t, "foo", name)
+
+	stmt, err := db.PrepareContext(ctx, "SELECT id FROM orders")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	assert.Nil(t, stmt.QueryRowContext(ctx).Scan(&id))
+	assert.Equal(t, 1, id)
+	err = stmt.QueryRowContext(shadowCtx).Scan(&id)
+	assert.True(t, errors.Is(err, sqlkit.ErrStmtRewriteMismatch))
+}
+
+func TestShadowHandler(t *testing.T) {
+	var shadows []bool
+	h := sqlkit.ShadowHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		shadows = append(shadows, sqlkit.IsShadow(r.Context()))
+		header := http.Header{}
+		sqlkit.SetShadowHeader(r.Context(), header)
+		assert.Equal(t, sqlkit.IsShadow(r.Context()), header.Get(sqlkit.ShadowHeader) == "true")
+	}))
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	r.Header.Set(sqlkit.ShadowHeader, "1")
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Equal(t, []bool{false, true}, shadows)
+}
@@ -0,0 +1,294 @@
+package sqlkit
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// SoftDelete rewrites queries on soft-deleted tables:
+//   - `deleted_at IS NULL`(or `Predicate`) is added to WHERE of SELECT(including subqueries and derived tables),
+//     and to the ON condition of the nullable side of outer joins
+//   - DELETE is turned into `UPDATE ... SET deleted_at = NOW()` if `UpdateOnDelete` is true
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{
+//         Rewriter: &sqlkit.SoftDelete{Tables: []string{"orders"}, UpdateOnDelete: true},
+//     }
+//
+type SoftDelete struct {
+	// Column soft delete column, default is `deleted_at`
+	Column string `json:"column,omitempty"`
+
+	// Predicate the filter of rows not deleted, default is `<Column> IS NULL`, e.g. `is_deleted = 0`,
+	// the unqualified columns are qualified by the table or alias
+	Predicate string `json:"predicate,omitempty"`
+
+	// Tables soft-deleted tables
+	Tables []string `json:"tables"`
+
+	// UpdateOnDelete turns DELETE into `UPDATE ... SET <Column> = NOW()`
+	UpdateOnDelete bool `json:"update_on_delete,omitempty"`
+
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser    *parser.Parser
+	mu        sync.Mutex // NOTE: parser is not goroutine safe
+	cache     *Cache[string, string]
+	logger    *zap.Logger
+	tables    map[string]struct{}
+	predicate ast.ExprNode
+}
+
+func (sd *SoftDelete) Name() string {
+	return "soft_delete"
+}
+
+func (sd *SoftDelete) Provision(ctx context.Context) error {
+	if len(sd.Tables) == 0 {
+		return errors.New("soft delete with empty tables")
+	}
+	if sd.Column == "" {
+		sd.Column = "deleted_at"
+	}
+	sd.parser = parser.New()
+	sd.cache = NewCache[string, string](sd.Name(), sd.Cache)
+	sd.tables = tableSet(sd.Tables)
+	if sd.Predicate != "" {
+		stmtNode, err := sd.parser.ParseOneStmt("SELECT 1 FROM DUAL WHERE "+sd.Predicate, "", "")
+		if err != nil {
+			return errors.WithMessagef(err, "invalid soft delete predicate: %s", sd.Predicate)
+		}
+		sd.predicate = stmtNode.(*ast.SelectStmt).Where
+	}
+	return nil
+}
+
+func (sd *SoftDelete) SetLogger(logger *zap.Logger) {
+	sd.logger = logger
+}
+
+func (sd *SoftDelete) isDeletable(table string) bool {
+	_, ok := sd.tables[strings.ToLower(table)]
+	return ok
+}
+
+func (sd *SoftDelete) Rewrite(sql string, args []any) (string, []any, error) {
+	sql, err := sd.RewriteSql(sql)
+	return sql, args, err
+}
+
+func (sd *SoftDelete) RewriteSql(sql string) (string, error) {
+	if result, ok := sd.cache.Load(sql); ok {
+		return result, nil
+	}
+	sd.mu.Lock()
+	defer sd.mu.Unlock() // NOTE: predicates are parsed while visiting
+	stmtNodes, warns, err := sd.parser.Parse(sql, "", "")
+	if err != nil {
+		return "", errors.WithMessagef(err, "parser sql faield: %s", sql)
+	}
+	if len(warns) > 0 && sd.logger != nil {
+		sd.logger.Debug("soft delete warnings", zap.Any("warns", warns), zap.String("sql", sql))
+	}
+	var (
+		results   = make([]string, 0, len(stmtNodes))
+		rewritten bool
+	)
+	for _, stmtNode := range stmtNodes {
+		visitor := &softDeleteVisitor{sd: sd}
+		node, _ := stmtNode.Accept(visitor)
+		if visitor.err != nil {
+			return "", errors.WithMessagef(visitor.err, "sql: %s", sql)
+		}
+		if !visitor.rewritten {
+			results = append(results, strings.TrimSuffix(strings.TrimSpace(stmtNode.Text()), ";"))
+			continue
+		}
+		rewritten = true
+		result, err := restore(node)
+		if err != nil {
+			return "", errors.WithMessagef(err, "restore failed for sql: %s", sql)
+		}
+		results = append(results, result)
+	}
+	result := sql
+	if rewritten {
+		result = strings.Join(results, "; ")
+	}
+	sd.cache.Store(sql, result)
+	return result, nil
+}
+
+// softDeleteVisitor adds predicates to SELECT and turns DELETE into UPDATE when leaving the nodes,
+// so the subqueries are rewritten before the outer ones and the added nodes are not visited
+type softDeleteVisitor struct {
+	sd        *SoftDelete
+	rewritten bool
+	err       error
+}
+
+func (v *softDeleteVisitor) Enter(in ast.Node) (ast.Node, bool) {
+	return in, v.err != nil
+}
+
+func (v *softDeleteVisitor) Leave(in ast.Node) (ast.Node, bool) {
+	if v.err != nil {
+		return in, false
+	}
+	switch n := in.(type) {
+	case *ast.SelectStmt:
+		if n.From != nil {
+			v.err = injectPredicates(n.From.TableRefs, &n.Where, v.filter)
+		}
+	case *ast.DeleteStmt:
+		if v.sd.UpdateOnDelete {
+			var update *ast.UpdateStmt
+			update, v.err = v.update(n)
+			if update != nil {
+				v.rewritten = true
+				return update, v.err == nil
+			}
+		}
+	}
+	return in, v.err == nil
+}
+
+// filter return the predicate of the soft-deleted table
+func (v *softDeleteVisitor) filter(ts *ast.TableSource, tn *ast.TableName) ast.ExprNode {
+	if !v.sd.isDeletable(tn.Name.String()) {
+		return nil
+	}
+	v.rewritten = true
+	schema, table := qualifier(ts, tn)
+	if v.sd.predicate == nil {
+		return &ast.IsNullExpr{Expr: &ast.ColumnNameExpr{Name: &ast.ColumnName{Schema: schema, Table: table, Name: model.NewCIStr(v.sd.Column)}}}
+	}
+	stmtNode, err := v.sd.parser.ParseOneStmt("SELECT 1 FROM DUAL WHERE "+v.sd.Predicate, "", "")
+	if err != nil { // NOTE: never happen since it is parsed in Provision
+		v.err = err
+		return nil
+	}
+	predicate := stmtNode.(*ast.SelectStmt).Where
+	predicate.Accept(&columnQualifier{schema: schema, table: table})
+	return &ast.ParenthesesExpr{Expr: predicate}
+}
+
+// update return the UPDATE which soft deletes the rows, or nil if no soft-deleted table is deleted from
+func (v *softDeleteVisitor) update(n *ast.DeleteStmt) (*ast.UpdateStmt, error) {
+	update := &ast.UpdateStmt{
+		TableRefs:     n.TableRefs,
+		Where:         n.Where,
+		Order:         n.Order,
+		Limit:         n.Limit,
+		Priority:      n.Priority,
+		IgnoreErr:     n.IgnoreErr,
+		MultipleTable: n.IsMultiTable,
+		TableHints:    n.TableHints,
+		With:          n.With,
+	}
+	var deletes, softDeletes int
+	if !n.IsMultiTable {
+		ts, ok := n.TableRefs.TableRefs.Left.(*ast.TableSource)
+		if !ok {
+			return nil, nil
+		}
+		tn, ok := ts.Source.(*ast.TableName)
+		if !ok || !v.sd.isDeletable(tn.Name.String()) {
+			return nil, nil
+		}
+		deletes, softDeletes = 1, 1
+		update.List = append(update.List, v.assignment(model.CIStr{}, model.CIStr{}))
+		update.Where = andExpr(update.Where, v.filter(ts, tn))
+	} else {
+		sources := tableSources(n.TableRefs.TableRefs)
+		for _, target := range n.Tables.Tables {
+			deletes++
+			ts, tn := lookupTableSource(sources, target)
+			if tn == nil || !v.sd.isDeletable(tn.Name.String()) {
+				continue
+			}
+			softDeletes++
+			schema, table := qualifier(ts, tn)
+			update.List = append(update.List, v.assignment(schema, table))
+			update.Where = andExpr(update.Where, v.filter(ts, tn))
+		}
+	}
+	if softDeletes == 0 {
+		return nil, nil
+	}
+	if softDeletes < deletes {
+		return nil, errors.New("soft delete: delete from both soft-deleted and other tables")
+	}
+	return update, nil
+}
+
+func (v *softDeleteVisitor) assignment(schema, table model.CIStr) *ast.Assignment {
+	return &ast.Assignment{
+		Column: &ast.ColumnName{Schema: schema, Table: table, Name: model.NewCIStr(v.sd.Column)},
+		Expr:   &ast.FuncCallExpr{FnName: model.NewCIStr("NOW")},
+	}
+}
+
+// tableSources return the table sources of the join tree
+func tableSources(node ast.ResultSetNode) []*ast.TableSource {
+	switch n := node.(type) {
+	case *ast.Join:
+		sources := tableSources(n.Left)
+		if n.Right != nil {
+			sources = append(sources, tableSources(n.Right)...)
+		}
+		return sources
+	case *ast.TableSource:
+		return []*ast.TableSource{n}
+	}
+	return nil
+}
+
+// lookupTableSource return the table source referred by the target of multi-table DELETE, i.e. alias or table name
+func lookupTableSource(sources []*ast.TableSource, target *ast.TableName) (*ast.TableSource, *ast.TableName) {
+	for _, ts := range sources {
+		tn, ok := ts.Source.(*ast.TableName)
+		if !ok {
+			continue
+		}
+		if ts.AsName.String() != "" {
+			if ts.AsName.L == target.Name.L {
+				return ts, tn
+			}
+		} else if tn.Name.L == target.Name.L && (target.Schema.L == "" || tn.Schema.L == target.Schema.L) {
+			return ts, tn
+		}
+	}
+	return nil, nil
+}
+
+// columnQualifier qualifies the unqualified columns
+type columnQualifier struct {
+	schema model.CIStr
+	table  model.CIStr
+}
+
+func (c *columnQualifier) Enter(in ast.Node) (ast.Node, bool) {
+	if n, ok := in.(*ast.ColumnName); ok && n.Table.L == "" {
+		n.Schema, n.Table = c.schema, c.table
+	}
+	return in, false
+}
+
+func (c *columnQualifier) Leave(in ast.Node) (ast.Node, bool) {
+	return in, true
+}
+
+var (
+	_ RewriterInterface = (*SoftDelete)(nil)
+	_ SqlRewriter       = (*SoftDelete)(nil)
+)
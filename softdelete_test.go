@@ -0,0 +1,80 @@
+package sqlkit_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestSoftDelete(t *testing.T) {
+	sd := &sqlkit.SoftDelete{Tables: []string{"orders", "users"}, UpdateOnDelete: true}
+	assert.Nil(t, sd.Provision(context.Background()))
+	var cases = []struct {
+		sql    string
+		result string
+		err    bool
+	}{
+		{
+			sql:    "SELECT id FROM dict WHERE id = ?",
+			result: "SELECT id FROM dict WHERE id = ?",
+		},
+		{
+			sql:    "SELECT o.id FROM orders AS o WHERE o.id = ? OR o.amount > ?",
+			result: "SELECT o.id FROM orders AS o WHERE (o.id=? OR o.amount>?) AND o.deleted_at IS NULL",
+		},
+		{
+			sql:    "SELECT o.id, u.name FROM orders o LEFT JOIN users u ON o.user_id = u.id",
+			result: "SELECT o.id,u.name FROM orders AS o LEFT JOIN users AS u ON (o.user_id=u.id) AND u.deleted_at IS NULL WHERE o.deleted_at IS NULL",
+		},
+		{
+			sql:    "SELECT d.id FROM dict d JOIN (SELECT dict_id FROM orders) AS t ON t.dict_id = d.id WHERE d.id IN (SELECT dict_id FROM users WHERE age > ?)",
+			result: "SELECT d.id FROM dict AS d JOIN (SELECT dict_id FROM orders WHERE orders.deleted_at IS NULL) AS t ON t.dict_id=d.id WHERE d.id IN (SELECT dict_id FROM users WHERE (age>?) AND users.deleted_at IS NULL)",
+		},
+		{
+			sql:    "UPDATE dict SET name = ? WHERE id IN (SELECT dict_id FROM orders)",
+			result: "UPDATE dict SET name=? WHERE id IN (SELECT dict_id FROM orders WHERE orders.deleted_at IS NULL)",
+		},
+		{
+			sql:    "DELETE FROM orders WHERE id = ?",
+			result: "UPDATE orders SET deleted_at=NOW() WHERE (id=?) AND orders.deleted_at IS NULL",
+		},
+		{
+			sql:    "DELETE o FROM orders AS o JOIN dict AS d ON o.dict_id = d.id WHERE d.name = ?",
+			result: "UPDATE orders AS o JOIN dict AS d ON o.dict_id=d.id SET o.deleted_at=NOW() WHERE (d.name=?) AND o.deleted_at IS NULL",
+		},
+		{
+			sql:    "DELETE o, u FROM orders o JOIN users u ON o.user_id = u.id",
+			result: "UPDATE orders AS o JOIN users AS u ON o.user_id=u.id SET o.deleted_at=NOW(), u.deleted_at=NOW() WHERE (o.deleted_at IS NULL) AND u.deleted_at IS NULL",
+		},
+		{
+			sql:    "DELETE FROM dict WHERE id = ?",
+			result: "DELETE FROM dict WHERE id = ?",
+		},
+		{
+			sql: "DELETE o, d FROM orders AS o JOIN dict AS d ON o.dict_id = d.id",
+			err: true,
+		},
+		{
+			sql: "SELECT dict.id FROM dict LEFT JOIN orders USING (id)",
+			err: true,
+		},
+	}
+	for _, tc := range cases {
+		s, err := sd.RewriteSql(tc.sql)
+		if tc.err {
+			assert.NotNil(t, err, tc.sql)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.result, s)
+	}
+
+	sd = &sqlkit.SoftDelete{Tables: []string{"orders"}, Predicate: "is_deleted = 0"}
+	assert.Nil(t, sd.Provision(context.Background()))
+	s, err := sd.RewriteSql("SELECT o.id FROM orders o; DELETE FROM orders")
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT o.id FROM orders AS o WHERE (o.is_deleted=0); DELETE FROM orders", s)
+}
@@ -66,3 +66,10 @@ func (m *SyncMap[K, V]) Store(key K, value V) {
 	defer m.lock.Unlock()
 	m.kvs[key] = value
 }
+
+// Clear deletes all the keys
+func (m *SyncMap[K, V]) Clear() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.kvs = make(map[K]V)
+}
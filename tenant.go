@@ -0,0 +1,366 @@
+package sqlkit
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/pingcap/tidb/parser"
+	"github.com/pingcap/tidb/parser/ast"
+	"github.com/pingcap/tidb/parser/model"
+	"github.com/pingcap/tidb/parser/opcode"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrTenantRequired returned by TenantIsolation if the query touches tenant-scoped tables but no tenant in ctx
+	ErrTenantRequired = errors.New("tenant required")
+
+	// ErrTenantUnsafe returned by TenantIsolation if the query can not be rewritten safely
+	ErrTenantUnsafe = errors.New("query can not be rewritten safely for tenant isolation")
+)
+
+// tenantSentinel a string literal marks where the tenant placeholder is injected, queries with the literal are refused
+const tenantSentinel = "__sqlkit_tenant__"
+
+// TenantIsolation rewrites queries on tenant-scoped tables with tenant predicates:
+//   - `tenant_id = ?` is added to WHERE of SELECT(including subqueries and derived tables), UPDATE and DELETE,
+//     and to the ON condition of the nullable side of outer joins
+//   - tenant_id is filled on INSERT/REPLACE(VALUES, SET and SELECT forms)
+//   - the tenant is read from `QueryInfo.Tenant` of ctx by default, and passed as arg
+//
+// queries which can not be rewritten safely are refused with ErrTenantUnsafe, e.g. INSERT without column list,
+// assignments to the tenant column, outer JOIN with USING on the nullable side(no ON condition to add to),
+// TRUNCATE, destructive DDL(DROP/RENAME TABLE, ALTER TABLE ... DROP/RENAME/TRUNCATE PARTITION) unless `AllowDestructiveDDL`,
+// and other unsupported statements on tenant-scoped tables.
+// NOTE: other DDL statements(e.g. CREATE TABLE, ALTER TABLE ... ADD COLUMN) on tenant-scoped tables are passed through unchanged,
+// since they operate on the schema rather than on rows of a tenant.
+//
+// Usage:
+//
+//     rm := &sqlkit.RewriteMiddleware{
+//         Rewriter: &sqlkit.TenantIsolation{Tables: []string{"orders", "users"}},
+//     }
+//     ctx = sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Tenant: "t1"})
+//
+type TenantIsolation struct {
+	// Column tenant column name, default is `tenant_id`
+	Column string `json:"column,omitempty"`
+
+	// Tables tenant-scoped tables
+	Tables []string `json:"tables"`
+
+	// AllowDestructiveDDL passes through destructive DDL on tenant-scoped tables, e.g. for schema migrations
+	AllowDestructiveDDL bool `json:"allow_destructive_ddl,omitempty"`
+
+	// TenantFunc return the tenant of ctx, default is `QueryInfo.Tenant`
+	TenantFunc func(context.Context) (string, bool) `json:"-"`
+
+	// Cache config of the cache of rewritten sqls, default is `DefaultCacheSize` entries without TTL
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	parser *parser.Parser
+	mu     sync.Mutex // NOTE: parser is not goroutine safe
+	cache  *Cache[string, *tenantRewrite]
+	logger *zap.Logger
+	tables map[string]struct{}
+}
+
+// tenantRewrite the rewritten sql, and the positions of original args, -1 means tenant
+type tenantRewrite struct {
+	sql   string
+	args  []int
+	nargs int // NOTE: number of original args
+}
+
+func (ti *TenantIsolation) Name() string {
+	return "tenant_isolation"
+}
+
+func (ti *TenantIsolation) Provision(ctx context.Context) error {
+	if len(ti.Tables) == 0 {
+		return errors.New("tenant isolation with empty tables")
+	}
+	if ti.Column == "" {
+		ti.Column = "tenant_id"
+	}
+	if ti.TenantFunc == nil {
+		ti.TenantFunc = func(ctx context.Context) (string, bool) {
+			info, ok := GetQueryInfo(ctx)
+			return info.Tenant, ok && info.Tenant != ""
+		}
+	}
+	if ti.logger == nil {
+		ti.logger = zap.NewNop()
+	}
+	ti.parser = parser.New()
+	ti.cache = NewCache[string, *tenantRewrite](ti.Name(), ti.Cache)
+	ti.tables = tableSet(ti.Tables)
+	return nil
+}
+
+func (ti *TenantIsolation) SetLogger(logger *zap.Logger) {
+	ti.logger = logger
+}
+
+// Rewrite always fails since the tenant is read from ctx, use RewriteContext instead
+func (ti *TenantIsolation) Rewrite(sql string, args []any) (string, []any, error) {
+	return "", nil, errors.WithMessage(ErrTenantRequired, "tenant isolation requires context")
+}
+
+func (ti *TenantIsolation) RewriteContext(ctx context.Context, sql string, args []any) (string, []any, error) {
+	tr, err := ti.rewriteSql(sql)
+	if err != nil {
+		return "", nil, err
+	}
+	if tr.sql == sql {
+		return sql, args, nil
+	}
+	tenant, ok := ti.TenantFunc(ctx)
+	if !ok {
+		return "", nil, errors.WithMessagef(ErrTenantRequired, "sql: %s", sql)
+	}
+	if args == nil { // NOTE: prepare
+		return tr.sql, nil, nil
+	}
+	if len(args) != tr.nargs {
+		return "", nil, errors.Errorf("sql: expected %d arguments, got %d: %s", tr.nargs, len(args), sql)
+	}
+	newArgs := make([]any, len(tr.args))
+	for i, pos := range tr.args {
+		if pos < 0 {
+			newArgs[i] = tenant
+		} else {
+			newArgs[i] = args[pos]
+		}
+	}
+	return tr.sql, newArgs, nil
+}
+
+func (ti *TenantIsolation) isScoped(table string) bool {
+	_, ok := ti.tables[strings.ToLower(table)]
+	return ok
+}
+
+func (ti *TenantIsolation) rewriteSql(sql string) (*tenantRewrite, error) {
+	if tr, ok := ti.cache.Load(sql); ok {
+		return tr, nil
+	}
+	ti.mu.Lock()
+	stmtNodes, warns, err := ti.parser.Parse(sql, "", "")
+	ti.mu.Unlock()
+	if err != nil {
+		return nil, errors.WithMessagef(err, "parser sql faield: %s", sql)
+	}
+	if len(warns) > 0 {
+		ti.logger.Debug("tenant isolation warnings", zap.Any("warns", warns), zap.String("sql", sql))
+	}
+	var (
+		results  = make([]string, 0, len(stmtNodes))
+		injected bool
+	)
+	for _, stmtNode := range stmtNodes {
+		if hasTenantSentinel(stmtNode) {
+			return nil, errors.WithMessagef(ErrTenantUnsafe, "literal %s is reserved: %s", tenantSentinel, sql)
+		}
+	}
+	for _, stmtNode := range stmtNodes {
+		ok, err := ti.rewriteStmt(stmtNode)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "sql: %s", sql)
+		}
+		if !ok {
+			results = append(results, strings.TrimSuffix(strings.TrimSpace(stmtNode.Text()), ";"))
+			continue
+		}
+		injected = true
+		result, err := restoreBackQuoted(stmtNode)
+		if err != nil {
+			return nil, errors.WithMessagef(err, "restore failed for sql: %s", sql)
+		}
+		results = append(results, result)
+	}
+	tr := &tenantRewrite{sql: sql}
+	if injected {
+		tr = newTenantRewrite(strings.Join(results, "; "))
+	}
+	ti.cache.Store(sql, tr)
+	return tr, nil
+}
+
+// newTenantRewrite replaces the tenant sentinels of the restored sql with placeholders, and records the args positions
+func newTenantRewrite(restored string) *tenantRewrite {
+	var (
+		sb   strings.Builder
+		tr   = &tenantRewrite{}
+		last int
+	)
+	sentinel := "'" + tenantSentinel + "'"
+	scanSql(restored, func(kind byte, start, end int) {
+		switch {
+		case kind == '?':
+			tr.args = append(tr.args, tr.nargs)
+			tr.nargs++
+		case kind == '\'' && restored[start:end] == sentinel:
+			sb.WriteString(restored[last:start])
+			sb.WriteString("?")
+			last = end
+			tr.args = append(tr.args, -1)
+		}
+	})
+	sb.WriteString(restored[last:])
+	tr.sql = sb.String()
+	return tr
+}
+
+// hasTenantSentinel reports whether the statement has a literal equal to the sentinel, which would be bound to the tenant
+func hasTenantSentinel(stmtNode ast.StmtNode) bool {
+	c := &nodeCollector{}
+	stmtNode.Accept(c)
+	for _, n := range c.nodes {
+		if v, ok := n.(ast.ValueExpr); ok && v.GetValue() == tenantSentinel {
+			return true
+		}
+	}
+	return false
+}
+
+// isDestructiveDDL reports whether the DDL drops or renames tables, columns or partitions
+func isDestructiveDDL(stmtNode ast.StmtNode) bool {
+	switch n := stmtNode.(type) {
+	case *ast.DropTableStmt, *ast.RenameTableStmt:
+		return true
+	case *ast.AlterTableStmt:
+		for _, spec := range n.Specs {
+			switch spec.Tp {
+			case ast.AlterTableDropColumn, ast.AlterTableRenameColumn, ast.AlterTableRenameTable,
+				ast.AlterTableDropPartition, ast.AlterTableTruncatePartition:
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rewriteStmt injects tenant predicates into the statement, return false if not rewritten
+func (ti *TenantIsolation) rewriteStmt(stmtNode ast.StmtNode) (bool, error) {
+	c := &nodeCollector{}
+	stmtNode.Accept(c)
+	scoped := false
+	for _, n := range c.nodes {
+		if tn, ok := n.(*ast.TableName); ok && ti.isScoped(tn.Name.String()) {
+			scoped = true
+			break
+		}
+	}
+	if !scoped {
+		return false, nil
+	}
+	switch stmtNode.(type) {
+	case *ast.SelectStmt, *ast.SetOprStmt, *ast.InsertStmt, *ast.UpdateStmt, *ast.DeleteStmt:
+	case *ast.TruncateTableStmt:
+		return false, errors.WithMessage(ErrTenantUnsafe, "truncate tenant-scoped table")
+	case ast.DDLNode:
+		if !ti.AllowDestructiveDDL && isDestructiveDDL(stmtNode) {
+			return false, errors.WithMessagef(ErrTenantUnsafe, "destructive DDL %T on tenant-scoped table", stmtNode)
+		}
+		return false, nil // NOTE: schema changes are not tenant-scoped
+	default:
+		return false, errors.WithMessagef(ErrTenantUnsafe, "unsupported statement %T", stmtNode)
+	}
+	for _, n := range c.nodes {
+		var err error
+		switch n := n.(type) {
+		case *ast.SelectStmt:
+			if n.From != nil {
+				err = ti.injectJoin(n.From.TableRefs, &n.Where)
+			}
+		case *ast.UpdateStmt:
+			if err = ti.checkAssignments(n.List); err == nil {
+				err = ti.injectJoin(n.TableRefs.TableRefs, &n.Where)
+			}
+		case *ast.DeleteStmt:
+			err = ti.injectJoin(n.TableRefs.TableRefs, &n.Where)
+		case *ast.InsertStmt:
+			err = ti.injectInsert(n)
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// injectJoin injects tenant predicates of tenant-scoped tables in the join tree
+func (ti *TenantIsolation) injectJoin(node ast.ResultSetNode, where *ast.ExprNode) error {
+	err := injectPredicates(node, where, func(ts *ast.TableSource, tn *ast.TableName) ast.ExprNode {
+		if !ti.isScoped(tn.Name.String()) {
+			return nil
+		}
+		schema, table := qualifier(ts, tn)
+		return &ast.BinaryOperationExpr{
+			Op: opcode.EQ,
+			L:  &ast.ColumnNameExpr{Name: &ast.ColumnName{Schema: schema, Table: table, Name: model.NewCIStr(ti.Column)}},
+			R:  ast.NewValueExpr(tenantSentinel, "", ""),
+		}
+	})
+	if err != nil {
+		return errors.WithMessage(ErrTenantUnsafe, err.Error())
+	}
+	return nil
+}
+
+func (ti *TenantIsolation) injectInsert(n *ast.InsertStmt) error {
+	tn, ok := n.Table.TableRefs.Left.(*ast.TableSource).Source.(*ast.TableName)
+	if !ok || !ti.isScoped(tn.Name.String()) {
+		return nil
+	}
+	if err := ti.checkAssignments(n.OnDuplicate); err != nil {
+		return err
+	}
+	if len(n.Setlist) > 0 {
+		if err := ti.checkAssignments(n.Setlist); err != nil {
+			return err
+		}
+		n.Setlist = append(n.Setlist, &ast.Assignment{
+			Column: &ast.ColumnName{Name: model.NewCIStr(ti.Column)},
+			Expr:   ast.NewValueExpr(tenantSentinel, "", ""),
+		})
+		return nil
+	}
+	if len(n.Columns) == 0 {
+		return errors.WithMessagef(ErrTenantUnsafe, "insert into %s without column list", tn.Name.String())
+	}
+	for _, column := range n.Columns {
+		if column.Name.L == strings.ToLower(ti.Column) {
+			return errors.WithMessagef(ErrTenantUnsafe, "insert into %s with explicit %s", tn.Name.String(), ti.Column)
+		}
+	}
+	n.Columns = append(n.Columns, &ast.ColumnName{Name: model.NewCIStr(ti.Column)})
+	for i := range n.Lists {
+		n.Lists[i] = append(n.Lists[i], ast.NewValueExpr(tenantSentinel, "", ""))
+	}
+	if n.Select != nil {
+		sel, ok := n.Select.(*ast.SelectStmt)
+		if !ok {
+			return errors.WithMessagef(ErrTenantUnsafe, "insert into %s with %T", tn.Name.String(), n.Select)
+		}
+		sel.Fields.Fields = append(sel.Fields.Fields, &ast.SelectField{Expr: ast.NewValueExpr(tenantSentinel, "", "")})
+	}
+	return nil
+}
+
+func (ti *TenantIsolation) checkAssignments(assignments []*ast.Assignment) error {
+	for _, assignment := range assignments {
+		if assignment.Column.Name.L == strings.ToLower(ti.Column) {
+			return errors.WithMessagef(ErrTenantUnsafe, "assignment to %s", ti.Column)
+		}
+	}
+	return nil
+}
+
+var (
+	_ RewriterInterface = (*TenantIsolation)(nil)
+	_ ContextRewriter   = (*TenantIsolation)(nil)
+)
@@ -0,0 +1,216 @@
+package sqlkit_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ccmonky/sqlkit"
+)
+
+func TestTenantIsolation(t *testing.T) {
+	ti := &sqlkit.TenantIsolation{Tables: []string{"orders", "users"}}
+	assert.Nil(t, ti.Provision(context.Background()))
+	ctx := sqlkit.WithQueryInfo(context.Background(), sqlkit.QueryInfo{Tenant: "t1"})
+	var cases = []struct {
+		sql     string
+		args    []any
+		tenant  string
+		newArgs []any
+		err     error
+	}{
+		{
+			sql:     "SELECT id FROM dict WHERE id = ?",
+			args:    []any{1},
+			tenant:  "SELECT id FROM dict WHERE id = ?",
+			newArgs: []any{1},
+		},
+		{
+			sql:     "SELECT o.id FROM orders AS o WHERE o.id = ? OR o.amount > ?",
+			args:    []any{1, 2},
+			tenant:  "SELECT `o`.`id` FROM `orders` AS `o` WHERE (`o`.`id`=? OR `o`.`amount`>?) AND `o`.`tenant_id`=?",
+			newArgs: []any{1, 2, "t1"},
+		},
+		{
+			sql:     "SELECT `key`, `desc` FROM orders WHERE id = ? AND @a = ?",
+			args:    []any{1, 2},
+			tenant:  "SELECT `key`,`desc` FROM `orders` WHERE (`id`=? AND @a=?) AND `orders`.`tenant_id`=?",
+			newArgs: []any{1, 2, "t1"},
+		},
+		{
+			sql:     "SELECT o.id, u.name FROM orders o JOIN users u ON o.user_id = u.id JOIN dict d ON d.id = ?",
+			args:    []any{3},
+			tenant:  "SELECT `o`.`id`,`u`.`name` FROM (`orders` AS `o` JOIN `users` AS `u` ON `o`.`user_id`=`u`.`id`) JOIN `dict` AS `d` ON `d`.`id`=? WHERE (`o`.`tenant_id`=?) AND `u`.`tenant_id`=?",
+			newArgs: []any{3, "t1", "t1"},
+		},
+		{
+			sql:     "SELECT orders.id FROM orders LEFT JOIN users ON orders.user_id = users.id WHERE orders.id = ?",
+			args:    []any{1},
+			tenant:  "SELECT `orders`.`id` FROM `orders` LEFT JOIN `users` ON (`orders`.`user_id`=`users`.`id`) AND `users`.`tenant_id`=? WHERE (`orders`.`id`=?) AND `orders`.`tenant_id`=?",
+			newArgs: []any{"t1", 1, "t1"},
+		},
+		{
+			sql:     "SELECT id FROM dict WHERE id IN (SELECT dict_id FROM orders WHERE amount > ?) AND name = 'it''s ?'",
+			args:    []any{1},
+			tenant:  "SELECT `id` FROM `dict` WHERE `id` IN (SELECT `dict_id` FROM `orders` WHERE (`amount`>?) AND `orders`.`tenant_id`=?) AND `name`='it''s ?'",
+			newArgs: []any{1, "t1"},
+		},
+		{
+			sql:     "SELECT id FROM orders UNION SELECT id FROM users",
+			args:    []any{},
+			tenant:  "SELECT `id` FROM `orders` WHERE `orders`.`tenant_id`=? UNION SELECT `id` FROM `users` WHERE `users`.`tenant_id`=?",
+			newArgs: []any{"t1", "t1"},
+		},
+		{
+			sql:     "UPDATE orders SET amount = ? WHERE id = ?",
+			args:    []any{1, 2},
+			tenant:  "UPDATE `orders` SET `amount`=? WHERE (`id`=?) AND `orders`.`tenant_id`=?",
+			newArgs: []any{1, 2, "t1"},
+		},
+		{
+			sql:     "DELETE FROM orders",
+			args:    []any{},
+			tenant:  "DELETE FROM `orders` WHERE `orders`.`tenant_id`=?",
+			newArgs: []any{"t1"},
+		},
+		{
+			sql:     "INSERT INTO orders (id, amount) VALUES (?, ?), (?, ?)",
+			args:    []any{1, 2, 3, 4},
+			tenant:  "INSERT INTO `orders` (`id`,`amount`,`tenant_id`) VALUES (?,?,?),(?,?,?)",
+			newArgs: []any{1, 2, "t1", 3, 4, "t1"},
+		},
+		{
+			sql:     "INSERT INTO orders SET id = ?",
+			args:    []any{1},
+			tenant:  "INSERT INTO `orders` SET `id`=?,`tenant_id`=?",
+			newArgs: []any{1, "t1"},
+		},
+		{
+			sql:     "INSERT INTO orders (id) SELECT id FROM users WHERE id > ?",
+			args:    []any{1},
+			tenant:  "INSERT INTO `orders` (`id`,`tenant_id`) SELECT `id`,? FROM `users` WHERE (`id`>?) AND `users`.`tenant_id`=?",
+			newArgs: []any{"t1", 1, "t1"},
+		},
+		{
+			sql: "INSERT INTO orders VALUES (?, ?)",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "INSERT INTO orders (id, tenant_id) VALUES (?, ?)",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "UPDATE orders SET tenant_id = ?",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql:     "SELECT id FROM orders JOIN users USING (id)",
+			args:    []any{},
+			tenant:  "SELECT `id` FROM `orders` JOIN `users` USING (`id`) WHERE (`orders`.`tenant_id`=?) AND `users`.`tenant_id`=?",
+			newArgs: []any{"t1", "t1"},
+		},
+		{
+			sql:     "ALTER TABLE orders ADD COLUMN note VARCHAR(16)",
+			args:    []any{},
+			tenant:  "ALTER TABLE orders ADD COLUMN note VARCHAR(16)",
+			newArgs: []any{},
+		},
+		{
+			sql: "SELECT id FROM orders LEFT JOIN users USING (id)",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "TRUNCATE TABLE orders",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "DROP TABLE orders",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "RENAME TABLE orders TO orders_old",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "ALTER TABLE orders DROP COLUMN tenant_id",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "SELECT id FROM orders WHERE note = '__sqlkit_tenant__'",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+		{
+			sql: "SELECT id FROM orders WHERE note = '__sqlkit_' 'tenant__'",
+			err: sqlkit.ErrTenantUnsafe,
+		},
+	}
+	for _, tc := range cases {
+		s, args, err := ti.RewriteContext(ctx, tc.sql, tc.args)
+		if tc.err != nil {
+			assert.True(t, errors.Is(err, tc.err), tc.sql)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tc.tenant, s)
+		assert.Equal(t, tc.newArgs, args)
+	}
+
+	_, _, err := ti.RewriteContext(context.Background(), "SELECT id FROM orders", []any{})
+	assert.True(t, errors.Is(err, sqlkit.ErrTenantRequired))
+	s, _, err := ti.RewriteContext(context.Background(), "SELECT id FROM dict", []any{})
+	assert.Nil(t, err)
+	assert.Equal(t, "SELECT id FROM dict", s)
+
+	ti = &sqlkit.TenantIsolation{Tables: []string{"orders"}, AllowDestructiveDDL: true}
+	assert.Nil(t, ti.Provision(ctx))
+	s, _, err = ti.RewriteContext(ctx, "DROP TABLE orders", []any{})
+	assert.Nil(t, err)
+	assert.Equal(t, "DROP TABLE orders", s)
+}
+
+func TestTenantIsolationMiddleware(t *testing.T) {
+	rm := &sqlkit.RewriteMiddleware{
+		Rewriter: &sqlkit.TenantIsolation{Tables: []string{"orders"}},
+	}
+	ctx := context.Background()
+	assert.Nil(t, rm.Provision(ctx))
+	sql.Register("sqlite3:tenant", sqlkit.WrapChain(&sqlite3.SQLiteDriver{}, rm))
+	db, err := sql.Open("sqlite3:tenant", ":memory:")
+	assert.Nil(t, err)
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE orders (id INTEGER, amount INTEGER, tenant_id VARCHAR(16))")
+	assert.Nil(t, err)
+	_, err = db.ExecContext(ctx, "INSERT INTO orders (id, amount) VALUES (1, 10)")
+	assert.True(t, errors.Is(err, sqlkit.ErrTenantRequired))
+
+	t1 := sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Tenant: "t1"})
+	t2 := sqlkit.WithQueryInfo(ctx, sqlkit.QueryInfo{Tenant: "t2"})
+	_, err = db.ExecContext(t1, "INSERT INTO orders (id, amount) VALUES (?, ?)", 1, 10)
+	assert.Nil(t, err)
+	_, err = db.ExecContext(t2, "INSERT INTO orders (id, amount) VALUES (?, ?)", 2, 20)
+	assert.Nil(t, err)
+
+	var count, amount int
+	assert.Nil(t, db.QueryRowContext(t1, "SELECT COUNT(*), SUM(amount) FROM orders").Scan(&count, &amount))
+	assert.Equal(t, 1, count)
+	assert.Equal(t, 10, amount)
+
+	res, err := db.ExecContext(t2, "UPDATE orders SET amount = ?", 30)
+	assert.Nil(t, err)
+	affected, _ := res.RowsAffected()
+	assert.Equal(t, int64(1), affected)
+
+	stmt, err := db.PrepareContext(t1, "SELECT amount FROM orders WHERE id > ?")
+	assert.Nil(t, err)
+	defer stmt.Close()
+	assert.Nil(t, stmt.QueryRowContext(t1, 0).Scan(&amount))
+	assert.Equal(t, 10, amount)
+	assert.Nil(t, stmt.QueryRowContext(t2, 0).Scan(&amount))
+	assert.Equal(t, 30, amount)
+}
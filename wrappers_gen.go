@@ -0,0 +1,780 @@
+// Code generated by "go run gen_wrappers.go"; DO NOT EDIT.
+
+package sqlkit
+
+import "database/sql/driver"
+
+type connOptional int
+
+const (
+	connExecer connOptional = 1 << iota
+	connQueryer
+	connSessionResetter
+	connPinger
+	connNamedValueChecker
+	connValidator
+)
+
+// wrapConnOptionals return a driver.Conn which implements the same optional interfaces as the underlying one
+func wrapConnOptionals(wrapped *Conn, mask connOptional) driver.Conn {
+	switch mask {
+	case connExecer:
+		return &ExecerContext{wrapped}
+	case connQueryer:
+		return &QueryerContext{wrapped}
+	case connExecer | connQueryer:
+		return &ExecerQueryerContext{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}}
+	case connSessionResetter:
+		return &SessionResetter{wrapped}
+	case connExecer | connSessionResetter:
+		return &connExecerSessionResetter{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}}
+	case connQueryer | connSessionResetter:
+		return &connQueryerSessionResetter{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}}
+	case connExecer | connQueryer | connSessionResetter:
+		return &ExecerQueryerContextWithSessionResetter{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}}
+	case connPinger:
+		return &Pinger{wrapped}
+	case connExecer | connPinger:
+		return &connExecerPinger{wrapped, &ExecerContext{wrapped}, &Pinger{wrapped}}
+	case connQueryer | connPinger:
+		return &connQueryerPinger{wrapped, &QueryerContext{wrapped}, &Pinger{wrapped}}
+	case connExecer | connQueryer | connPinger:
+		return &connExecerQueryerPinger{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &Pinger{wrapped}}
+	case connSessionResetter | connPinger:
+		return &connSessionResetterPinger{wrapped, &SessionResetter{wrapped}, &Pinger{wrapped}}
+	case connExecer | connSessionResetter | connPinger:
+		return &connExecerSessionResetterPinger{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}}
+	case connQueryer | connSessionResetter | connPinger:
+		return &connQueryerSessionResetterPinger{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connPinger:
+		return &connExecerQueryerSessionResetterPinger{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}}
+	case connNamedValueChecker:
+		return &NamedValueChecker{wrapped}
+	case connExecer | connNamedValueChecker:
+		return &connExecerNamedValueChecker{wrapped, &ExecerContext{wrapped}, &NamedValueChecker{wrapped}}
+	case connQueryer | connNamedValueChecker:
+		return &connQueryerNamedValueChecker{wrapped, &QueryerContext{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connQueryer | connNamedValueChecker:
+		return &connExecerQueryerNamedValueChecker{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &NamedValueChecker{wrapped}}
+	case connSessionResetter | connNamedValueChecker:
+		return &connSessionResetterNamedValueChecker{wrapped, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connSessionResetter | connNamedValueChecker:
+		return &connExecerSessionResetterNamedValueChecker{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}}
+	case connQueryer | connSessionResetter | connNamedValueChecker:
+		return &connQueryerSessionResetterNamedValueChecker{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connNamedValueChecker:
+		return &connExecerQueryerSessionResetterNamedValueChecker{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}}
+	case connPinger | connNamedValueChecker:
+		return &connPingerNamedValueChecker{wrapped, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connPinger | connNamedValueChecker:
+		return &connExecerPingerNamedValueChecker{wrapped, &ExecerContext{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connQueryer | connPinger | connNamedValueChecker:
+		return &connQueryerPingerNamedValueChecker{wrapped, &QueryerContext{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connQueryer | connPinger | connNamedValueChecker:
+		return &connExecerQueryerPingerNamedValueChecker{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connSessionResetter | connPinger | connNamedValueChecker:
+		return &connSessionResetterPingerNamedValueChecker{wrapped, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connSessionResetter | connPinger | connNamedValueChecker:
+		return &connExecerSessionResetterPingerNamedValueChecker{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connQueryer | connSessionResetter | connPinger | connNamedValueChecker:
+		return &connQueryerSessionResetterPingerNamedValueChecker{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connPinger | connNamedValueChecker:
+		return &connExecerQueryerSessionResetterPingerNamedValueChecker{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}}
+	case connValidator:
+		return &Validator{wrapped}
+	case connExecer | connValidator:
+		return &connExecerValidator{wrapped, &ExecerContext{wrapped}, &Validator{wrapped}}
+	case connQueryer | connValidator:
+		return &connQueryerValidator{wrapped, &QueryerContext{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connValidator:
+		return &connExecerQueryerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &Validator{wrapped}}
+	case connSessionResetter | connValidator:
+		return &connSessionResetterValidator{wrapped, &SessionResetter{wrapped}, &Validator{wrapped}}
+	case connExecer | connSessionResetter | connValidator:
+		return &connExecerSessionResetterValidator{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &Validator{wrapped}}
+	case connQueryer | connSessionResetter | connValidator:
+		return &connQueryerSessionResetterValidator{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connValidator:
+		return &connExecerQueryerSessionResetterValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Validator{wrapped}}
+	case connPinger | connValidator:
+		return &connPingerValidator{wrapped, &Pinger{wrapped}, &Validator{wrapped}}
+	case connExecer | connPinger | connValidator:
+		return &connExecerPingerValidator{wrapped, &ExecerContext{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connQueryer | connPinger | connValidator:
+		return &connQueryerPingerValidator{wrapped, &QueryerContext{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connPinger | connValidator:
+		return &connExecerQueryerPingerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connSessionResetter | connPinger | connValidator:
+		return &connSessionResetterPingerValidator{wrapped, &SessionResetter{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connExecer | connSessionResetter | connPinger | connValidator:
+		return &connExecerSessionResetterPingerValidator{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connQueryer | connSessionResetter | connPinger | connValidator:
+		return &connQueryerSessionResetterPingerValidator{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connPinger | connValidator:
+		return &connExecerQueryerSessionResetterPingerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &Validator{wrapped}}
+	case connNamedValueChecker | connValidator:
+		return &connNamedValueCheckerValidator{wrapped, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connNamedValueChecker | connValidator:
+		return &connExecerNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connQueryer | connNamedValueChecker | connValidator:
+		return &connQueryerNamedValueCheckerValidator{wrapped, &QueryerContext{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connNamedValueChecker | connValidator:
+		return &connExecerQueryerNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connSessionResetter | connNamedValueChecker | connValidator:
+		return &connSessionResetterNamedValueCheckerValidator{wrapped, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connSessionResetter | connNamedValueChecker | connValidator:
+		return &connExecerSessionResetterNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connQueryer | connSessionResetter | connNamedValueChecker | connValidator:
+		return &connQueryerSessionResetterNamedValueCheckerValidator{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connNamedValueChecker | connValidator:
+		return &connExecerQueryerSessionResetterNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connPinger | connNamedValueChecker | connValidator:
+		return &connPingerNamedValueCheckerValidator{wrapped, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connPinger | connNamedValueChecker | connValidator:
+		return &connExecerPingerNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connQueryer | connPinger | connNamedValueChecker | connValidator:
+		return &connQueryerPingerNamedValueCheckerValidator{wrapped, &QueryerContext{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connPinger | connNamedValueChecker | connValidator:
+		return &connExecerQueryerPingerNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connSessionResetter | connPinger | connNamedValueChecker | connValidator:
+		return &connSessionResetterPingerNamedValueCheckerValidator{wrapped, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connSessionResetter | connPinger | connNamedValueChecker | connValidator:
+		return &connExecerSessionResetterPingerNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connQueryer | connSessionResetter | connPinger | connNamedValueChecker | connValidator:
+		return &connQueryerSessionResetterPingerNamedValueCheckerValidator{wrapped, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	case connExecer | connQueryer | connSessionResetter | connPinger | connNamedValueChecker | connValidator:
+		return &connExecerQueryerSessionResetterPingerNamedValueCheckerValidator{wrapped, &ExecerContext{wrapped}, &QueryerContext{wrapped}, &SessionResetter{wrapped}, &Pinger{wrapped}, &NamedValueChecker{wrapped}, &Validator{wrapped}}
+	default:
+		return wrapped
+	}
+}
+
+type connExecerSessionResetter struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+}
+
+type connQueryerSessionResetter struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+}
+
+type connExecerPinger struct {
+	*Conn
+	*ExecerContext
+	*Pinger
+}
+
+type connQueryerPinger struct {
+	*Conn
+	*QueryerContext
+	*Pinger
+}
+
+type connExecerQueryerPinger struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*Pinger
+}
+
+type connSessionResetterPinger struct {
+	*Conn
+	*SessionResetter
+	*Pinger
+}
+
+type connExecerSessionResetterPinger struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*Pinger
+}
+
+type connQueryerSessionResetterPinger struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+}
+
+type connExecerQueryerSessionResetterPinger struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+}
+
+type connExecerNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*NamedValueChecker
+}
+
+type connQueryerNamedValueChecker struct {
+	*Conn
+	*QueryerContext
+	*NamedValueChecker
+}
+
+type connExecerQueryerNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*NamedValueChecker
+}
+
+type connSessionResetterNamedValueChecker struct {
+	*Conn
+	*SessionResetter
+	*NamedValueChecker
+}
+
+type connExecerSessionResetterNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*NamedValueChecker
+}
+
+type connQueryerSessionResetterNamedValueChecker struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*NamedValueChecker
+}
+
+type connExecerQueryerSessionResetterNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*NamedValueChecker
+}
+
+type connPingerNamedValueChecker struct {
+	*Conn
+	*Pinger
+	*NamedValueChecker
+}
+
+type connExecerPingerNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*Pinger
+	*NamedValueChecker
+}
+
+type connQueryerPingerNamedValueChecker struct {
+	*Conn
+	*QueryerContext
+	*Pinger
+	*NamedValueChecker
+}
+
+type connExecerQueryerPingerNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*Pinger
+	*NamedValueChecker
+}
+
+type connSessionResetterPingerNamedValueChecker struct {
+	*Conn
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+}
+
+type connExecerSessionResetterPingerNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+}
+
+type connQueryerSessionResetterPingerNamedValueChecker struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+}
+
+type connExecerQueryerSessionResetterPingerNamedValueChecker struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+}
+
+type connExecerValidator struct {
+	*Conn
+	*ExecerContext
+	*Validator
+}
+
+type connQueryerValidator struct {
+	*Conn
+	*QueryerContext
+	*Validator
+}
+
+type connExecerQueryerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*Validator
+}
+
+type connSessionResetterValidator struct {
+	*Conn
+	*SessionResetter
+	*Validator
+}
+
+type connExecerSessionResetterValidator struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*Validator
+}
+
+type connQueryerSessionResetterValidator struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*Validator
+}
+
+type connExecerQueryerSessionResetterValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*Validator
+}
+
+type connPingerValidator struct {
+	*Conn
+	*Pinger
+	*Validator
+}
+
+type connExecerPingerValidator struct {
+	*Conn
+	*ExecerContext
+	*Pinger
+	*Validator
+}
+
+type connQueryerPingerValidator struct {
+	*Conn
+	*QueryerContext
+	*Pinger
+	*Validator
+}
+
+type connExecerQueryerPingerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*Pinger
+	*Validator
+}
+
+type connSessionResetterPingerValidator struct {
+	*Conn
+	*SessionResetter
+	*Pinger
+	*Validator
+}
+
+type connExecerSessionResetterPingerValidator struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*Pinger
+	*Validator
+}
+
+type connQueryerSessionResetterPingerValidator struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+	*Validator
+}
+
+type connExecerQueryerSessionResetterPingerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+	*Validator
+}
+
+type connNamedValueCheckerValidator struct {
+	*Conn
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*NamedValueChecker
+	*Validator
+}
+
+type connQueryerNamedValueCheckerValidator struct {
+	*Conn
+	*QueryerContext
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerQueryerNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*NamedValueChecker
+	*Validator
+}
+
+type connSessionResetterNamedValueCheckerValidator struct {
+	*Conn
+	*SessionResetter
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerSessionResetterNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*NamedValueChecker
+	*Validator
+}
+
+type connQueryerSessionResetterNamedValueCheckerValidator struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerQueryerSessionResetterNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*NamedValueChecker
+	*Validator
+}
+
+type connPingerNamedValueCheckerValidator struct {
+	*Conn
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerPingerNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connQueryerPingerNamedValueCheckerValidator struct {
+	*Conn
+	*QueryerContext
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerQueryerPingerNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connSessionResetterPingerNamedValueCheckerValidator struct {
+	*Conn
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerSessionResetterPingerNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connQueryerSessionResetterPingerNamedValueCheckerValidator struct {
+	*Conn
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+type connExecerQueryerSessionResetterPingerNamedValueCheckerValidator struct {
+	*Conn
+	*ExecerContext
+	*QueryerContext
+	*SessionResetter
+	*Pinger
+	*NamedValueChecker
+	*Validator
+}
+
+var (
+	_ driver.ExecerContext     = (*ExecerContext)(nil)
+	_ driver.QueryerContext    = (*QueryerContext)(nil)
+	_ driver.ExecerContext     = (*ExecerQueryerContext)(nil)
+	_ driver.QueryerContext    = (*ExecerQueryerContext)(nil)
+	_ driver.SessionResetter   = (*SessionResetter)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetter)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetter)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetter)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetter)(nil)
+	_ driver.ExecerContext     = (*ExecerQueryerContextWithSessionResetter)(nil)
+	_ driver.QueryerContext    = (*ExecerQueryerContextWithSessionResetter)(nil)
+	_ driver.SessionResetter   = (*ExecerQueryerContextWithSessionResetter)(nil)
+	_ driver.Pinger            = (*Pinger)(nil)
+	_ driver.ExecerContext     = (*connExecerPinger)(nil)
+	_ driver.Pinger            = (*connExecerPinger)(nil)
+	_ driver.QueryerContext    = (*connQueryerPinger)(nil)
+	_ driver.Pinger            = (*connQueryerPinger)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerPinger)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerPinger)(nil)
+	_ driver.Pinger            = (*connExecerQueryerPinger)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterPinger)(nil)
+	_ driver.Pinger            = (*connSessionResetterPinger)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterPinger)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterPinger)(nil)
+	_ driver.Pinger            = (*connExecerSessionResetterPinger)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterPinger)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterPinger)(nil)
+	_ driver.Pinger            = (*connQueryerSessionResetterPinger)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterPinger)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterPinger)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterPinger)(nil)
+	_ driver.Pinger            = (*connExecerQueryerSessionResetterPinger)(nil)
+	_ driver.NamedValueChecker = (*NamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connQueryerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connQueryerNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connSessionResetterNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerSessionResetterNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerSessionResetterNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connPingerNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connExecerPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerPingerNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connQueryerPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connQueryerPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connQueryerPingerNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerPingerNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connExecerQueryerPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerPingerNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connExecerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.Pinger            = (*connExecerQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerSessionResetterPingerNamedValueChecker)(nil)
+	_ driver.Validator         = (*Validator)(nil)
+	_ driver.ExecerContext     = (*connExecerValidator)(nil)
+	_ driver.Validator         = (*connExecerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerValidator)(nil)
+	_ driver.Validator         = (*connQueryerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerValidator)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterValidator)(nil)
+	_ driver.Validator         = (*connSessionResetterValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterValidator)(nil)
+	_ driver.Validator         = (*connExecerSessionResetterValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterValidator)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterValidator)(nil)
+	_ driver.Validator         = (*connQueryerSessionResetterValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerSessionResetterValidator)(nil)
+	_ driver.Pinger            = (*connPingerValidator)(nil)
+	_ driver.Validator         = (*connPingerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerPingerValidator)(nil)
+	_ driver.Pinger            = (*connExecerPingerValidator)(nil)
+	_ driver.Validator         = (*connExecerPingerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerPingerValidator)(nil)
+	_ driver.Pinger            = (*connQueryerPingerValidator)(nil)
+	_ driver.Validator         = (*connQueryerPingerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerPingerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerPingerValidator)(nil)
+	_ driver.Pinger            = (*connExecerQueryerPingerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerPingerValidator)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterPingerValidator)(nil)
+	_ driver.Pinger            = (*connSessionResetterPingerValidator)(nil)
+	_ driver.Validator         = (*connSessionResetterPingerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterPingerValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterPingerValidator)(nil)
+	_ driver.Pinger            = (*connExecerSessionResetterPingerValidator)(nil)
+	_ driver.Validator         = (*connExecerSessionResetterPingerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterPingerValidator)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterPingerValidator)(nil)
+	_ driver.Pinger            = (*connQueryerSessionResetterPingerValidator)(nil)
+	_ driver.Validator         = (*connQueryerSessionResetterPingerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterPingerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterPingerValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterPingerValidator)(nil)
+	_ driver.Pinger            = (*connExecerQueryerSessionResetterPingerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerSessionResetterPingerValidator)(nil)
+	_ driver.NamedValueChecker = (*connNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connQueryerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connQueryerNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerSessionResetterNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connPingerNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connExecerPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerPingerNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connExecerQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerPingerNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connExecerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.ExecerContext     = (*connExecerQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.QueryerContext    = (*connExecerQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.SessionResetter   = (*connExecerQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Pinger            = (*connExecerQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.NamedValueChecker = (*connExecerQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+	_ driver.Validator         = (*connExecerQueryerSessionResetterPingerNamedValueCheckerValidator)(nil)
+)
+
+type stmtOptional int
+
+const (
+	stmtNamedValueChecker stmtOptional = 1 << iota
+	stmtColumnConverter
+)
+
+// wrapStmtOptionals return a driver.Stmt which implements the same optional interfaces as the underlying one
+func wrapStmtOptionals(wrapped *Stmt, mask stmtOptional) driver.Stmt {
+	switch mask {
+	case stmtNamedValueChecker:
+		return &StmtNamedValueChecker{wrapped}
+	case stmtColumnConverter:
+		return &StmtColumnConverter{wrapped}
+	case stmtNamedValueChecker | stmtColumnConverter:
+		return &stmtNamedValueCheckerColumnConverter{wrapped, &StmtNamedValueChecker{wrapped}, &StmtColumnConverter{wrapped}}
+	default:
+		return wrapped
+	}
+}
+
+type stmtNamedValueCheckerColumnConverter struct {
+	*Stmt
+	*StmtNamedValueChecker
+	*StmtColumnConverter
+}
+
+var (
+	_ driver.NamedValueChecker = (*StmtNamedValueChecker)(nil)
+	_ driver.ColumnConverter   = (*StmtColumnConverter)(nil)
+	_ driver.NamedValueChecker = (*stmtNamedValueCheckerColumnConverter)(nil)
+	_ driver.ColumnConverter   = (*stmtNamedValueCheckerColumnConverter)(nil)
+)